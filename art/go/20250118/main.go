@@ -1,12 +1,11 @@
 package main
 
 import (
-	"fmt"
 	"math/rand"
-	"sort"
 	"time"
 
 	"github.com/ryomak/p5go"
+	"github.com/ryomak/sketch/art/internal/life"
 )
 
 const (
@@ -149,56 +148,8 @@ func countNeighbours(column, row int) int {
 
 // 領域を割り当てる
 func assignRegions() ([][]int, map[int]string) {
-	regionID := 0
-	regions := make2DArray(columnCount, rowCount)
-	shapeKeys := make(map[int]string)
-
-	visited := make2DArray(columnCount, rowCount)
-
-	// Flood Fill を使って領域を識別
-	for column := 0; column < columnCount; column++ {
-		for row := 0; row < rowCount; row++ {
-			if currentCells[column][row] == 1 && regions[column][row] == 0 {
-				regionID++
-				shape := []string{}
-				floodFill(column, row, regionID, regions, visited, &shape)
-				shapeKeys[regionID] = calculateShapeKey(shape)
-			}
-		}
-	}
-	return regions, shapeKeys
-}
-
-// Flood Fill アルゴリズムで領域を塗りつぶし、形状を記録
-func floodFill(column, row, regionID int, regions, visited [][]int, shape *[]string) {
-	if column < 0 || column >= columnCount || row < 0 || row >= rowCount {
-		return
-	}
-	if visited[column][row] == 1 || currentCells[column][row] == 0 {
-		return
-	}
-
-	visited[column][row] = 1
-	regions[column][row] = regionID
-
-	// 形状の相対位置を記録
-	*shape = append(*shape, fmt.Sprintf("%d,%d", column, row))
-
-	// 周囲8方向に対して再帰的に処理
-	directions := [][2]int{
-		{-1, -1}, {0, -1}, {1, -1},
-		{-1, 0}, {1, 0},
-		{-1, 1}, {0, 1}, {1, 1},
-	}
-	for _, dir := range directions {
-		floodFill(column+dir[0], row+dir[1], regionID, regions, visited, shape)
-	}
-}
-
-// 形状キーを計算
-func calculateShapeKey(shape []string) string {
-	sort.Strings(shape) // 形状を一意にするためソート
-	return fmt.Sprintf("%v", shape)
+	regions := life.LabelRegions(currentCells)
+	return regions, life.ShapeKeys(regions)
 }
 
 // 形状ごとの色を取得
@@ -29,8 +29,29 @@ const (
 	// 細胞間相互作用のパラメータ
 	repulsionConstant  = 0.5 // 近すぎる場合の反発力
 	attractionConstant = 0.5 // わずかな引力（重力的な効果として強めに設定）
+
+	// ソフトボディ（細胞膜）モードのパラメータ
+	membranePoints   = 24   // 膜を構成する点の数
+	membraneSubsteps = 4    // 1フレームあたりの膜の物理計算回数
+	membraneSpring   = 60.0 // 隣接点をつなぐバネの強さ
+	membraneBending  = 20.0 // 膜を滑らかに保つ曲げ剛性
+	membranePressure = 4.0  // 内圧の強さ（目標面積との差に比例）
+	membraneDamping  = 6.0  // 膜の振動の減衰
+	pinchDuration    = 1.2  // 分裂時に膜がくびれるまでの時間（秒）
+	pinchStrength    = 30.0 // くびれを作る収縮力
+	pinchBandWidth   = 0.35 // くびれが効く帯の幅（半径に対する比）
 )
 
+// softBodyMode が true のとき、細胞を円ではなくバネでつながった膜として扱います。
+// クリックで切り替えられます。
+var softBodyMode = false
+
+// MembranePoint は細胞膜を構成する1点を表します。
+type MembranePoint struct {
+	x, y   float64
+	vx, vy float64
+}
+
 // Cell は細胞の状態を表します。
 type Cell struct {
 	x, y              float64  // 位置（中心）
@@ -44,6 +65,11 @@ type Cell struct {
 	growthRate  float64 // 個体ごとの成長速度
 	shrinkRate  float64 // 個体ごとの縮小速度
 	speedFactor float64 // 個体ごとの移動速度係数
+
+	membrane   []MembranePoint // 細胞膜（ソフトボディモード時のみ使用）
+	pinching   bool            // 分裂のくびれが進行中かどうか
+	pinchTime  float64         // くびれ開始からの経過時間（秒）
+	pinchAngle float64         // 娘細胞が並ぶ方向（ラジアン）
 }
 
 // mutateColor は、与えられた色に±20のランダムな変動を加えた色を返します。
@@ -84,8 +110,13 @@ func (c *Cell) Update(dt float64, sim *Simulation) {
 	}
 
 	// 位置更新（速度に個体ごとの speedFactor をかける）
-	c.x += c.vx * dt * c.speedFactor
-	c.y += c.vy * dt * c.speedFactor
+	dx := c.vx * dt * c.speedFactor
+	dy := c.vy * dt * c.speedFactor
+	c.x += dx
+	c.y += dy
+	if softBodyMode {
+		c.translateMembrane(dx, dy)
+	}
 
 	// 画面端で反射
 	if c.x < c.r {
@@ -103,13 +134,22 @@ func (c *Cell) Update(dt float64, sim *Simulation) {
 		c.vy = -c.vy
 	}
 
+	// ソフトボディモードでは膜を変形させ、中心を膜の重心に合わせる
+	if softBodyMode {
+		c.stepMembrane(dt)
+		c.x, c.y = c.centroid()
+	}
+
 	// 成長フェーズ中で、最大サイズに達したら縮小フェーズへ
 	if !c.isShrinking && c.r >= maxSize {
 		c.isShrinking = true
 	}
 
 	// 分裂イベント：年齢が閾値を超え、かつ全体細胞数が maxCells 未満なら分裂
-	if c.age >= c.divisionThreshold && len(sim.cells) < maxCells {
+	if softBodyMode {
+		// ソフトボディモードでは膜をくびれさせてから2つに分ける
+		c.updatePinch(dt, sim)
+	} else if c.age >= c.divisionThreshold && len(sim.cells) < maxCells {
 		// 分裂時、元の細胞はリセット（半径70%に縮小、年齢リセット、成長フェーズに戻す）
 		c.age = 0
 		c.r *= 0.7
@@ -117,20 +157,10 @@ func (c *Cell) Update(dt float64, sim *Simulation) {
 		c.isShrinking = false
 
 		// 新たな細胞を元の細胞の近傍に生成
-		newCell := Cell{
-			x:                 c.x + (rand.Float64()*2-1)*c.r,
-			y:                 c.y + (rand.Float64()*2-1)*c.r,
-			r:                 c.r,
-			age:               0,
-			divisionThreshold: randomInRange(divisionThresholdMin, divisionThresholdMax),
-			vx:                (rand.Float64()*2 - 1) * 40,
-			vy:                (rand.Float64()*2 - 1) * 40,
-			color:             mutateColor(c.color),
-			isShrinking:       false,
-			growthRate:        randomInRange(growthRateMin, growthRateMax),
-			shrinkRate:        randomInRange(shrinkRateMin, shrinkRateMax),
-			speedFactor:       randomInRange(speedFactorMin, speedFactorMax),
-		}
+		newCell := c.newDaughter(
+			c.x+(rand.Float64()*2-1)*c.r,
+			c.y+(rand.Float64()*2-1)*c.r,
+		)
 		sim.cells = append(sim.cells, newCell)
 	}
 
@@ -145,10 +175,300 @@ func (c *Cell) Update(dt float64, sim *Simulation) {
 		c.growthRate = randomInRange(growthRateMin, growthRateMax)
 		c.shrinkRate = randomInRange(shrinkRateMin, shrinkRateMax)
 		c.speedFactor = randomInRange(speedFactorMin, speedFactorMax)
+		c.pinching = false
+		if softBodyMode {
+			c.membrane = newMembrane(c.x, c.y, c.r)
+		}
 		// 色はそのまま
 	}
 }
 
+// newDaughter は、c から分裂した新しい細胞を (x, y) に生成します。
+func (c *Cell) newDaughter(x, y float64) Cell {
+	return Cell{
+		x:                 x,
+		y:                 y,
+		r:                 c.r,
+		age:               0,
+		divisionThreshold: randomInRange(divisionThresholdMin, divisionThresholdMax),
+		vx:                (rand.Float64()*2 - 1) * 40,
+		vy:                (rand.Float64()*2 - 1) * 40,
+		color:             mutateColor(c.color),
+		isShrinking:       false,
+		growthRate:        randomInRange(growthRateMin, growthRateMax),
+		shrinkRate:        randomInRange(shrinkRateMin, shrinkRateMax),
+		speedFactor:       randomInRange(speedFactorMin, speedFactorMax),
+	}
+}
+
+// updatePinch は、ソフトボディモードでの分裂を処理します。
+// 分裂閾値に達すると膜の中央がくびれ始め、くびれきったところで膜を2つに分けます。
+func (c *Cell) updatePinch(dt float64, sim *Simulation) {
+	if !c.pinching {
+		if c.age >= c.divisionThreshold && len(sim.cells) < maxCells {
+			c.pinching = true
+			c.pinchTime = 0
+			c.pinchAngle = rand.Float64() * 2 * math.Pi
+		}
+		return
+	}
+
+	c.pinchTime += dt
+	if c.pinchTime < pinchDuration {
+		return
+	}
+
+	c.pinching = false
+	if len(sim.cells) >= maxCells {
+		// くびれている間に上限に達したら分裂を取りやめる
+		return
+	}
+
+	half1, half2 := splitMembrane(c.membrane, c.x, c.y, c.pinchAngle)
+	if half1 == nil || half2 == nil {
+		return
+	}
+
+	// 元の細胞は片側の膜を引き継ぐ（面積が半分になるので半径は約70%）
+	c.age = 0
+	c.r *= 0.7
+	c.divisionThreshold = randomInRange(divisionThresholdMin, divisionThresholdMax)
+	c.isShrinking = false
+	c.membrane = half1
+	c.x, c.y = c.centroid()
+
+	// もう片側の膜から新たな細胞を生成
+	newCell := c.newDaughter(polygonCentroid(half2))
+	newCell.membrane = half2
+	sim.cells = append(sim.cells, newCell)
+}
+
+// newMembrane は、中心 (x, y)・半径 r の円周上に等間隔に並んだ膜を生成します。
+func newMembrane(x, y, r float64) []MembranePoint {
+	membrane := make([]MembranePoint, membranePoints)
+	for i := range membrane {
+		angle := 2 * math.Pi * float64(i) / membranePoints
+		membrane[i] = MembranePoint{
+			x: x + math.Cos(angle)*r,
+			y: y + math.Sin(angle)*r,
+		}
+	}
+	return membrane
+}
+
+// translateMembrane は、膜全体を (dx, dy) だけ平行移動します。
+func (c *Cell) translateMembrane(dx, dy float64) {
+	for i := range c.membrane {
+		c.membrane[i].x += dx
+		c.membrane[i].y += dy
+	}
+}
+
+// centroid は、ソフトボディモードでは膜の重心を、それ以外では細胞の中心を返します。
+func (c *Cell) centroid() (float64, float64) {
+	if len(c.membrane) < 3 {
+		return c.x, c.y
+	}
+	return polygonCentroid(c.membrane)
+}
+
+// stepMembrane は、dt秒分だけ膜の変形を計算します。
+// 隣接点間のバネ・曲げ剛性・内圧・分裂時のくびれの力を積分し、画面端では膜を押しつぶします。
+func (c *Cell) stepMembrane(dt float64) {
+	if len(c.membrane) != membranePoints {
+		c.membrane = newMembrane(c.x, c.y, c.r)
+	}
+
+	n := len(c.membrane)
+	h := dt / membraneSubsteps
+	restLength := 2 * math.Pi * c.r / float64(n)
+	targetArea := math.Pi * c.r * c.r
+
+	// くびれの方向（娘細胞が並ぶ軸 u と、それに垂直な軸 v）
+	ux, uy := math.Cos(c.pinchAngle), math.Sin(c.pinchAngle)
+	vx, vy := -uy, ux
+	pinch := 0.0
+	if c.pinching {
+		pinch = math.Min(c.pinchTime/pinchDuration, 1)
+	}
+
+	fx := make([]float64, n)
+	fy := make([]float64, n)
+	for step := 0; step < membraneSubsteps; step++ {
+		for i := range fx {
+			fx[i], fy[i] = 0, 0
+		}
+
+		area := polygonArea(c.membrane)
+		// 面積が目標より小さいほど外向きに押す
+		pressure := membranePressure * (targetArea - area) / targetArea
+		cx, cy := polygonCentroid(c.membrane)
+
+		for i := 0; i < n; i++ {
+			a := c.membrane[i]
+			b := c.membrane[(i+1)%n]
+			ex, ey := b.x-a.x, b.y-a.y
+			length := math.Hypot(ex, ey)
+			if length < 0.0001 {
+				continue
+			}
+
+			// バネ：隣接点との距離を restLength に保つ
+			f := membraneSpring * (length - restLength) / length
+			fx[i] += ex * f
+			fy[i] += ey * f
+			fx[(i+1)%n] -= ex * f
+			fy[(i+1)%n] -= ey * f
+
+			// 内圧：辺の外向き法線方向に、辺の長さに比例した力を両端へ分配
+			px := ey * pressure * membraneSpring / 2
+			py := -ex * pressure * membraneSpring / 2
+			fx[i] += px
+			fy[i] += py
+			fx[(i+1)%n] += px
+			fy[(i+1)%n] += py
+		}
+
+		for i := 0; i < n; i++ {
+			prev := c.membrane[(i+n-1)%n]
+			next := c.membrane[(i+1)%n]
+			pt := &c.membrane[i]
+
+			// 曲げ剛性：両隣の中点へ引き寄せて滑らかにする
+			fx[i] += membraneBending * ((prev.x+next.x)/2 - pt.x)
+			fy[i] += membraneBending * ((prev.y+next.y)/2 - pt.y)
+
+			// くびれ：分裂軸に垂直な帯の上にある点を中心線へ引き寄せる
+			if pinch > 0 {
+				along := (pt.x-cx)*ux + (pt.y-cy)*uy
+				across := (pt.x-cx)*vx + (pt.y-cy)*vy
+				w := math.Exp(-math.Pow(along/(pinchBandWidth*c.r), 2))
+				fx[i] -= pinchStrength * pinch * w * across * vx
+				fy[i] -= pinchStrength * pinch * w * across * vy
+			}
+		}
+
+		for i := range c.membrane {
+			pt := &c.membrane[i]
+			pt.vx += (fx[i] - membraneDamping*pt.vx) * h
+			pt.vy += (fy[i] - membraneDamping*pt.vy) * h
+			pt.x += pt.vx * h
+			pt.y += pt.vy * h
+
+			// 画面端に当たった点はその場で押しつぶされる
+			if pt.x < 0 {
+				pt.x, pt.vx = 0, 0
+			} else if pt.x > canvasWidth {
+				pt.x, pt.vx = canvasWidth, 0
+			}
+			if pt.y < 0 {
+				pt.y, pt.vy = 0, 0
+			} else if pt.y > canvasHeight {
+				pt.y, pt.vy = canvasHeight, 0
+			}
+		}
+	}
+}
+
+// polygonArea は、膜が囲む面積を返します（点の並びが時計回りなら負になります）。
+func polygonArea(points []MembranePoint) float64 {
+	area := 0.0
+	n := len(points)
+	for i := 0; i < n; i++ {
+		a := points[i]
+		b := points[(i+1)%n]
+		area += a.x*b.y - b.x*a.y
+	}
+	return area / 2
+}
+
+// polygonCentroid は、膜の点の平均位置を返します。
+func polygonCentroid(points []MembranePoint) (float64, float64) {
+	var sx, sy float64
+	for _, pt := range points {
+		sx += pt.x
+		sy += pt.y
+	}
+	n := float64(len(points))
+	return sx / n, sy / n
+}
+
+// splitMembrane は、くびれた膜を (cx, cy) を通り angle 方向に垂直な線で2つに分け、
+// それぞれを membranePoints 個の点で組み直して返します。どちらかが空なら nil を返します。
+func splitMembrane(membrane []MembranePoint, cx, cy, angle float64) ([]MembranePoint, []MembranePoint) {
+	ux, uy := math.Cos(angle), math.Sin(angle)
+	n := len(membrane)
+	side := func(i int) bool {
+		pt := membrane[(i+n)%n]
+		return (pt.x-cx)*ux+(pt.y-cy)*uy >= 0
+	}
+
+	// 片側から反対側へ切り替わる位置を起点に、輪を順にたどる
+	start := -1
+	for i := 0; i < n; i++ {
+		if side(i) && !side(i-1) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, nil
+	}
+
+	var half1, half2 []MembranePoint
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if side(i) {
+			half1 = append(half1, membrane[i])
+		} else {
+			half2 = append(half2, membrane[i])
+		}
+	}
+	if len(half1) < 3 || len(half2) < 3 {
+		return nil, nil
+	}
+	return resampleMembrane(half1, membranePoints), resampleMembrane(half2, membranePoints)
+}
+
+// resampleMembrane は、閉じた多角形の周上に等間隔で count 個の点を並べ直します。
+// 点の並びは面積が正になる向きにそろえます。
+func resampleMembrane(points []MembranePoint, count int) []MembranePoint {
+	n := len(points)
+	cumulative := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		a := points[i]
+		b := points[(i+1)%n]
+		cumulative[i+1] = cumulative[i] + math.Hypot(b.x-a.x, b.y-a.y)
+	}
+	perimeter := cumulative[n]
+
+	result := make([]MembranePoint, count)
+	seg := 0
+	for k := 0; k < count; k++ {
+		d := perimeter * float64(k) / float64(count)
+		for seg < n-1 && cumulative[seg+1] < d {
+			seg++
+		}
+		a := points[seg]
+		b := points[(seg+1)%n]
+		t := 0.0
+		if l := cumulative[seg+1] - cumulative[seg]; l > 0 {
+			t = (d - cumulative[seg]) / l
+		}
+		result[k] = MembranePoint{
+			x: a.x + (b.x-a.x)*t,
+			y: a.y + (b.y-a.y)*t,
+		}
+	}
+
+	if polygonArea(result) < 0 {
+		for i, j := 0, count-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result
+}
+
 // Simulation はシミュレーション全体の状態を管理します。
 type Simulation struct {
 	cells []Cell
@@ -227,6 +547,65 @@ func (sim *Simulation) Update(dt float64) {
 	for i := 0; i < n; i++ {
 		sim.cells[i].Update(dt, sim)
 	}
+
+	// 3. ソフトボディモードでは、接触している膜同士を押し合わせて変形させる
+	if softBodyMode {
+		sim.resolveMembraneContacts()
+	}
+}
+
+// resolveMembraneContacts は、重なった細胞の膜を2つの細胞の間の境界線まで押し戻します。
+// 境界線は半径の比で中心間を分ける位置に置き、押し合った膜は平らにつぶれます。
+func (sim *Simulation) resolveMembraneContacts() {
+	n := len(sim.cells)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			a := &sim.cells[i]
+			b := &sim.cells[j]
+			dx := b.x - a.x
+			dy := b.y - a.y
+			d := math.Hypot(dx, dy)
+			if d < 0.001 || d >= a.r+b.r {
+				continue
+			}
+			ux, uy := dx/d, dy/d
+			boundary := d * a.r / (a.r + b.r)
+			clampMembrane(a.membrane, a.x, a.y, ux, uy, boundary)
+			clampMembrane(b.membrane, b.x, b.y, -ux, -uy, d-boundary)
+		}
+	}
+}
+
+// clampMembrane は、中心 (cx, cy) から (ux, uy) 方向に limit を超えた膜の点を境界線上に戻します。
+func clampMembrane(membrane []MembranePoint, cx, cy, ux, uy, limit float64) {
+	for i := range membrane {
+		pt := &membrane[i]
+		over := (pt.x-cx)*ux + (pt.y-cy)*uy - limit
+		if over <= 0 {
+			continue
+		}
+		pt.x -= ux * over
+		pt.y -= uy * over
+		// 境界に向かう速度成分を打ち消す
+		if v := pt.vx*ux + pt.vy*uy; v > 0 {
+			pt.vx -= ux * v
+			pt.vy -= uy * v
+		}
+	}
+}
+
+// enableSoftBody は、ソフトボディモードを切り替え、必要なら各細胞の膜を現在の円から作り直します。
+func (sim *Simulation) enableSoftBody(enabled bool) {
+	softBodyMode = enabled
+	for i := range sim.cells {
+		c := &sim.cells[i]
+		c.pinching = false
+		if enabled {
+			c.membrane = newMembrane(c.x, c.y, c.r)
+		} else {
+			c.membrane = nil
+		}
+	}
 }
 
 // Draw は、各細胞をキャンバスに描画します。
@@ -234,10 +613,38 @@ func (sim *Simulation) Draw(p *p5go.Canvas) {
 	for _, cell := range sim.cells {
 		p.Fill(float64(cell.color[0]), float64(cell.color[1]), float64(cell.color[2]), 200)
 		p.NoStroke()
+		if softBodyMode && len(cell.membrane) > 0 {
+			drawMembrane(p, cell)
+			continue
+		}
 		p.Ellipse(cell.x, cell.y, cell.r*2, cell.r*2)
 	}
 }
 
+// drawMembrane は、細胞膜の輪郭と核を描画します。
+func drawMembrane(p *p5go.Canvas, cell Cell) {
+	p.BeginShape()
+	for _, pt := range cell.membrane {
+		p.Vertex(pt.x, pt.y)
+	}
+	p.EndShape(p5go.CLOSE)
+
+	// 膜の縁取り
+	p.NoFill()
+	p.Stroke(float64(cell.color[0]), float64(cell.color[1]), float64(cell.color[2]), 255)
+	p.StrokeWeight(1.5)
+	p.BeginShape()
+	for _, pt := range cell.membrane {
+		p.Vertex(pt.x, pt.y)
+	}
+	p.EndShape(p5go.CLOSE)
+	p.NoStroke()
+
+	// 核
+	p.Fill(float64(cell.color[0])*0.6, float64(cell.color[1])*0.6, float64(cell.color[2])*0.6, 220)
+	p.Ellipse(cell.x, cell.y, cell.r*0.5, cell.r*0.5)
+}
+
 // randomInRange は、min以上max未満のランダムな値を返します。
 func randomInRange(min, max float64) float64 {
 	return min + rand.Float64()*(max-min)
@@ -256,6 +663,10 @@ func main() {
 			p.Background(0)
 			sim.Draw(p)
 		}),
+		p5go.MousePressed(func(p *p5go.Canvas) {
+			// クリックで円モードとソフトボディモードを切り替え
+			sim.enableSoftBody(!softBodyMode)
+		}),
 	)
 	select {}
 }
@@ -1,139 +1,584 @@
 package main
 
 import (
+	"fmt"
 	"math"
+	"math/rand"
 	"syscall/js"
 
 	"github.com/ryomak/p5go"
+	"github.com/ryomak/sketch/art/internal/motion"
 )
 
 var (
-	video                      js.Value // Webカメラ映像のグローバル変数
-	prevPixels                 js.Value // 前フレームのピクセルデータ
-	pointerX, pointerY         float64  // 重み付き平均から得た動体の位置
-	smoothX, smoothY           float64  // EMA による滑らかな位置
-	prevPointerX, prevPointerY float64  // 前フレームの滑らか位置（波発生用）
-	waves                      []Wave   // 発生中の波エフェクト
+	tracker    *motion.Tracker // 動体の検出と追跡
+	showMask   bool            // true のとき前景マスクをデバッグ表示する（クリックで切り替え）
+	ripples    *RippleField    // 水面の高さ場
+	rippleView *rippleRenderer
+
+	curGray, prevGray []float64      // 縮小したグレースケール画像（オプティカルフロー用）
+	hasPrevGray       bool           // prevGray に有効なデータが入っているか
+	flow              FlowField      // セルごとの動きベクトル
+	flowParticles     []FlowParticle // 動きに流されるパーティクル
+
+	gestures    *motion.GestureRecognizer // 最も大きな動体の軌跡からジェスチャーを認識する
+	lastGesture motion.Gesture            // 直前に認識したジェスチャー（画面に表示する）
+	gestureTime float64                   // lastGesture を認識した時刻（秒）
 )
 
 const (
-	cellSize          = 30    // セル単位のサイズ（ピクセル）
-	threshold         = 100.0 // 差分とみなす閾値
-	movementThreshold = 100.0 // 前フレームとの位置差がこれを超えたら波を発生
-	smoothingFactor   = 0.3   // EMA の係数（0～1、値が大きいほど素早く追従）
+	// 水面（波動方程式）のパラメータ
+	rippleCols          = 100   // 水面の格子の列数
+	rippleRows          = 100   // 水面の格子の行数
+	rippleDamping       = 0.985 // 1ステップごとの減衰率
+	rippleDisturbRadius = 2.0   // 動体が水面を押す範囲（格子の半径）
+	rippleDisturbGain   = 6.0   // 動体の速さに対する水面を押す強さ
+	rippleMaxDisturb    = 200.0 // 1フレームで押す強さの上限
+	rippleHighlight     = 1.5   // 傾きに対するハイライトの強さ
+	rippleRefraction    = 0.08  // 傾きに対する映像のずれ（格子単位）
+	rippleShowVideo     = true  // true なら水越しに反転した映像を映す
+
+	// オプティカルフロー（Lucas-Kanade 法）のパラメータ
+	flowDownsample    = 4     // グレースケール化するときの縮小率
+	flowCols          = 20    // フローを求めるグリッドの列数
+	flowRows          = 20    // フローを求めるグリッドの行数
+	flowMinEigen      = 500.0 // 模様が少なく信頼できないセルを除外する固有値の下限
+	flowMaxSpeed      = 40.0  // 1フレームあたりの速度の上限（キャンバス座標）
+	flowArrowMin      = 1.5   // この速さ未満のセルには矢印を描かない
+	flowArrowScale    = 3.0   // 矢印の長さの倍率
+	flowParticleCount = 300   // パーティクルの数
+	flowParticleGain  = 0.4   // フローがパーティクルを押す強さ
+	flowParticleDrag  = 0.9   // パーティクルの速度の減衰率
+
+	// ジェスチャーのパラメータ
+	gestureLabelDuration = 1.5  // 認識したジェスチャー名を表示する秒数
+	gestureSwipeImpulse  = 12.0 // スワイプでパーティクルに与える速さ
 )
 
-// Wave は波エフェクトを表します
-type Wave struct {
-	x, y      float64 // 発生位置
-	radius    float64 // 現在の半径
-	alpha     float64 // 描画時の透明度（0～255）
-	maxRadius float64 // 最大半径（例: 200）
+// FlowField はグリッド状に並んだセルごとの速度ベクトルを表します
+type FlowField struct {
+	cols, rows int
+	vx, vy     []float64 // キャンバス座標での1フレームあたりの移動量
+}
+
+// FlowParticle はフローに流されるパーティクルを表します
+type FlowParticle struct {
+	x, y   float64
+	vx, vy float64
+}
+
+// RippleField は、減衰つきの2次元波動方程式で水面の高さを計算する格子です。
+// 画面端では波が反射し、複数の波は重なり合って干渉します。
+type RippleField struct {
+	cols, rows int
+	cur, prev  []float64 // 現在と1ステップ前の高さ
+}
+
+// NewRippleField は、cols × rows の静かな水面を生成します。
+func NewRippleField(cols, rows int) *RippleField {
+	return &RippleField{
+		cols: cols,
+		rows: rows,
+		cur:  make([]float64, cols*rows),
+		prev: make([]float64, cols*rows),
+	}
+}
+
+// Disturb は、格子座標 (x, y) を中心に半径 radius のなだらかなくぼみを strength の深さで加えます。
+func (f *RippleField) Disturb(x, y, radius, strength float64) {
+	c0 := max(int(x-radius*2), 0)
+	c1 := min(int(x+radius*2), f.cols-1)
+	r0 := max(int(y-radius*2), 0)
+	r1 := min(int(y+radius*2), f.rows-1)
+	for row := r0; row <= r1; row++ {
+		for col := c0; col <= c1; col++ {
+			dx := float64(col) - x
+			dy := float64(row) - y
+			f.cur[row*f.cols+col] -= strength * math.Exp(-(dx*dx+dy*dy)/(radius*radius))
+		}
+	}
+}
+
+// Step は、波動方程式を1ステップ進めます。
+// 格子の外側は端の値を鏡写しにする（ノイマン境界）ので、波は壁で反射します。
+func (f *RippleField) Step() {
+	cols, rows := f.cols, f.rows
+	for row := 0; row < rows; row++ {
+		up := max(row-1, 0) * cols
+		down := min(row+1, rows-1) * cols
+		for col := 0; col < cols; col++ {
+			left := max(col-1, 0)
+			right := min(col+1, cols-1)
+			i := row*cols + col
+			neighbours := f.cur[row*cols+left] + f.cur[row*cols+right] + f.cur[up+col] + f.cur[down+col]
+			// prev を次の高さで上書きしてから入れ替える
+			f.prev[i] = (neighbours/2 - f.prev[i]) * rippleDamping
+		}
+	}
+	f.cur, f.prev = f.prev, f.cur
+}
+
+// Slope は、格子点 (col, row) での水面の傾きを返します。
+func (f *RippleField) Slope(col, row int) (float64, float64) {
+	left := max(col-1, 0)
+	right := min(col+1, f.cols-1)
+	up := max(row-1, 0)
+	down := min(row+1, f.rows-1)
+	sx := f.cur[row*f.cols+right] - f.cur[row*f.cols+left]
+	sy := f.cur[down*f.cols+col] - f.cur[up*f.cols+col]
+	return sx, sy
+}
+
+// rippleRenderer は、水面を格子と同じ解像度の画像に描いてからキャンバスへ拡大表示します。
+// 格子1マスごとに Rect を呼ぶ代わりに、ピクセルをまとめて JS 側へ転送します。
+type rippleRenderer struct {
+	pixels    []byte
+	canvas    js.Value // 格子と同じ大きさの作業用 canvas 要素
+	context   js.Value
+	imageData js.Value
+	target    js.Value // p5 が描画している canvas の 2D コンテキスト
+}
+
+// newRippleRenderer は、cols × rows の作業用 canvas を用意します。
+func newRippleRenderer(cols, rows int) *rippleRenderer {
+	document := js.Global().Get("document")
+	canvas := document.Call("createElement", "canvas")
+	canvas.Set("width", cols)
+	canvas.Set("height", rows)
+	context := canvas.Call("getContext", "2d")
+	return &rippleRenderer{
+		pixels:    make([]byte, cols*rows*4),
+		canvas:    canvas,
+		context:   context,
+		imageData: context.Call("createImageData", cols, rows),
+		target:    document.Call("querySelector", "#canvas-detail canvas").Call("getContext", "2d"),
+	}
+}
+
+// render は、水面の傾きから陰影をつけて描画します。
+// frame が与えられていれば、左右反転した映像を傾きに応じてずらして映し、水越しに見える像にします。
+func (r *rippleRenderer) render(f *RippleField, frame []byte, frameW, frameH int, width, height float64) {
+	for row := 0; row < f.rows; row++ {
+		for col := 0; col < f.cols; col++ {
+			sx, sy := f.Slope(col, row)
+			// 左上から光が当たっているとみなしたハイライト
+			light := math.Max(0, -(sx+sy)*rippleHighlight)
+
+			var red, green, blue float64
+			if frame != nil {
+				// 傾きの分だけ屈折させた位置の映像を取る（左右反転で鏡のように）
+				u := (float64(col) + 0.5 + sx*rippleRefraction) / float64(f.cols)
+				v := (float64(row) + 0.5 + sy*rippleRefraction) / float64(f.rows)
+				fx := min(max(int((1-u)*float64(frameW)), 0), frameW-1)
+				fy := min(max(int(v*float64(frameH)), 0), frameH-1)
+				index := (fy*frameW + fx) * 4
+				red = float64(frame[index]) * 0.6
+				green = float64(frame[index+1]) * 0.7
+				blue = float64(frame[index+2])*0.7 + 40
+			} else {
+				// 映像がなければ深い水の色
+				h := f.cur[row*f.cols+col]
+				red = 10 + h*0.5
+				green = 50 + h
+				blue = 100 + h*2
+			}
+
+			i := (row*f.cols + col) * 4
+			r.pixels[i] = clampByte(red + light)
+			r.pixels[i+1] = clampByte(green + light)
+			r.pixels[i+2] = clampByte(blue + light)
+			r.pixels[i+3] = 255
+		}
+	}
+
+	js.CopyBytesToJS(r.imageData.Get("data"), r.pixels)
+	r.context.Call("putImageData", r.imageData, 0, 0)
+	r.target.Set("imageSmoothingEnabled", true)
+	r.target.Call("drawImage", r.canvas, 0, 0, width, height)
+}
+
+// clampByte は、v を 0～255 に収めて返します。
+func clampByte(v float64) byte {
+	return byte(math.Max(0, math.Min(255, v)))
 }
 
 func setup(c *p5go.Canvas) {
 	// キャンバスサイズ 500x400
 	c.CreateCanvas(400, 400)
 
-	// Webカメラ映像の取得
-	video = c.CreateCapture("VIDEO")
-	video.Call("hide") // video要素自体は非表示
+	// 映像の入力元（既定は Webカメラ）から動体を追跡する
+	// 水面に映す映像と同じく左右反転して、カメラに向かって動いた側に波が立つようにする
+	config := motion.DefaultConfig()
+	config.Mirror = true
+	tracker = motion.NewTracker(motion.SourceFromURL(c), config)
+
+	// FPS を 30 に設定
+	c.FrameRate(30)
 
-	// FPS を 7 に設定
-	c.FrameRate(7)
+	ripples = NewRippleField(rippleCols, rippleRows)
+	rippleView = newRippleRenderer(rippleCols, rippleRows)
+
+	gestures = motion.NewGestureRecognizer(motion.DefaultGestureConfig(), motion.DefaultGestureTemplates())
+
+	scatterFlowParticles(c.Width(), c.Height())
 }
 
-func draw(c *p5go.Canvas) {
-	// video のピクセルデータ更新
-	video.Call("loadPixels")
-	curPixels := video.Get("pixels")
-	c.Background(0)
+// scatterFlowParticles は、パーティクルをキャンバス全体にばらまきます。
+func scatterFlowParticles(width, height float64) {
+	flowParticles = make([]FlowParticle, flowParticleCount)
+	for i := range flowParticles {
+		flowParticles[i] = FlowParticle{
+			x: rand.Float64() * width,
+			y: rand.Float64() * height,
+		}
+	}
+}
 
-	width := int(c.Width())
-	height := int(c.Height())
-
-	var sumWeight, sumX, sumY float64
-
-	// 前フレームとの差分から動いている部分の重み付き平均位置を算出
-	if !prevPixels.IsUndefined() {
-		for y := 0; y < height; y += cellSize {
-			for x := 0; x < width; x += cellSize {
-				index := (y*width + x) * 4
-				rDiff := math.Abs(curPixels.Index(index).Float() - prevPixels.Index(index).Float())
-				gDiff := math.Abs(curPixels.Index(index+1).Float() - prevPixels.Index(index+1).Float())
-				bDiff := math.Abs(curPixels.Index(index+2).Float() - prevPixels.Index(index+2).Float())
-				diff := rDiff + gDiff + bDiff
-
-				if diff > threshold {
-					sumX += float64(x) * diff
-					sumY += float64(y) * diff
-					sumWeight += diff
+// applyGesture は、認識したジェスチャーに応じてパーティクルを動かします。
+// スワイプはその向きに吹き飛ばし、円はばらまき直します。
+func applyGesture(gesture motion.Gesture, width, height float64) {
+	var dx, dy float64
+	switch gesture {
+	case motion.GestureSwipeLeft:
+		dx = -1
+	case motion.GestureSwipeRight:
+		dx = 1
+	case motion.GestureSwipeUp:
+		dy = -1
+	case motion.GestureSwipeDown:
+		dy = 1
+	case motion.GestureCircle:
+		scatterFlowParticles(width, height)
+		return
+	default:
+		return
+	}
+	for i := range flowParticles {
+		flowParticles[i].vx += dx * gestureSwipeImpulse
+		flowParticles[i].vy += dy * gestureSwipeImpulse
+	}
+}
+
+// trackColor は、動体の ID ごとに見分けやすい色を返します。
+func trackColor(id int) [3]float64 {
+	colors := [][3]float64{
+		{255, 80, 80},
+		{80, 160, 255},
+		{255, 220, 60},
+		{120, 255, 120},
+		{255, 120, 255},
+		{80, 255, 255},
+	}
+	return colors[(id-1)%len(colors)]
+}
+
+// toGray は、RGBA の映像を factor 分の1に縮小したグレースケール画像に変換して dst に書き込みます。
+// 縮小後の幅と高さを返します。
+func toGray(dst []float64, frame []byte, width, height, factor int) ([]float64, int, int) {
+	gw := width / factor
+	gh := height / factor
+	if cap(dst) < gw*gh {
+		dst = make([]float64, gw*gh)
+	}
+	dst = dst[:gw*gh]
+
+	norm := 1.0 / float64(factor*factor)
+	for gy := 0; gy < gh; gy++ {
+		for gx := 0; gx < gw; gx++ {
+			sum := 0.0
+			for y := gy * factor; y < (gy+1)*factor; y++ {
+				index := (y*width + gx*factor) * 4
+				for x := 0; x < factor; x++ {
+					// ITU-R BT.601 の輝度
+					sum += 0.299*float64(frame[index]) + 0.587*float64(frame[index+1]) + 0.114*float64(frame[index+2])
+					index += 4
 				}
 			}
+			dst[gy*gw+gx] = sum * norm
 		}
 	}
+	return dst, gw, gh
+}
 
-	// もし動いている部分があれば、重み付き平均位置を算出
-	if sumWeight > 0 {
-		pointerX = sumX / sumWeight
-		pointerY = sumY / sumWeight
+// computeFlow は、Lucas-Kanade 法で prev から cur への動きをグリッドのセルごとに推定します。
+// 各セルの窓内で輝度勾配の連立方程式を最小二乗で解き、縮小画像のピクセル単位で速度を求めます。
+// 模様が少なく解が安定しないセルの速度は 0 になります。
+func computeFlow(field *FlowField, prev, cur []float64, width, height, cols, rows int) {
+	field.cols = cols
+	field.rows = rows
+	if len(field.vx) != cols*rows {
+		field.vx = make([]float64, cols*rows)
+		field.vy = make([]float64, cols*rows)
+	}
+
+	cellW := width / cols
+	cellH := height / rows
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			var sxx, sxy, syy, sxt, syt float64
+			// 中央差分が取れるよう画像の縁は1ピクセル除外する
+			x0 := max(col*cellW, 1)
+			x1 := min((col+1)*cellW, width-1)
+			y0 := max(row*cellH, 1)
+			y1 := min((row+1)*cellH, height-1)
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := y*width + x
+					ix := (prev[i+1] - prev[i-1]) / 2
+					iy := (prev[i+width] - prev[i-width]) / 2
+					it := cur[i] - prev[i]
+					sxx += ix * ix
+					sxy += ix * iy
+					syy += iy * iy
+					sxt += ix * it
+					syt += iy * it
+				}
+			}
 
-		// 初回の場合は EMA の初期値として設定
-		if smoothX == 0 && smoothY == 0 {
-			smoothX = pointerX
-			smoothY = pointerY
-		} else {
-			// EMA を用いて滑らかに更新
-			smoothX = (1-smoothingFactor)*smoothX + smoothingFactor*pointerX
-			smoothY = (1-smoothingFactor)*smoothY + smoothingFactor*pointerY
+			// 2x2 行列の小さい方の固有値が十分大きいときだけ解く
+			trace := sxx + syy
+			det := sxx*syy - sxy*sxy
+			minEigen := trace/2 - math.Sqrt(math.Max(trace*trace/4-det, 0))
+			index := row*cols + col
+			if minEigen < flowMinEigen {
+				field.vx[index] = 0
+				field.vy[index] = 0
+				continue
+			}
+			field.vx[index] = (-syy*sxt + sxy*syt) / det
+			field.vy[index] = (sxy*sxt - sxx*syt) / det
 		}
-	} // 動きがなければ前回値を維持
-
-	// 前フレームとの位置差があれば、波エフェクトを発生させる
-	dx := smoothX - prevPointerX
-	dy := smoothY - prevPointerY
-	dist := math.Sqrt(dx*dx + dy*dy)
-	if dist > movementThreshold {
-		newWave := Wave{
-			x:         smoothX,
-			y:         smoothY,
-			radius:    10,
-			alpha:     255,
-			maxRadius: 200,
+	}
+}
+
+// scale は、フィールドのすべての速度に sx, sy を掛け、速さを maxSpeed で頭打ちにします。
+func (f *FlowField) scale(sx, sy, maxSpeed float64) {
+	for i := range f.vx {
+		f.vx[i] *= sx
+		f.vy[i] *= sy
+		if speed := math.Hypot(f.vx[i], f.vy[i]); speed > maxSpeed {
+			f.vx[i] *= maxSpeed / speed
+			f.vy[i] *= maxSpeed / speed
 		}
-		waves = append(waves, newWave)
-	}
-	// 更新前の滑らかな位置を保存
-	prevPointerX = smoothX
-	prevPointerY = smoothY
-
-	// 現在のピクセルデータを次回用に保存
-	prevPixels = curPixels
-
-	// 波エフェクトの更新＆描画
-	newWaves := []Wave{}
-	for _, wave := range waves {
-		wave.radius += 10 // 拡大速度
-		wave.alpha -= 25  // 消失速度
-		if wave.alpha > 0 && wave.radius < wave.maxRadius {
-			newWaves = append(newWaves, wave)
+	}
+}
+
+// mirror は、フィールドを左右反転します（セルの並びを入れ替え、横向きの速度の符号を反転）。
+func (f *FlowField) mirror() {
+	for row := 0; row < f.rows; row++ {
+		vx := f.vx[row*f.cols : (row+1)*f.cols]
+		vy := f.vy[row*f.cols : (row+1)*f.cols]
+		for l, r := 0, f.cols-1; l <= r; l, r = l+1, r-1 {
+			vx[l], vx[r] = -vx[r], -vx[l]
+			vy[l], vy[r] = vy[r], vy[l]
+		}
+	}
+}
+
+// sample は、キャンバス座標 (x, y) での速度をセル間で双線形補間して返します。
+func (f *FlowField) sample(x, y, width, height float64) (float64, float64) {
+	if f.cols == 0 || f.rows == 0 || len(f.vx) == 0 {
+		return 0, 0
+	}
+	// セルの中心を格子点とみなす
+	gx := x/width*float64(f.cols) - 0.5
+	gy := y/height*float64(f.rows) - 0.5
+	gx = math.Max(0, math.Min(gx, float64(f.cols-1)))
+	gy = math.Max(0, math.Min(gy, float64(f.rows-1)))
+	c0 := int(gx)
+	r0 := int(gy)
+	c1 := min(c0+1, f.cols-1)
+	r1 := min(r0+1, f.rows-1)
+	tx := gx - float64(c0)
+	ty := gy - float64(r0)
+
+	lerp := func(v []float64) float64 {
+		top := v[r0*f.cols+c0]*(1-tx) + v[r0*f.cols+c1]*tx
+		bottom := v[r1*f.cols+c0]*(1-tx) + v[r1*f.cols+c1]*tx
+		return top*(1-ty) + bottom*ty
+	}
+	return lerp(f.vx), lerp(f.vy)
+}
+
+// updateFlowParticles は、フローに沿ってパーティクルを動かし、画面外に出たものは反対側に戻します。
+func updateFlowParticles(width, height float64) {
+	for i := range flowParticles {
+		pt := &flowParticles[i]
+		fx, fy := flow.sample(pt.x, pt.y, width, height)
+		pt.vx = pt.vx*flowParticleDrag + fx*flowParticleGain
+		pt.vy = pt.vy*flowParticleDrag + fy*flowParticleGain
+		pt.x += pt.vx
+		pt.y += pt.vy
+
+		if pt.x < 0 {
+			pt.x += width
+		} else if pt.x >= width {
+			pt.x -= width
+		}
+		if pt.y < 0 {
+			pt.y += height
+		} else if pt.y >= height {
+			pt.y -= height
+		}
+	}
+}
+
+// drawFlow は、セルごとの動きを矢印で、パーティクルを速さに応じた明るさの点で描画します。
+func drawFlow(c *p5go.Canvas, width, height float64) {
+	cellW := width / float64(flow.cols)
+	cellH := height / float64(flow.rows)
+	c.StrokeWeight(1)
+	for row := 0; row < flow.rows; row++ {
+		for col := 0; col < flow.cols; col++ {
+			index := row*flow.cols + col
+			vx, vy := flow.vx[index], flow.vy[index]
+			speed := math.Hypot(vx, vy)
+			if speed < flowArrowMin {
+				continue
+			}
+			x := (float64(col) + 0.5) * cellW
+			y := (float64(row) + 0.5) * cellH
+			ex := x + vx*flowArrowScale
+			ey := y + vy*flowArrowScale
+			c.Stroke(0, 255, 150, math.Min(255, speed*20))
+			c.Line(x, y, ex, ey)
+			// 矢じり
+			angle := math.Atan2(vy, vx)
+			c.Line(ex, ey, ex-math.Cos(angle-0.5)*4, ey-math.Sin(angle-0.5)*4)
+			c.Line(ex, ey, ex-math.Cos(angle+0.5)*4, ey-math.Sin(angle+0.5)*4)
 		}
-		c.NoFill()
-		c.Stroke(255, 0, 0, wave.alpha)
-		c.Ellipse(wave.x, wave.y, wave.radius, wave.radius)
 	}
-	waves = newWaves
 
-	// 滑らかに更新された動体の位置に小さな青い円を描画（ポインタ）
 	c.NoStroke()
-	c.Fill(0, 0, 255)
-	c.Ellipse(smoothX, smoothY, 20, 20)
+	for _, pt := range flowParticles {
+		brightness := math.Min(255, 80+math.Hypot(pt.vx, pt.vy)*30)
+		c.Fill(brightness, brightness, 255, 200)
+		c.Ellipse(pt.x, pt.y, 3, 3)
+	}
+}
+
+// drawMask は、動きありと判定されたセルを半透明の白い四角で描画します。
+// マスクは映像と同じ向きなので、mirror なら左右反転して描きます。
+func drawMask(c *p5go.Canvas, mask []float64, cols, rows int, width, height float64, mirror bool) {
+	cellW := width / float64(cols)
+	cellH := height / float64(rows)
+	c.NoStroke()
+	c.Fill(255, 255, 255, 90)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if mask[row*cols+col] > 0 {
+				x := col
+				if mirror {
+					x = cols - 1 - col
+				}
+				c.Rect(float64(x)*cellW, float64(row)*cellH, cellW, cellH)
+			}
+		}
+	}
+}
+
+func draw(c *p5go.Canvas) {
+	c.Background(0)
+
+	width := c.Width()
+	height := c.Height()
+
+	// 前景から動体を検出し、フレーム間で追跡する
+	frameRead := tracker.Update(width, height)
+	frame, frameWidth, frameHeight, hasFrame := tracker.Frame()
+	if frameRead {
+		var gw, gh int
+		curGray, gw, gh = toGray(curGray, frame, frameWidth, frameHeight, flowDownsample)
+		if hasPrevGray && len(prevGray) == len(curGray) {
+			computeFlow(&flow, prevGray, curGray, gw, gh, flowCols, flowRows)
+			// 縮小画像のピクセル単位からキャンバス座標に変換
+			flow.scale(
+				float64(flowDownsample)*width/float64(frameWidth),
+				float64(flowDownsample)*height/float64(frameHeight),
+				flowMaxSpeed,
+			)
+			if tracker.Config().Mirror {
+				flow.mirror()
+			}
+		}
+		// 次回用に入れ替えて再利用
+		curGray, prevGray = prevGray, curGray
+		hasPrevGray = true
+	}
+
+	// 動体の速さに応じて、その位置の水面を押し下げる
+	// （ポインタは EMA で追従するので、1フレームの移動量は速度に係数を掛けたものになる）
+	config := tracker.Config()
+	tracks := tracker.Tracks()
+	for _, t := range tracks {
+		speed := math.Hypot(t.VX, t.VY) * config.Smoothing
+		ripples.Disturb(
+			t.SmoothX/width*rippleCols,
+			t.SmoothY/height*rippleRows,
+			rippleDisturbRadius,
+			math.Min(speed*rippleDisturbGain, rippleMaxDisturb),
+		)
+	}
+
+	// 最も大きな動体の滑らかな軌跡からジェスチャーを認識する
+	now := motion.Now()
+	if primary, ok := tracker.Primary(); ok {
+		if gesture, ok := gestures.Add(primary.SmoothX, primary.SmoothY, now); ok {
+			lastGesture, gestureTime = gesture, now
+			applyGesture(gesture, width, height)
+		}
+	} else {
+		gestures.Reset()
+	}
+
+	// 水面を進めて描画（現フレームがあれば水越しの映像として映す）
+	ripples.Step()
+	var videoFrame []byte
+	if rippleShowVideo && hasFrame {
+		videoFrame = frame
+	}
+	rippleView.render(ripples, videoFrame, frameWidth, frameHeight, width, height)
+
+	// デバッグ用に前景マスクを表示
+	if mask, maskCols, maskRows := tracker.Mask(); showMask && mask != nil {
+		drawMask(c, mask, maskCols, maskRows, width, height, config.Mirror)
+	}
+
+	// フローに沿ってパーティクルを流し、フローの矢印とともに描画
+	updateFlowParticles(width, height)
+	drawFlow(c, width, height)
+
+	// 動体ごとに滑らかに更新された位置へ円と ID を描画（ポインタ）
+	c.NoStroke()
+	for _, t := range tracks {
+		col := trackColor(t.ID)
+		// 見失っている間は薄く表示
+		alpha := 255 * (1 - float64(t.Missed)/float64(config.MaxMissedFrames+1))
+		size := math.Max(12, math.Min(40, math.Sqrt(t.Area)/2))
+		c.Fill(col[0], col[1], col[2], alpha)
+		c.Ellipse(t.SmoothX, t.SmoothY, size, size)
+		c.TextSize(12)
+		c.Text(fmt.Sprintf("#%d", t.ID), t.SmoothX+size/2+2, t.SmoothY-size/2)
+	}
+
+	// 認識したジェスチャー名をしばらく表示
+	if elapsed := now - gestureTime; lastGesture != motion.GestureNone && elapsed < gestureLabelDuration {
+		c.Fill(255, 255, 255, 255*(1-elapsed/gestureLabelDuration))
+		c.TextSize(20)
+		c.Text(string(lastGesture), 10, 30)
+	}
+}
+
+func mousePressed(c *p5go.Canvas) {
+	// クリックで前景マスクの表示を切り替え
+	showMask = !showMask
 }
 
 func main() {
 	p5go.Run("#canvas-detail",
 		p5go.Setup(setup),
 		p5go.Draw(draw),
+		p5go.MousePressed(mousePressed),
 	)
 	select {}
 }
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"math"
 	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall/js"
 	"time"
 
 	"github.com/ryomak/p5go"
 )
 
 const (
-	CANVAS_WIDTH        = 400
+	CANVAS_WIDTH        = 400 // 基準のキャンバスサイズ（この大きさで街のドットの大きさを決める）
 	CANVAS_HEIGHT       = 400
-	BASE_Y              = CANVAS_HEIGHT * 1
 	BUILDING_BASE_WIDTH = 80
 	STAR_COUNT          = 50
 	SPRITE_SCALE        = 5 // 建物スプライトは16×16で拡大
@@ -23,28 +27,32 @@ const (
 // 1: 壁色（建物と共通）
 // 2: 窓色
 // 3: 屋根色
+// 4: アンテナ・手すり
+// 5: 看板
 // 6: 月用の色
+// 7: 太陽用の色
 var palette = map[int][3]int{
-	1: {70, 70, 90},              // 壁
-	2: {255, 220, 100},           // 窓
-	3: generateRandomRoofColor(), // 屋根
-	6: {250, 250, 200},           // 月
+	1: {70, 70, 90},    // 壁
+	2: {255, 220, 100}, // 窓
+	3: {150, 80, 60},   // 屋根（setup で乱数の種から決め直す）
+	4: {120, 120, 135}, // アンテナ・手すり
+	5: {255, 90, 150},  // 看板
+	6: {250, 250, 200}, // 月
+	7: {255, 200, 90},  // 太陽
 }
 
 // 屋根色のランダム生成関数
-func generateRandomRoofColor() [3]int {
-	rand.Seed(time.Now().UnixNano())
-
+func generateRandomRoofColor(rng *rand.Rand) [3]int {
 	// 値をランダムに生成
-	r := rand.Intn(141) + 50 // 50～190の範囲
-	g := rand.Intn(141) + 50
-	b := rand.Intn(141) + 50
+	r := rng.Intn(141) + 50 // 50～190の範囲
+	g := rng.Intn(141) + 50
+	b := rng.Intn(141) + 50
 
 	// 条件を満たすまで再生成
 	for !isValidColor(r, g, b) {
-		r = rand.Intn(141) + 50
-		g = rand.Intn(141) + 50
-		b = rand.Intn(141) + 50
+		r = rng.Intn(141) + 50
+		g = rng.Intn(141) + 50
+		b = rng.Intn(141) + 50
 	}
 
 	return [3]int{r, g, b}
@@ -69,89 +77,206 @@ func isValidColor(r, g, b int) bool {
 }
 
 // ─────────────────────────────
-// 【建物スプライト】（16×16ドット）
-
-// 高層ビル（屋根は1行のみのフラットな屋根）
-var highBuildingSprite1 = [][]int{
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 0, 0, 0}, // 屋根行
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-}
-
-// 商業ビル（屋根は1行のみ）
-var commercialBuildingSprite = [][]int{
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 0}, // 屋根行
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-	{1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-	{1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-	{1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-	{1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-	{1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 1, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0},
-}
-
-// 三角屋根の建物（屋根は三角形）
-var triangleBuildingSprite = [][]int{
-	{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 0, 3, 3, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 0, 3, 3, 3, 3, 3, 0, 0, 0, 0, 0, 0, 0, 0},
-	{0, 0, 3, 3, 3, 3, 3, 3, 3, 0, 0, 0, 0, 0, 0, 0},
-	{0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 0, 0, 0, 0, 0, 0},
-	{3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 0, 0, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
-	{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0, 0, 0},
-	{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
-}
-
-// タワー（屋根なし・シンプルな縦長パターン）
-var towerSprite = [][]int{
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
-	{0, 0, 0, 0, 1, 2, 1, 2, 1, 2, 1, 2, 1, 0, 0, 0},
+// 【建物スプライトの生成】
+// 建物は次の文法で組み立てる（| はどれか1つ、[] はあってもなくてもよい）
+//
+//	建物   → [アンテナ] 屋根 階* 1階
+//	屋根   → 平屋根 | 三角屋根 | 段々屋根 | 尖塔
+//	階     → 壁行 窓行 [バルコニー行]
+//	窓行   → 格子窓 | 2連窓 | 横長窓 | まばら窓
+//	1階    → 壁行 入口行
+//
+// 看板は窓の並ぶ面の上に重ねて置く。
+
+const (
+	BUILDING_MIN_WIDTH  = 7  // 建物の幅（ドット）
+	BUILDING_MAX_WIDTH  = 15 // 建物の幅（ドット、BUILDING_BASE_WIDTH / SPRITE_SCALE 以下）
+	BUILDING_MIN_FLOORS = 3  // 階数
+	BUILDING_MAX_FLOORS = 9
+)
+
+// 屋根の種類
+const (
+	roofFlat = iota
+	roofGable
+	roofStepped
+	roofSpire
+	roofKinds
+)
+
+// 窓の並び方
+const (
+	windowGrid = iota
+	windowPairs
+	windowStrip
+	windowSparse
+	windowKinds
+)
+
+// generateBuilding: 文法に沿って建物スプライトを1つ生成する
+// 同じ状態の rng からは同じ建物ができる
+func generateBuilding(rng *rand.Rand) [][]int {
+	width := BUILDING_MIN_WIDTH + rng.Intn(BUILDING_MAX_WIDTH-BUILDING_MIN_WIDTH+1)
+	floors := BUILDING_MIN_FLOORS + rng.Intn(BUILDING_MAX_FLOORS-BUILDING_MIN_FLOORS+1)
+	windowPattern := rng.Intn(windowKinds)
+	hasBalconies := rng.Float64() < 0.3
+
+	var rows [][]int
+	if rng.Float64() < 0.35 {
+		rows = append(rows, antennaRows(rng, width)...)
+	}
+	rows = append(rows, roofRows(rng, width, rng.Intn(roofKinds))...)
+	wallTop := len(rows) // ここから下が壁と窓
+	for floor := 0; floor < floors; floor++ {
+		rows = append(rows, fillRow(width, 1))
+		rows = append(rows, windowRow(rng, width, windowPattern))
+		if hasBalconies && floor%2 == 1 {
+			rows = append(rows, balconyRow(width))
+		}
+	}
+	rows = append(rows, fillRow(width, 1), entranceRow(width))
+
+	if rng.Float64() < 0.4 {
+		addSign(rng, rows, width, wallTop)
+	}
+	return rows
+}
+
+// fillRow: 幅 width の行をすべて key で埋める
+func fillRow(width, key int) []int {
+	row := make([]int, width)
+	for i := range row {
+		row[i] = key
+	}
+	return row
+}
+
+// roofRows: 屋根の行を返す
+func roofRows(rng *rand.Rand, width, kind int) [][]int {
+	switch kind {
+	case roofGable:
+		// 中央から両側へ広がる三角屋根
+		height := (width + 1) / 2
+		rows := make([][]int, height)
+		for i := range rows {
+			rows[i] = make([]int, width)
+			for j := height - 1 - i; j < width-(height-1-i); j++ {
+				rows[i][j] = 3
+			}
+		}
+		return rows
+	case roofStepped:
+		// 2段の段々屋根（上の段は幅が狭い）
+		inset := 1 + rng.Intn(max(1, width/4))
+		top := make([]int, width)
+		for j := inset; j < width-inset; j++ {
+			top[j] = 3
+		}
+		return [][]int{top, fillRow(width, 1), fillRow(width, 3)}
+	case roofSpire:
+		// 細い尖塔と平らな屋根
+		center := width / 2
+		spire := 2 + rng.Intn(3)
+		rows := make([][]int, 0, spire+1)
+		for i := 0; i < spire; i++ {
+			row := make([]int, width)
+			row[center] = 3
+			if i == spire-1 && center > 0 && center < width-1 {
+				row[center-1], row[center+1] = 3, 3
+			}
+			rows = append(rows, row)
+		}
+		return append(rows, fillRow(width, 3))
+	default:
+		return [][]int{fillRow(width, 3)}
+	}
+}
+
+// antennaRows: 屋根の上に立つアンテナの行を返す
+func antennaRows(rng *rand.Rand, width int) [][]int {
+	x := 1 + rng.Intn(width-2)
+	height := 2 + rng.Intn(3)
+	rows := make([][]int, height)
+	for i := range rows {
+		rows[i] = make([]int, width)
+		rows[i][x] = 4
+	}
+	// 先端の航空障害灯
+	rows[0][x] = 5
+	return rows
+}
+
+// windowRow: 窓の並ぶ行を返す（両端は壁）
+func windowRow(rng *rand.Rand, width, pattern int) []int {
+	row := fillRow(width, 1)
+	for j := 1; j < width-1; j++ {
+		switch pattern {
+		case windowGrid:
+			if j%2 == 1 {
+				row[j] = 2
+			}
+		case windowPairs:
+			if j%3 != 0 {
+				row[j] = 2
+			}
+		case windowStrip:
+			row[j] = 2
+		case windowSparse:
+			if j%2 == 1 && rng.Float64() < 0.5 {
+				row[j] = 2
+			}
+		}
+	}
+	return row
+}
+
+// balconyRow: 手すりの並ぶバルコニーの行を返す
+func balconyRow(width int) []int {
+	row := fillRow(width, 1)
+	for j := 1; j < width-1; j++ {
+		if j%2 == 0 {
+			row[j] = 4
+		}
+	}
+	return row
+}
+
+// entranceRow: 1階の入口の行を返す
+func entranceRow(width int) []int {
+	row := fillRow(width, 1)
+	door := width / 2
+	row[door] = 2
+	if width%2 == 0 {
+		row[door-1] = 2
+	}
+	return row
+}
+
+// addSign: 窓の並ぶ面の上に縦長または横長の看板を重ねる
+// 看板を置けるのは wallTop 行目から下の壁と窓の行だけ（屋根やアンテナの行は除く）
+func addSign(rng *rand.Rand, rows [][]int, width, wallTop int) {
+	first := wallTop
+	last := len(rows) - 2 // 入口の行は除く
+	if last-first < 3 {
+		return
+	}
+	if rng.Float64() < 0.5 && width >= 7 {
+		// 横長の看板
+		y := first + 1 + rng.Intn(last-first-1)
+		for j := 1; j < width-1; j++ {
+			rows[y][j] = 5
+		}
+		return
+	}
+	// 縦長の看板（壁の端）
+	x := 0
+	if rng.Float64() < 0.5 {
+		x = width - 1
+	}
+	top := first + 1 + rng.Intn(last-first-2)
+	for i := top; i < top+3 && i < last; i++ {
+		rows[i][x] = 5
+	}
 }
 
 // ─────────────────────────────
@@ -184,40 +309,57 @@ var treeSprite = [][]int{
 // ─────────────────────────────
 // 【月のスプライト】（8×8ドット）
 // キー6を使用
-// 満月
-var moonFullSprite = [][]int{
-	{0, 0, 6, 6, 6, 6, 0, 0},
-	{0, 6, 6, 6, 6, 6, 6, 0},
-	{6, 6, 6, 6, 6, 6, 6, 6},
-	{6, 6, 6, 6, 6, 6, 6, 6},
-	{6, 6, 6, 6, 6, 6, 6, 6},
-	{6, 6, 6, 6, 6, 6, 6, 6},
-	{0, 6, 6, 6, 6, 6, 6, 0},
-	{0, 0, 6, 6, 6, 6, 0, 0},
-}
-
-// 半月（左側のみ表示）
-var moonHalfSprite = [][]int{
-	{0, 0, 6, 6, 0, 0, 0, 0},
-	{0, 6, 6, 6, 0, 0, 0, 0},
-	{6, 6, 6, 6, 0, 0, 0, 0},
-	{6, 6, 6, 6, 0, 0, 0, 0},
-	{6, 6, 6, 6, 0, 0, 0, 0},
-	{6, 6, 6, 6, 0, 0, 0, 0},
-	{0, 6, 6, 6, 0, 0, 0, 0},
-	{0, 0, 6, 6, 0, 0, 0, 0},
-}
-
-// 三日月
-var moonCrescentSprite = [][]int{
-	{0, 0, 6, 6, 6, 6, 0, 0},
-	{0, 0, 0, 6, 6, 6, 6, 0},
-	{0, 0, 0, 0, 6, 6, 6, 6},
-	{0, 0, 0, 0, 6, 6, 6, 6},
-	{0, 0, 0, 6, 6, 6, 6, 6},
-	{6, 6, 6, 6, 6, 6, 6, 6},
-	{0, 6, 6, 6, 6, 6, 6, 0},
-	{0, 0, 6, 6, 6, 6, 0, 0},
+
+// moonPhaseSprite: 月齢 phase（0=新月, 0.25=上弦, 0.5=満月, 0.75=下弦）の月を生成する
+func moonPhaseSprite(phase float64) [][]int {
+	const (
+		size = 8
+		// 新月からこれより近いと、8ドットでは円の内側がどこも光らず縁の余白だけが光ってしまう
+		newMoon = 0.08
+	)
+	sprite := make([][]int, size)
+	for i := range sprite {
+		sprite[i] = make([]int, size)
+	}
+	if math.Min(phase, 1-phase) < newMoon {
+		return sprite // 新月は描かない
+	}
+	radius := size / 2.0
+	// 明暗の境目（行ごとの半幅に対する位置）
+	terminator := math.Cos(2 * math.Pi * phase)
+	for i := range sprite {
+		ny := (float64(i) + 0.5 - radius) / radius
+		halfWidth := math.Sqrt(math.Max(0, 1-ny*ny))
+		for j := range sprite[i] {
+			nx := (float64(j) + 0.5 - radius) / radius
+			if math.Abs(nx) > halfWidth+0.15 {
+				continue // 円の外
+			}
+			// 満ちていくときは右側から光り始め、欠けていくときは右側から暗くなる（北半球から見た向き）
+			lit := nx > halfWidth*terminator
+			if phase > 0.5 {
+				lit = nx < -halfWidth*terminator
+			}
+			if lit {
+				sprite[i][j] = 6
+			}
+		}
+	}
+	return sprite
+}
+
+// ─────────────────────────────
+// 【太陽のスプライト】（8×8ドット）
+// キー7を使用
+var sunSprite = [][]int{
+	{0, 0, 7, 7, 7, 7, 0, 0},
+	{0, 7, 7, 7, 7, 7, 7, 0},
+	{7, 7, 7, 7, 7, 7, 7, 7},
+	{7, 7, 7, 7, 7, 7, 7, 7},
+	{7, 7, 7, 7, 7, 7, 7, 7},
+	{7, 7, 7, 7, 7, 7, 7, 7},
+	{0, 7, 7, 7, 7, 7, 7, 0},
+	{0, 0, 7, 7, 7, 7, 0, 0},
 }
 
 // ─────────────────────────────
@@ -226,81 +368,610 @@ var moonCrescentSprite = [][]int{
 // drawSprite: 指定位置にスプライトを描画（layer毎にシェーディング適用）
 // layer: 0 = 奥（または背景）、1 = 中間、2 = 手前
 func drawSprite(c *p5go.Canvas, sprite [][]int, x, y, scale float64, layer int) {
-	layerShading := [3]float64{1.0, 0.8, 0.6}
-	shading := layerShading[layer]
+	drawShadedSprite(c, sprite, x, y, scale, layerShading[layer], nil)
+}
+
+// layerShading: レイヤーごとの明るさ
+var layerShading = [3]float64{1.0, 0.8, 0.6}
+
+// drawShadedSprite: shading 倍の明るさでスプライトを描画する
+// window が nil でなければ、窓（キー2）のピクセルごとに色を問い合わせる
+func drawShadedSprite(c *p5go.Canvas, sprite [][]int, x, y, scale, shading float64, window func(i, j int) [3]float64) {
 	for i := 0; i < len(sprite); i++ {
 		for j := 0; j < len(sprite[i]); j++ {
 			pixel := sprite[i][j]
-			if pixel != 0 {
-				if color, exists := palette[pixel]; exists {
-					c.Fill(float64(color[0])*shading, float64(color[1])*shading, float64(color[2])*shading)
-					c.Rect(x+float64(j)*scale, y+float64(i)*scale, scale, scale)
+			if pixel == 0 {
+				continue
+			}
+			if pixel == 2 && window != nil {
+				color := window(i, j)
+				c.Fill(color[0], color[1], color[2])
+			} else if color, exists := palette[pixel]; exists {
+				c.Fill(math.Min(255, float64(color[0])*shading), math.Min(255, float64(color[1])*shading), math.Min(255, float64(color[2])*shading))
+			} else {
+				continue
+			}
+			// 拡大したときにドットの間に隙間ができないよう、大きさは切り上げる
+			size := math.Ceil(scale * unit)
+			c.Rect((x+float64(j)*scale)*unit, (y+float64(i)*scale)*unit, size, size)
+		}
+	}
+}
+
+// ─────────────────────────────
+// 時刻
+
+// Sky: ある時刻の空の色（上端と地平線）
+type Sky struct {
+	hour         float64
+	top, horizon [3]float64
+}
+
+// skyKeyframes: 時刻ごとの空の色（間は線形に補間する）
+var skyKeyframes = []Sky{
+	{0, [3]float64{10, 10, 30}, [3]float64{30, 30, 40}},
+	{4.5, [3]float64{15, 15, 40}, [3]float64{40, 35, 60}},
+	{6, [3]float64{60, 80, 150}, [3]float64{250, 150, 100}},
+	{8, [3]float64{90, 160, 235}, [3]float64{190, 220, 245}},
+	{16, [3]float64{80, 150, 230}, [3]float64{200, 220, 240}},
+	{18, [3]float64{70, 60, 140}, [3]float64{250, 120, 80}},
+	{19.5, [3]float64{25, 25, 60}, [3]float64{70, 50, 90}},
+	{21, [3]float64{10, 10, 30}, [3]float64{30, 30, 40}},
+	{24, [3]float64{10, 10, 30}, [3]float64{30, 30, 40}},
+}
+
+// skyAt: hour 時の空の色を返す
+func skyAt(hour float64) ([3]float64, [3]float64) {
+	for k := 1; k < len(skyKeyframes); k++ {
+		from, to := skyKeyframes[k-1], skyKeyframes[k]
+		if hour <= to.hour {
+			t := (hour - from.hour) / (to.hour - from.hour)
+			return lerpColor(from.top, to.top, t), lerpColor(from.horizon, to.horizon, t)
+		}
+	}
+	last := skyKeyframes[len(skyKeyframes)-1]
+	return last.top, last.horizon
+}
+
+// lerpColor: 2つの色を t で補間する
+func lerpColor(a, b [3]float64, t float64) [3]float64 {
+	return [3]float64{
+		a[0] + (b[0]-a[0])*t,
+		a[1] + (b[1]-a[1])*t,
+		a[2] + (b[2]-a[2])*t,
+	}
+}
+
+// smoothstep: edge0 から edge1 にかけて 0 から 1 へなめらかに変化する
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := math.Max(0, math.Min(1, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+// nightness: 夜の度合い（0 = 昼、1 = 夜）
+func nightness(hour float64) float64 {
+	return smoothstep(17, 20, hour) + 1 - smoothstep(5, 7, hour)
+}
+
+// litWindowRatio: 明かりのついている窓の割合
+// 夕方から順に点き、深夜から明け方にかけて少しずつ消える
+func litWindowRatio(hour float64) float64 {
+	evening := smoothstep(16.5, 20, hour)
+	lateNight := 1 - 0.75*smoothstep(0, 4, hour)
+	if hour < 12 {
+		return lateNight * (1 - smoothstep(5, 6.5, hour))
+	}
+	return evening
+}
+
+// windowThreshold: 窓ごとに固定の 0～1 の値（これが割合より小さい窓に明かりがつく）
+func windowThreshold(seed int64, i, j int) float64 {
+	h := uint64(seed)*0x9E3779B97F4A7C15 ^ uint64(i)*0xBF58476D1CE4E5B9 ^ uint64(j)*0x94D049BB133111EB
+	h ^= h >> 31
+	h *= 0xD6E8FEB86659FD93
+	h ^= h >> 32
+	return float64(h%10000) / 10000
+}
+
+// ─────────────────────────────
+// 街の状態
+
+const (
+	FRAME_RATE        = 30
+	DAY_SECONDS       = 60  // 1日の長さ（秒）
+	LUNAR_DAYS        = 8   // 月の満ち欠けの周期（日）
+	START_HOUR        = 17  // 表示を始める時刻
+	SUN_ARC_HEIGHT    = 150 // 太陽と月が昇る高さ
+	STAR_TWINKLE_RATE = 3.0 // 星のまたたきの速さ
+
+	SCROLL_SPEED    = 1.5                       // 手前のレイヤーが1フレームに進む距離
+	TREE_SLOT_WIDTH = CANVAS_WIDTH / TREE_COUNT // 木を1本置く区画の幅
+	TREE_CHANCE     = 0.8                       // 区画に木が生える確率
+)
+
+// layerSpeeds: レイヤーごとのスクロールの速さ（奥ほど遅い）
+var layerSpeeds = [3]float64{1.0, 0.6, 0.35}
+
+// Building: 配置済みの建物
+type Building struct {
+	sprite [][]int
+	x, y   float64 // 左下の位置（x はレイヤー内の位置）
+	layer  int
+	seed   int64 // 窓の明かりの並びを決める
+}
+
+// Star: 位置を固定した星
+type Star struct {
+	x, y, size float64
+	brightness float64
+	phase      float64 // またたきの位相
+}
+
+var (
+	citySeed   int64               // 街全体の乱数の種
+	buildingAt [3]map[int]Building // レイヤーごとに生成済みの建物（区画番号 → 建物）
+	scrollX    float64             // 手前のレイヤーのスクロール位置
+	scrollDir  = 1.0               // スクロールの向き（クリックで反転）
+	stars      []Star
+	clock      = float64(START_HOUR) // 時刻（時）
+	day        int                   // 経過日数（月齢に使う）
+	frameCount int
+)
+
+// slotSeed: レイヤーと区画番号から決まる乱数の種
+// 同じ区画はいつ生成しても同じ建物になるので、戻ってきても街並みが変わらない
+func slotSeed(layer, slot int) int64 {
+	h := uint64(citySeed) ^ uint64(layer+1)*0x9E3779B97F4A7C15 ^ uint64(int64(slot))*0xBF58476D1CE4E5B9
+	h ^= h >> 30
+	h *= 0xBF58476D1CE4E5B9
+	h ^= h >> 27
+	h *= 0x94D049BB133111EB
+	h ^= h >> 31
+	return int64(h)
+}
+
+// building: layer の区画 slot の建物（まだなければ生成する）
+func building(layer, slot int) Building {
+	if b, ok := buildingAt[layer][slot]; ok {
+		return b
+	}
+	rng := rand.New(rand.NewSource(slotSeed(layer, wrapSlot(slot, buildingSlots))))
+	b := Building{
+		sprite: generateBuilding(rng),
+		x:      float64(slot*BUILDING_BASE_WIDTH + rng.Intn(20) - 10),
+		y:      baseY - float64(layer*30),
+		layer:  layer,
+		seed:   rng.Int63(),
+	}
+	buildingAt[layer][slot] = b
+	return b
+}
+
+// forgetBuildings: 画面から離れた区画の建物を捨てる（必要になれば同じものを作り直せる）
+func forgetBuildings(layer, first, last int) {
+	for slot := range buildingAt[layer] {
+		if slot < first-2 || slot > last+2 {
+			delete(buildingAt[layer], slot)
+		}
+	}
+}
+
+// tree: 区画 slot に木があればその位置を返す
+func tree(slot int) (float64, bool) {
+	rng := rand.New(rand.NewSource(slotSeed(len(layerSpeeds), wrapSlot(slot, treeSlots))))
+	if rng.Float64() >= TREE_CHANCE {
+		return 0, false
+	}
+	return float64(slot)*treeSlotWidth + rng.Float64()*treeSlotWidth, true
+}
+
+// wrapSlot: 横につなげるとき、区画番号を 0～slots-1 に折り返す（slots が 0 なら折り返さない）
+func wrapSlot(slot, slots int) int {
+	if slots == 0 {
+		return slot
+	}
+	return ((slot % slots) + slots) % slots
+}
+
+// wrappedX: 横につなげるときは、端をまたぐものが反対側の端にも出るよう左右にずらした位置も返す
+func wrappedX(x float64) []float64 {
+	if !options.tile {
+		return []float64{x}
+	}
+	return []float64{x - viewWidth, x, x + viewWidth}
+}
+
+// visibleSlots: スクロール位置 offset のとき画面にかかる区画の範囲
+func visibleSlots(offset, slotWidth float64) (int, int) {
+	first := int(math.Floor(offset/slotWidth)) - 1
+	last := int(math.Floor((offset+viewWidth)/slotWidth)) + 1
+	return first, last
+}
+
+// advanceScroll: 1フレーム分スクロールを進める
+func advanceScroll() {
+	scrollX += SCROLL_SPEED * scrollDir
+}
+
+// generateStars: 星の位置を決める（またたいても位置は動かない）
+func generateStars(rng *rand.Rand) {
+	stars = make([]Star, STAR_COUNT)
+	for i := range stars {
+		stars[i] = Star{
+			x:          rng.Float64() * viewWidth,
+			y:          rng.Float64() * (baseY - CANVAS_HEIGHT/2),
+			size:       rng.Float64()*2 + 1,
+			brightness: float64(180 + rng.Intn(75)),
+			phase:      rng.Float64() * 2 * math.Pi,
+		}
+	}
+}
+
+// advanceClock: 1フレーム分時刻を進める
+func advanceClock() {
+	clock += 24.0 / (DAY_SECONDS * FRAME_RATE)
+	if clock >= 24 {
+		clock -= 24
+		day++
+	}
+}
+
+// drawSky: 時刻に応じた空のグラデーションと星を描画
+func drawSky(c *p5go.Canvas) {
+	top, horizon := skyAt(clock)
+	width, height := c.Width(), baseY*unit // 水面があれば、その上の地面までが空
+	c.StrokeWeight(1)
+	for y := 0.0; y < height; y += 2 {
+		color := lerpColor(top, horizon, y/height)
+		c.Stroke(color[0], color[1], color[2])
+		c.Line(0, y, width, y)
+		c.Line(0, y+1, width, y+1)
+	}
+	c.NoStroke()
+
+	night := nightness(clock)
+	if night <= 0 {
+		return
+	}
+	seconds := float64(frameCount) / FRAME_RATE
+	for _, star := range stars {
+		twinkle := 0.65 + 0.35*math.Sin(seconds*STAR_TWINKLE_RATE+star.phase)
+		c.Fill(star.brightness, star.brightness, star.brightness, 255*night*twinkle)
+		for _, x := range wrappedX(star.x) {
+			c.Ellipse(x*unit, star.y*unit, star.size*unit, star.size*unit)
+		}
+	}
+}
+
+// arcPosition: 昇ってからの進み具合 t（0～1）の天体の位置
+func arcPosition(t float64, spriteSize float64) (float64, float64) {
+	x := t*(viewWidth+spriteSize) - spriteSize
+	y := baseY - CANVAS_HEIGHT/2 - math.Sin(t*math.Pi)*SUN_ARC_HEIGHT
+	return x, y
+}
+
+// drawSunAndMoon: 太陽は 6 時から 18 時、月は 18 時から翌 6 時に弧を描いて動く
+func drawSunAndMoon(c *p5go.Canvas) {
+	if clock >= 6 && clock < 18 {
+		x, y := arcPosition((clock-6)/12, 8*MOON_SCALE)
+		for _, x := range wrappedX(x) {
+			drawSprite(c, sunSprite, x, y, MOON_SCALE, 0)
+		}
+		return
+	}
+	sinceRise := clock - 18
+	if sinceRise < 0 {
+		sinceRise += 24
+	}
+	phase := math.Mod((float64(day)+sinceRise/24)/LUNAR_DAYS+0.5, 1)
+	x, y := arcPosition(sinceRise/12, 8*MOON_SCALE)
+	moon := moonPhaseSprite(phase)
+	for _, x := range wrappedX(x) {
+		drawSprite(c, moon, x, y, MOON_SCALE, 0)
+	}
+}
+
+// drawCityscape: 奥のレイヤーから順に、レイヤーごとの速さでずらして建物を描画
+// 昼は明るく、夕方から窓に明かりをつける
+func drawCityscape(c *p5go.Canvas) {
+	ambient := 1 + 0.8*(1-nightness(clock)) // 昼は壁や屋根を明るく
+	ratio := litWindowRatio(clock)
+	_, horizon := skyAt(clock)
+	lit := palette[2]
+	wall := palette[1]
+	scale := float64(SPRITE_SCALE)
+	for layer := len(layerSpeeds) - 1; layer >= 0; layer-- {
+		if options.weather == "fog" && layer < len(layerSpeeds)-1 {
+			// 霧: 手前のレイヤーを描く前に霧を重ね、奥のものほど霞ませる
+			drawFog(c, FOG_LAYER_ALPHA)
+		}
+		offset := scrollX * layerSpeeds[layer]
+		first, last := visibleSlots(offset, BUILDING_BASE_WIDTH)
+		shading := layerShading[layer] * ambient
+		// 明かりの消えた窓は壁の色に空を映したような色にする
+		dark := lerpColor([3]float64{float64(wall[0]), float64(wall[1]), float64(wall[2])}, horizon, 0.35)
+		dark = lerpColor([3]float64{}, dark, layerShading[layer])
+		for slot := first; slot <= last; slot++ {
+			b := building(layer, slot)
+			window := func(i, j int) [3]float64 {
+				if windowThreshold(b.seed, i, j) < ratio {
+					return [3]float64{float64(lit[0]), float64(lit[1]), float64(lit[2])}
 				}
+				return dark
 			}
+			drawShadedSprite(c, b.sprite, b.x-offset, b.y-float64(len(b.sprite))*scale, scale, shading, window)
 		}
+		forgetBuildings(layer, first, last)
 	}
 }
 
-// drawNightSky: 夜空と星を描画
-func drawNightSky(c *p5go.Canvas) {
-	c.Background(30, 30, 40)
-	for i := 0; i < STAR_COUNT; i++ {
-		x := rand.Float64() * CANVAS_WIDTH
-		y := rand.Float64() * (CANVAS_HEIGHT / 2)
-		starSize := rand.Float64()*2 + 1
-		brightness := 180 + rand.Intn(75)
-		c.Fill(float64(brightness), float64(brightness), float64(brightness))
-		c.Ellipse(x, y, starSize, starSize)
-	}
-}
-
-// generateCityscape: レイヤー毎に建物群を描画
-func generateCityscape(c *p5go.Canvas) {
-	layers := 3
-	// 建物スプライト（木は別扱い）
-	sprites := [][][]int{
-		highBuildingSprite1,
-		commercialBuildingSprite,
-		triangleBuildingSprite,
-		towerSprite,
-	}
-	for layer := layers - 1; layer >= 0; layer-- {
-		buildingCount := CANVAS_WIDTH / BUILDING_BASE_WIDTH
-		for i := 0; i < buildingCount; i++ {
-			x := float64(i)*BUILDING_BASE_WIDTH + float64(rand.Intn(20)-10)
-			y := BASE_Y - float64(layer*30)
-			scale := float64(SPRITE_SCALE)
-			// 16ドット高さのスプライトを、下端が y に合うように描画
-			selectedSprite := sprites[rand.Intn(len(sprites))]
-			drawSprite(c, selectedSprite, x, y-16*scale, scale, layer)
+// drawTrees: 地面に小さな木を描画（手前レイヤーと同じ速さでスクロール）
+// ここでは木スプライト（8×8）を TREE_SCALE で描画し、下端を地面（baseY）に合わせます。
+func drawTrees(c *p5go.Canvas) {
+	ambient := 1 + 0.8*(1-nightness(clock))
+	offset := scrollX * layerSpeeds[0]
+	first, last := visibleSlots(offset, treeSlotWidth)
+	for slot := first; slot <= last; slot++ {
+		x, ok := tree(slot)
+		if !ok {
+			continue
 		}
+		y := baseY - float64(len(treeSprite))*TREE_SCALE
+		drawShadedSprite(c, treeSprite, x-offset, y, TREE_SCALE, layerShading[2]*ambient, nil)
 	}
 }
 
-// generateTrees: 地面に小さな木を描画（手前レイヤー）
-// ここでは木スプライト（8×8）を TREE_SCALE で描画し、下端を地面（BASE_Y）に合わせます。
-func generateTrees(c *p5go.Canvas) {
-	for i := 0; i < TREE_COUNT; i++ {
-		x := rand.Float64() * CANVAS_WIDTH
-		y := BASE_Y - float64(len(treeSprite))*TREE_SCALE
-		drawSprite(c, treeSprite, x, y, TREE_SCALE, 2)
+// ─────────────────────────────
+// 水面と天気
+
+const (
+	WATER_HEIGHT     = 80   // 地面より下の水面の高さ（基準の px）
+	WATER_DARKEN     = 0.45 // 空の色に対する水の暗さ
+	REFLECTION_ALPHA = 150  // 映り込みの不透明度
+	RIPPLE_AMPLITUDE = 3.0  // 波で映り込みが横にずれる大きさ（水面の下端で最大）
+	RIPPLE_FREQUENCY = 0.35 // 波の細かさ（1px あたりの位相）
+	RIPPLE_SPEED     = 2.5  // 波の速さ
+	SHIMMER_SPEED    = 6.0  // 映り込んだ窓のきらめきの速さ
+	RAIN_COUNT       = 150
+	SNOW_COUNT       = 120
+	FOG_LAYER_ALPHA  = 70 // レイヤーごとに重ねる霧の不透明度
+	FOG_GROUND_ALPHA = 50 // 一番手前に重ねる霧の不透明度
+	WEATHER_WIND     = 0.8
+	RAIN_SPEED       = 9.0
+	SNOW_SPEED       = 0.8
+	SNOW_SWAY        = 0.6
+)
+
+// Drop: 雨粒または雪のひとひら
+type Drop struct {
+	x, y  float64
+	speed float64
+	size  float64
+	phase float64
+}
+
+var drops []Drop
+
+// generateWeather: 天気に合わせて雨粒や雪を画面全体にばらまく
+func generateWeather(rng *rand.Rand) {
+	count := 0
+	switch options.weather {
+	case "rain":
+		count = RAIN_COUNT
+	case "snow":
+		count = SNOW_COUNT
+	}
+	drops = make([]Drop, count)
+	for i := range drops {
+		drops[i] = Drop{
+			x:     rng.Float64() * viewWidth,
+			y:     rng.Float64() * viewHeight,
+			speed: 0.7 + rng.Float64()*0.6,
+			size:  1 + rng.Float64()*2,
+			phase: rng.Float64() * 2 * math.Pi,
+		}
+	}
+}
+
+// rippleOffset: 水面の深さ depth（0～1）の行で、映り込みが横にずれる量
+func rippleOffset(y, depth, seconds float64) float64 {
+	return math.Sin(y*RIPPLE_FREQUENCY+seconds*RIPPLE_SPEED) * RIPPLE_AMPLITUDE * (0.3 + 0.7*depth)
+}
+
+// drawWater: 地面より下に、空を暗く映した水面と、波で揺らいだ街の映り込みを描画
+func drawWater(c *p5go.Canvas) {
+	if !options.water {
+		return
+	}
+	top, horizon := skyAt(clock)
+	width := c.Width()
+	c.StrokeWeight(1)
+	for y := baseY * unit; y < c.Height(); y += 2 {
+		// 水面は地平線の色から、深いところほど空の上端の色に近づける
+		t := (y/unit - baseY) / WATER_HEIGHT
+		color := lerpColor([3]float64{}, lerpColor(horizon, top, t), WATER_DARKEN)
+		c.Stroke(color[0], color[1], color[2])
+		c.Line(0, y, width, y)
+		c.Line(0, y+1, width, y+1)
+	}
+	c.NoStroke()
+
+	seconds := float64(frameCount) / FRAME_RATE
+	ambient := 1 + 0.8*(1-nightness(clock))
+	ratio := litWindowRatio(clock)
+	lit := palette[2]
+	scale := float64(SPRITE_SCALE)
+	for layer := len(layerSpeeds) - 1; layer >= 0; layer-- {
+		offset := scrollX * layerSpeeds[layer]
+		first, last := visibleSlots(offset, BUILDING_BASE_WIDTH)
+		shading := layerShading[layer] * ambient * 0.7
+		for slot := first; slot <= last; slot++ {
+			b := building(layer, slot)
+			spriteTop := b.y - float64(len(b.sprite))*scale
+			for i, row := range b.sprite {
+				// 水面を軸に上下を反転した位置
+				y := 2*baseY - (spriteTop + float64(i+1)*scale)
+				if y < baseY || y >= viewHeight {
+					continue
+				}
+				depth := (y - baseY) / WATER_HEIGHT
+				dx := rippleOffset(y, depth, seconds)
+				alpha := REFLECTION_ALPHA * (1 - 0.6*depth)
+				for j, pixel := range row {
+					if pixel == 0 {
+						continue
+					}
+					if pixel == 2 {
+						if windowThreshold(b.seed, i, j) >= ratio {
+							continue // 明かりのない窓は水に映らない
+						}
+						// 明かりのついた窓は波に合わせてきらめく
+						shimmer := 0.5 + 0.5*math.Sin(seconds*SHIMMER_SPEED+float64(i)*2.3+float64(j)*1.7)
+						c.Fill(float64(lit[0]), float64(lit[1]), float64(lit[2]), alpha*shimmer)
+					} else if color, exists := palette[pixel]; exists {
+						c.Fill(math.Min(255, float64(color[0])*shading), math.Min(255, float64(color[1])*shading), math.Min(255, float64(color[2])*shading), alpha)
+					} else {
+						continue
+					}
+					size := math.Ceil(scale * unit)
+					c.Rect((b.x-offset+float64(j)*scale+dx)*unit, y*unit, size, size)
+				}
+			}
+		}
 	}
 }
 
-// generateMoon: 上空に月をドット絵で表示（3種類のうちランダムに選択）
-func generateMoon(c *p5go.Canvas) {
-	moonSprites := [][][]int{
-		moonFullSprite,
-		moonHalfSprite,
-		moonCrescentSprite,
+// drawFog: 空の地平線の色をした霧を画面全体に重ねる
+func drawFog(c *p5go.Canvas, alpha float64) {
+	_, horizon := skyAt(clock)
+	fog := lerpColor(horizon, [3]float64{200, 200, 210}, 0.5)
+	c.Fill(fog[0], fog[1], fog[2], alpha)
+	c.Rect(0, 0, c.Width(), c.Height())
+}
+
+// drawWeather: 雨や雪を動かして描画し、霧なら手前にもうっすら重ねる
+func drawWeather(c *p5go.Canvas) {
+	switch options.weather {
+	case "rain":
+		c.StrokeWeight(math.Max(1, unit))
+		c.Stroke(180, 190, 220, 150)
+		for i := range drops {
+			d := &drops[i]
+			d.y += RAIN_SPEED * d.speed
+			d.x += WEATHER_WIND
+			wrapDrop(d)
+			c.Line(d.x*unit, d.y*unit, (d.x-WEATHER_WIND*1.5)*unit, (d.y-RAIN_SPEED*d.speed*0.8)*unit)
+		}
+		c.NoStroke()
+	case "snow":
+		seconds := float64(frameCount) / FRAME_RATE
+		c.Fill(245, 245, 255, 220)
+		for i := range drops {
+			d := &drops[i]
+			d.y += SNOW_SPEED * d.speed
+			d.x += WEATHER_WIND*0.3 + math.Sin(seconds+d.phase)*SNOW_SWAY
+			wrapDrop(d)
+			size := math.Ceil(d.size * unit)
+			c.Rect(d.x*unit, d.y*unit, size, size)
+		}
+	case "fog":
+		drawFog(c, FOG_GROUND_ALPHA)
+	}
+}
+
+// wrapDrop: 画面の外に出た雨粒や雪を反対側に戻す
+func wrapDrop(d *Drop) {
+	if d.y > viewHeight {
+		d.y -= viewHeight
+	}
+	if d.x > viewWidth {
+		d.x -= viewWidth
+	} else if d.x < 0 {
+		d.x += viewWidth
+	}
+}
+
+// ─────────────────────────────
+// 壁紙用の書き出し
+//
+// URL のクエリで、同じ街を好きな大きさで描画できる（scripts/art_wallpaper.sh から使う）
+//
+//	?seed=42             街並みを決める乱数の種（同じ種なら同じ街）
+//	?width=3840&height=2160  キャンバスの大きさ（ピクセル）
+//	?tile=1              左右の端がつながるようにする（横に並べて敷き詰められる）
+//	?hour=21.5           時刻を固定して止める（スクロールもしない）
+//	?water=1             地面の下に水面（街の映り込み）を描く（水面の分だけキャンバスが下に伸びる）
+//	?weather=rain        天気（rain: 雨, snow: 雪, fog: 霧）
+
+// Options: クエリから読み取った描画の設定
+type Options struct {
+	seed          int64
+	width, height int
+	tile          bool
+	hour          float64
+	still         bool // hour が指定されたら時刻とスクロールを止める
+	water         bool
+	weather       string // "", "rain", "snow", "fog"
+}
+
+var (
+	options Options
+
+	unit          = 1.0                    // 基準の 1px がキャンバスの何 px になるか
+	viewWidth     = float64(CANVAS_WIDTH)  // 基準の px で測った画面の幅
+	viewHeight    = float64(CANVAS_HEIGHT) // 基準の px で測った画面の高さ
+	baseY         = float64(CANVAS_HEIGHT) // 地面の高さ（基準の px）
+	buildingSlots int                      // 横につなげるときの建物の区画数（0 なら無限）
+	treeSlots     int                      // 横につなげるときの木の区画数
+	treeSlotWidth = float64(TREE_SLOT_WIDTH)
+)
+
+// optionsFromURL: URL のクエリから描画の設定を読み取る
+func optionsFromURL() Options {
+	query, _ := url.ParseQuery(strings.TrimPrefix(js.Global().Get("location").Get("search").String(), "?"))
+	opts := Options{
+		seed:    time.Now().UnixNano(),
+		width:   CANVAS_WIDTH,
+		height:  CANVAS_HEIGHT,
+		tile:    query.Get("tile") == "1",
+		water:   query.Get("water") == "1",
+		weather: query.Get("weather"),
+	}
+	if seed, err := strconv.ParseInt(query.Get("seed"), 10, 64); err == nil {
+		opts.seed = seed
+	}
+	if width, err := strconv.Atoi(query.Get("width")); err == nil && width > 0 {
+		opts.width = width
+	}
+	if height, err := strconv.Atoi(query.Get("height")); err == nil && height > 0 {
+		opts.height = height
+	}
+	if hour, err := strconv.ParseFloat(query.Get("hour"), 64); err == nil {
+		opts.hour = math.Mod(math.Mod(hour, 24)+24, 24)
+		opts.still = true
+	}
+	return opts
+}
+
+// layout: キャンバスの大きさに合わせて街の配置を決める
+// 引き伸ばすのではなく、ドットの大きさは短い辺に合わせ、広い分だけ建物を増やし空を広げる
+func layout(opts Options) {
+	unit = math.Min(float64(opts.width), float64(opts.height)) / CANVAS_HEIGHT
+	viewWidth = float64(opts.width) / unit
+	if opts.tile {
+		// 区画がちょうど画面の幅に収まるよう、ドットの大きさを少しだけ調整する
+		buildingSlots = max(1, int(math.Round(viewWidth/BUILDING_BASE_WIDTH)))
+		viewWidth = float64(buildingSlots * BUILDING_BASE_WIDTH)
+		unit = float64(opts.width) / viewWidth
+		treeSlots = max(1, int(math.Round(viewWidth/TREE_SLOT_WIDTH)))
+		treeSlotWidth = viewWidth / float64(treeSlots)
+	}
+	viewHeight = float64(opts.height) / unit
+	baseY = viewHeight
+	if opts.water {
+		// 地面はそのままにして、その下に水面を足す
+		viewHeight += WATER_HEIGHT
 	}
-	selectedMoon := moonSprites[rand.Intn(len(moonSprites))]
-	moonWidth := len(selectedMoon[0])
-	moonHeight := len(selectedMoon)
-	// x はキャンバス右端に収まるように、y は上半分に配置
-	x := rand.Float64() * (CANVAS_WIDTH - float64(moonWidth)*MOON_SCALE)
-	y := rand.Float64() * ((CANVAS_HEIGHT / 2) - float64(moonHeight)*MOON_SCALE)
-	drawSprite(c, selectedMoon, x, y, MOON_SCALE, 0)
 }
 
 // ─────────────────────────────
@@ -308,15 +979,40 @@ func generateMoon(c *p5go.Canvas) {
 func main() {
 	p5go.Run("#canvas-detail",
 		p5go.Setup(func(c *p5go.Canvas) {
-			c.CreateCanvas(CANVAS_WIDTH, CANVAS_HEIGHT)
+			options = optionsFromURL()
+			layout(options)
+			c.CreateCanvas(options.width, int(math.Round(viewHeight*unit)))
 			c.NoStroke()
+			c.FrameRate(FRAME_RATE)
+
+			citySeed = options.seed
+			if options.still {
+				clock = options.hour
+			}
+			rng := rand.New(rand.NewSource(citySeed))
+			palette[3] = generateRandomRoofColor(rng)
+			for layer := range buildingAt {
+				buildingAt[layer] = map[int]Building{}
+			}
+			generateStars(rng)
+			generateWeather(rng)
 		}),
 		p5go.Draw(func(c *p5go.Canvas) {
-			c.NoLoop()
-			drawNightSky(c)
-			generateMoon(c)
-			generateCityscape(c)
-			generateTrees(c)
+			frameCount++
+			if !options.still {
+				advanceClock()
+				advanceScroll()
+			}
+			drawSky(c)
+			drawSunAndMoon(c)
+			drawCityscape(c)
+			drawTrees(c)
+			drawWater(c)
+			drawWeather(c)
+		}),
+		p5go.MousePressed(func(c *p5go.Canvas) {
+			// クリックでスクロールの向きを反転（戻っても同じ街並みが現れる）
+			scrollDir = -scrollDir
 		}),
 	)
 	select {}
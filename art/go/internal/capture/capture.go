@@ -0,0 +1,40 @@
+// Package capture は、野生のモンスターをボールで捕まえられる確率を計算します。
+package capture
+
+import "math"
+
+// Ball はボールの種類です。
+type Ball int
+
+const (
+	MonsterBall Ball = iota
+	SuperBall
+	HyperBall
+	MasterBall // 必ず捕まえる
+)
+
+// Balls はボールの種類の数です。
+const Balls = 4
+
+// BallBonus はボールの種類ごとの捕獲率の倍率です（マスターボールは倍率によらず必ず捕まえる）。
+var BallBonus = [Balls]float64{1.0, 1.5, 2.0, 1.0}
+
+// HPFactor は HP の減り具合による捕獲率の倍率を返します（満タンで1倍、ひん死寸前で3倍）。
+func HPFactor(hp, maxHP int) float64 {
+	if maxHP <= 0 {
+		return 1
+	}
+	return float64(3*maxHP-2*hp) / float64(maxHP)
+}
+
+// Probability は捕獲の成功率を返します。
+// 基本の捕獲率にレア度（高いほど下がる）、サイズ（大きいほど下がる）、
+// HP の減り具合、ボールの倍率を掛け合わせ、0 から 1 に収めます。
+func Probability(baseRate float64, ball Ball, hp, maxHP, rarity int, sizeValue float64) float64 {
+	if ball == MasterBall {
+		return 1
+	}
+	rate := (baseRate - float64(rarity-1)*0.15) * (2.0 - sizeValue) // XS(1.5～1.3) to XL(0.7～0.5)
+	rate *= HPFactor(hp, maxHP) * BallBonus[ball]
+	return math.Max(0, math.Min(1, rate))
+}
@@ -0,0 +1,117 @@
+package capture
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHPFactor(t *testing.T) {
+	tests := []struct {
+		hp, maxHP int
+		want      float64
+	}{
+		{100, 100, 1},   // 満タン
+		{50, 100, 2},    // 半分
+		{0, 100, 3},     // ひん死寸前
+		{1, 40, 2.95},   // 残り 1
+		{30, 40, 1.5},   // 4分の3
+		{10, 0, 1},      // 最大 HP がない
+		{-5, -5, 1},     // 壊れたデータ
+		{100, 200, 2.0}, // 半分（別の最大 HP）
+	}
+	for _, tt := range tests {
+		if got := HPFactor(tt.hp, tt.maxHP); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("HPFactor(%d, %d) = %v, want %v", tt.hp, tt.maxHP, got, tt.want)
+		}
+	}
+}
+
+func TestProbabilityBallBonus(t *testing.T) {
+	// 基本 0.2・レア度1・サイズ1.0・HP 満タンなら、ボールの倍率がそのまま効く
+	tests := []struct {
+		ball Ball
+		want float64
+	}{
+		{MonsterBall, 0.2},
+		{SuperBall, 0.3},
+		{HyperBall, 0.4},
+		{MasterBall, 1},
+	}
+	for _, tt := range tests {
+		if got := Probability(0.2, tt.ball, 50, 50, 1, 1.0); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Probability(ball %d) = %v, want %v", tt.ball, got, tt.want)
+		}
+	}
+}
+
+func TestProbabilityMasterBall(t *testing.T) {
+	// どんなに捕まえにくくてもマスターボールは必ず捕まえる
+	for _, tt := range []struct {
+		baseRate  float64
+		hp, maxHP int
+		rarity    int
+		sizeValue float64
+	}{
+		{0.01, 100, 100, 5, 1.5},
+		{0, 1, 1, 5, 1.5},
+		{-1, 0, 0, 10, 2},
+	} {
+		if got := Probability(tt.baseRate, MasterBall, tt.hp, tt.maxHP, tt.rarity, tt.sizeValue); got != 1 {
+			t.Errorf("Probability(%+v, MasterBall) = %v, want 1", tt, got)
+		}
+	}
+}
+
+func TestProbabilitySize(t *testing.T) {
+	// 大きいほど捕まえにくい（倍率は 2 - sizeValue）
+	tests := []struct {
+		sizeValue float64
+		want      float64
+	}{
+		{0.5, 0.3},   // XS の最小
+		{1.0, 0.2},   // ふつう
+		{1.5, 0.1},   // XL の最大
+		{1.25, 0.15}, // L
+	}
+	for _, tt := range tests {
+		if got := Probability(0.2, MonsterBall, 10, 10, 1, tt.sizeValue); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Probability(size %v) = %v, want %v", tt.sizeValue, got, tt.want)
+		}
+	}
+	if small, large := Probability(0.3, SuperBall, 20, 40, 2, 0.7), Probability(0.3, SuperBall, 20, 40, 2, 1.3); small <= large {
+		t.Errorf("小さい方が捕まえにくい: %v <= %v", small, large)
+	}
+}
+
+func TestProbabilityRarity(t *testing.T) {
+	// レア度が1上がるごとに基本の捕獲率から 0.15 引く
+	if got := Probability(0.5, MonsterBall, 10, 10, 3, 1.0); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("Probability(rarity 3) = %v, want 0.2", got)
+	}
+}
+
+func TestProbabilityClamp(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseRate  float64
+		ball      Ball
+		hp, maxHP int
+		rarity    int
+		sizeValue float64
+		want      float64
+	}{
+		{"レア度で負になる", 0.1, HyperBall, 1, 100, 5, 1.0, 0},
+		{"大きすぎて負になる", 0.5, MonsterBall, 10, 10, 1, 2.5, 0},
+		{"ひん死でハイパーボール", 0.9, HyperBall, 0, 100, 1, 0.5, 1},
+		{"スーパーボールで1を超える", 0.8, SuperBall, 10, 10, 1, 1.0, 1},
+	}
+	for _, tt := range tests {
+		got := Probability(tt.baseRate, tt.ball, tt.hp, tt.maxHP, tt.rarity, tt.sizeValue)
+		if got != tt.want {
+			t.Errorf("%s: Probability = %v, want %v", tt.name, got, tt.want)
+		}
+		if got < 0 || got > 1 {
+			t.Errorf("%s: Probability = %v は 0～1 の外", tt.name, got)
+		}
+	}
+}
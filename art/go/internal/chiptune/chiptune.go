@@ -0,0 +1,153 @@
+// Package chiptune は、ファミコン風の小さなシンセサイザーです。
+//
+// 矩形波・三角波・ノイズの音色にエンベロープをかけ、トラッカー形式の譜面（Parse を参照）を
+// モノラルの PCM（-1～1 の float32）に書き出します。ブラウザでは Player が WebAudio で鳴らし、
+// ブラウザの外では WriteWAV で同じ PCM を WAV ファイルにして聴いたり比べたりできます。
+package chiptune
+
+import (
+	"fmt"
+	"math"
+)
+
+// Wave は音色の種類です。
+type Wave int
+
+const (
+	Square   Wave = iota // 矩形波（Duty で音色が変わる）
+	Triangle             // 16段階の三角波
+	Noise                // 15ビットの LFSR のノイズ
+)
+
+var waveNames = []string{"square", "triangle", "noise"}
+
+func (w Wave) String() string {
+	if int(w) < len(waveNames) {
+		return waveNames[w]
+	}
+	return fmt.Sprintf("Wave(%d)", int(w))
+}
+
+// Envelope は音量の ADSR エンベロープです。時間の単位は秒です。
+type Envelope struct {
+	Attack  float64 // 0 から最大音量まで上がる時間
+	Decay   float64 // 最大音量から Sustain まで下がる時間
+	Sustain float64 // 鳴らし続けている間の音量（0～1）
+	Release float64 // 音を止めてから 0 まで下がる時間
+}
+
+// DefaultEnvelope は、すぐに立ち上がって少し減衰するエンベロープです。
+var DefaultEnvelope = Envelope{Attack: 0.005, Decay: 0.1, Sustain: 0.6, Release: 0.05}
+
+// Level は、鳴らし始めてから t 秒たったときの音量です。
+func (e Envelope) Level(t float64) float64 {
+	switch {
+	case t < 0:
+		return 0
+	case t < e.Attack:
+		return t / e.Attack
+	case t < e.Attack+e.Decay:
+		return 1 - (1-e.Sustain)*(t-e.Attack)/e.Decay
+	}
+	return e.Sustain
+}
+
+// Released は、音量 level で音を止めてから t 秒たったときの音量です。
+func (e Envelope) Released(level, t float64) float64 {
+	if t >= e.Release {
+		return 0
+	}
+	return level * (1 - t/e.Release)
+}
+
+// Voice は譜面の1列（チャンネル）の音色です。
+type Voice struct {
+	Wave     Wave
+	Duty     float64 // 矩形波の High の割合（0.125, 0.25, 0.5 など）
+	Volume   float64 // ミックスするときの音量（0～1）
+	Envelope Envelope
+}
+
+// noteFrequency は MIDI のノート番号の周波数（A4 = 69 = 440Hz）です。
+func noteFrequency(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+// oscillator は1チャンネルの発音の状態です。
+type oscillator struct {
+	voice Voice
+	rate  float64
+
+	freq    float64
+	phase   float64
+	lfsr    uint16
+	noise   float64
+	on      bool    // 鍵盤を押している
+	elapsed float64 // 押してから、または離してからの秒数
+	level   float64 // 離したときの音量
+}
+
+func newOscillator(voice Voice, rate int) *oscillator {
+	return &oscillator{voice: voice, rate: float64(rate), lfsr: 1, noise: 1}
+}
+
+// noteOn は note を鳴らし始めます。
+func (o *oscillator) noteOn(note int) {
+	o.freq = noteFrequency(note)
+	if o.voice.Wave == Noise {
+		// ノイズはノートの高さを LFSR を進める速さにする
+		o.freq *= 16
+	}
+	o.on = true
+	o.elapsed = 0
+}
+
+// noteOff は音を止め、リリースに入ります。
+func (o *oscillator) noteOff() {
+	if !o.on {
+		return
+	}
+	o.level = o.voice.Envelope.Level(o.elapsed)
+	o.on = false
+	o.elapsed = 0
+}
+
+// next は次のサンプルを返します。
+func (o *oscillator) next() float64 {
+	var amp float64
+	if o.on {
+		amp = o.voice.Envelope.Level(o.elapsed)
+	} else {
+		amp = o.voice.Envelope.Released(o.level, o.elapsed)
+	}
+	o.elapsed += 1 / o.rate
+	if amp == 0 || o.freq == 0 {
+		return 0
+	}
+
+	var v float64
+	switch o.voice.Wave {
+	case Square:
+		v = -1
+		if o.phase < o.voice.Duty {
+			v = 1
+		}
+	case Triangle:
+		// ファミコンと同じく16段階に量子化する
+		tri := 1 - 4*math.Abs(o.phase-0.5)
+		v = math.Round(tri*7.5+7.5)/7.5 - 1
+	case Noise:
+		v = o.noise
+	}
+
+	o.phase += o.freq / o.rate
+	for o.phase >= 1 {
+		o.phase--
+		if o.voice.Wave == Noise {
+			bit := (o.lfsr ^ o.lfsr>>1) & 1
+			o.lfsr = o.lfsr>>1 | bit<<14
+			o.noise = float64(o.lfsr&1)*2 - 1
+		}
+	}
+	return v * amp * o.voice.Volume
+}
@@ -0,0 +1,354 @@
+package chiptune
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	songs, err := Parse([]byte(`
+# コメント
+song jingle   # 曲の名前
+bpm 150
+speed 2
+loop
+voice square duty=0.25 volume=0.3 env=0.01/0.1/0.5/0.2
+voice noise
+C-4 C#4  # C#4 の # はコメントではない
+A-4 ---
+... B-9
+
+song blip
+voice triangle volume=0.4
+C-0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 2 {
+		t.Fatalf("曲が %d あります、want 2", len(songs))
+	}
+
+	s := songs[0]
+	if s.Name != "jingle" || s.BPM != 150 || s.Speed != 2 || !s.Loop {
+		t.Errorf("jingle = %+v", s)
+	}
+	wantVoices := []Voice{
+		{Wave: Square, Duty: 0.25, Volume: 0.3, Envelope: Envelope{0.01, 0.1, 0.5, 0.2}},
+		{Wave: Noise, Duty: 0.5, Volume: 0.3, Envelope: DefaultEnvelope},
+	}
+	if len(s.Voices) != len(wantVoices) {
+		t.Fatalf("voice が %d あります", len(s.Voices))
+	}
+	for i, v := range wantVoices {
+		if s.Voices[i] != v {
+			t.Errorf("Voices[%d] = %+v, want %+v", i, s.Voices[i], v)
+		}
+	}
+	wantRows := [][]Cell{
+		{{Note, 60}, {Note, 61}},
+		{{Note, 69}, {Off, 0}},
+		{{Hold, 0}, {Note, 131}},
+	}
+	if len(s.Rows) != len(wantRows) {
+		t.Fatalf("行が %d あります", len(s.Rows))
+	}
+	for i, row := range wantRows {
+		for j, cell := range row {
+			if s.Rows[i][j] != cell {
+				t.Errorf("Rows[%d][%d] = %+v, want %+v", i, j, s.Rows[i][j], cell)
+			}
+		}
+	}
+
+	// 省略したときの値
+	b := songs[1]
+	if b.BPM != 120 || b.Speed != 4 || b.Loop || b.Rows[0][0] != (Cell{Note, 12}) {
+		t.Errorf("blip = %+v", b)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"song の名前がない":      "song\n",
+		"song の名前が2つ":      "song a b\n",
+		"song より前":         "bpm 120\n",
+		"bpm が数でない":        "song a\nbpm fast\n",
+		"bpm が 0":          "song a\nbpm 0\n",
+		"bpm がマイナス":        "song a\nbpm -120\n",
+		"bpm が NaN":        "song a\nbpm NaN\n",
+		"bpm の引数が2つ":       "song a\nbpm 120 140\n",
+		"bpm が無限大":         "song a\nbpm Inf\nvoice square\nC-4\n",
+		"speed が 0":        "song a\nspeed 0\n",
+		"speed が整数でない":     "song a\nspeed 1.5\n",
+		"bpm×speed が大きすぎる": "song a\nbpm 6000\nspeed 12\nvoice square\nC-4\n",
+		"voice が行より後":      "song a\nvoice square\nC-4\nvoice noise\n",
+		"voice の音色がない":     "song a\nvoice\n",
+		"知らない音色":           "song a\nvoice saw\n",
+		"duty が 0":         "song a\nvoice square duty=0\n",
+		"duty が 1":         "song a\nvoice square duty=1\n",
+		"volume が数でない":     "song a\nvoice square volume=loud\n",
+		"知らない設定":           "song a\nvoice square pan=0.5\n",
+		"env が3つ":          "song a\nvoice square env=0.1/0.1/0.5\n",
+		"env がマイナス":        "song a\nvoice square env=0.1/-0.1/0.5/0.1\n",
+		"サステインが1より大きい":     "song a\nvoice square env=0.1/0.1/1.5/0.1\n",
+		"セルが多い":            "song a\nvoice square\nC-4 C-4\n",
+		"セルが少ない":           "song a\nvoice square\nvoice noise\nC-4\n",
+		"音名でない":            "song a\nvoice square\nH-4\n",
+		"オクターブがない":         "song a\nvoice square\nC-\n",
+		"オクターブが数でない":       "song a\nvoice square\nC-x\n",
+		"譜面の行がない":          "song a\nvoice square\n",
+		"2曲目に譜面の行がない":      "song a\nvoice square\nC-4\nsong b\n",
+	}
+	for name, text := range tests {
+		if songs, err := Parse([]byte(text)); err == nil {
+			t.Errorf("%s: エラーになりません（%d 曲）", name, len(songs))
+		}
+	}
+
+	// 1秒に maxRowsPerSecond 行ちょうどまでは読める
+	if _, err := Parse([]byte("song a\nbpm 15000\nspeed 4\nvoice square\nC-4\n")); err != nil {
+		t.Errorf("1秒に %d 行: %v", maxRowsPerSecond, err)
+	}
+}
+
+func TestParseShippedSounds(t *testing.T) {
+	b, err := os.ReadFile("../../retro_game/sounds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	songs, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range songs {
+		if s.RowSamples(8000) < 1 {
+			t.Errorf("%s: 8000Hz で1行が %d サンプルです", s.Name, s.RowSamples(8000))
+		}
+	}
+}
+
+func TestEnvelopeLevel(t *testing.T) {
+	e := Envelope{Attack: 0.1, Decay: 0.2, Sustain: 0.4, Release: 0.5}
+	tests := []struct {
+		t, want float64
+	}{
+		{-1, 0},
+		{0, 0},
+		{0.05, 0.5}, // アタックの途中
+		{0.1, 1},    // 最大音量
+		{0.2, 0.7},  // ディケイの途中
+		{0.3, 0.4},  // サステイン
+		{10, 0.4},
+	}
+	for _, tt := range tests {
+		if got := e.Level(tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Level(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+
+	// アタックもディケイも 0 なら、いきなりサステインの音量になる
+	flat := Envelope{Sustain: 0.6}
+	for _, at := range []float64{0, 0.01, 1} {
+		if got := flat.Level(at); got != 0.6 {
+			t.Errorf("アタックとディケイが 0: Level(%v) = %v, want 0.6", at, got)
+		}
+	}
+	// アタックが 0 でディケイがあれば、最大音量から始まる
+	if got := (Envelope{Decay: 0.1, Sustain: 0.2}).Level(0); got != 1 {
+		t.Errorf("アタックが 0: Level(0) = %v, want 1", got)
+	}
+}
+
+func TestEnvelopeReleased(t *testing.T) {
+	e := Envelope{Release: 0.5}
+	tests := []struct {
+		level, t, want float64
+	}{
+		{0.8, 0, 0.8},
+		{0.8, 0.25, 0.4},
+		{0.8, 0.5, 0},
+		{0.8, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := e.Released(tt.level, tt.t); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Released(%v, %v) = %v, want %v", tt.level, tt.t, got, tt.want)
+		}
+	}
+	if got := (Envelope{}).Released(1, 0); got != 0 {
+		t.Errorf("リリースが 0: Released(1, 0) = %v, want 0", got)
+	}
+}
+
+func TestRowSamples(t *testing.T) {
+	tests := []struct {
+		bpm   float64
+		speed int
+		rate  int
+		want  int
+	}{
+		{120, 4, 48000, 6000},
+		{150, 4, 44100, 4410},
+		{600, 4, 44100, 1102}, // 切り捨て
+		{90, 3, 8000, 1777},
+		{15000, 4, 1000, 1},
+	}
+	for _, tt := range tests {
+		s := &Song{BPM: tt.bpm, Speed: tt.speed}
+		if got := s.RowSamples(tt.rate); got != tt.want {
+			t.Errorf("bpm %v speed %d rate %d: RowSamples = %d, want %d", tt.bpm, tt.speed, tt.rate, got, tt.want)
+		}
+	}
+}
+
+// testSong は2チャンネルの短い曲です。
+func testSong(loop bool) *Song {
+	songs, err := Parse([]byte(`
+song test
+bpm 120
+voice square env=0.01/0.05/0.5/0.2
+voice triangle env=0.01/0.05/0.5/0.3
+C-4 C-3
+... ---
+E-4 G-3
+--- ---
+`))
+	if err != nil {
+		panic(err)
+	}
+	songs[0].Loop = loop
+	return songs[0]
+}
+
+func TestRenderLength(t *testing.T) {
+	for _, rate := range []int{8000, 44100, 48000} {
+		s := testSong(true)
+		rows := len(s.Rows) * s.RowSamples(rate)
+		if got := len(s.Render(rate)); got != rows {
+			t.Errorf("ループ %dHz: 長さ %d, want %d", rate, got, rows)
+		}
+
+		s = testSong(false)
+		want := rows + int(0.3*float64(rate)) + 1 // いちばん長いリリースまで
+		samples := s.Render(rate)
+		if len(samples) != want {
+			t.Errorf("ループしない %dHz: 長さ %d, want %d", rate, len(samples), want)
+		}
+		if last := samples[len(samples)-1]; last != 0 {
+			t.Errorf("ループしない %dHz: 最後のサンプル %v, want 0", rate, last)
+		}
+		for i, v := range samples {
+			if v < -1 || v > 1 || math.IsNaN(float64(v)) {
+				t.Fatalf("%dHz: %d 番目のサンプル %v", rate, i, v)
+			}
+		}
+	}
+
+	// 1行が1サンプルに満たない周波数でも落ちない
+	s := testSong(true)
+	if got := len(s.Render(10)); got != len(s.Rows) {
+		t.Errorf("10Hz: 長さ %d, want %d", got, len(s.Rows))
+	}
+}
+
+func TestRenderDeterministic(t *testing.T) {
+	a, b := testSong(false).Render(8000), testSong(false).Render(8000)
+	if !slices.Equal(a, b) {
+		t.Error("同じ曲が違う PCM になりました")
+	}
+	silent := true
+	for _, v := range a {
+		if v != 0 {
+			silent = false
+			break
+		}
+	}
+	if silent {
+		t.Error("音が鳴っていません")
+	}
+}
+
+func TestWriteWAV(t *testing.T) {
+	samples := []float32{0, 1, -1, 0.5, 2, -2}
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, samples, 22050); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	dataSize := len(samples) * 2
+	if len(b) != 44+dataSize {
+		t.Fatalf("長さ %d, want %d", len(b), 44+dataSize)
+	}
+
+	le := binary.LittleEndian
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"RIFF", string(b[0:4]), "RIFF"},
+		{"RIFF の大きさ", le.Uint32(b[4:]), uint32(36 + dataSize)},
+		{"WAVE", string(b[8:12]), "WAVE"},
+		{"fmt", string(b[12:16]), "fmt "},
+		{"fmt の大きさ", le.Uint32(b[16:]), uint32(16)},
+		{"リニア PCM", le.Uint16(b[20:]), uint16(1)},
+		{"チャンネル数", le.Uint16(b[22:]), uint16(1)},
+		{"サンプリング周波数", le.Uint32(b[24:]), uint32(22050)},
+		{"1秒のバイト数", le.Uint32(b[28:]), uint32(44100)},
+		{"ブロックの大きさ", le.Uint16(b[32:]), uint16(2)},
+		{"ビット数", le.Uint16(b[34:]), uint16(16)},
+		{"data", string(b[36:40]), "data"},
+		{"data の大きさ", le.Uint32(b[40:]), uint32(dataSize)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// -1～1 を超える値は切りつめる
+	want := []int16{0, 32767, -32767, 16384, 32767, -32767}
+	for i, w := range want {
+		if got := int16(le.Uint16(b[44+i*2:])); got != w {
+			t.Errorf("%d 番目のサンプル %d, want %d", i, got, w)
+		}
+	}
+
+	// 空の PCM はヘッダーだけ
+	buf.Reset()
+	if err := WriteWAV(&buf, nil, 8000); err != nil || buf.Len() != 44 || le.Uint32(buf.Bytes()[40:]) != 0 {
+		t.Errorf("空の PCM: %d バイト, %v", buf.Len(), err)
+	}
+}
+
+type failWriter struct{ n int }
+
+var errWrite = errors.New("書けません")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n -= len(p); w.n < 0 {
+		return 0, errWrite
+	}
+	return len(p), nil
+}
+
+func TestWriteWAVError(t *testing.T) {
+	for _, n := range []int{0, 10, 44} {
+		if err := WriteWAV(&failWriter{n}, []float32{0.1, 0.2}, 8000); !errors.Is(err, errWrite) {
+			t.Errorf("%d バイトで書けなくなる: err = %v", n, err)
+		}
+	}
+}
+
+func TestWaveString(t *testing.T) {
+	for w, want := range map[Wave]string{Square: "square", Triangle: "triangle", Noise: "noise", Wave(9): "Wave(9)"} {
+		if got := w.String(); got != want {
+			t.Errorf("%d: %q, want %q", int(w), got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package chiptune
+
+import (
+	"encoding/binary"
+	"math"
+	"syscall/js"
+)
+
+// Player は、レンダリングした曲を WebAudio で鳴らします。
+// nil の Player のメソッドは何もしないので、音を切っているときは nil のまま使えます。
+type Player struct {
+	ctx     js.Value
+	rate    int
+	buffers map[string]js.Value
+	loop    js.Value // ループ再生中の AudioBufferSourceNode
+	looping string
+}
+
+// NewPlayer は AudioContext を作ります。ブラウザが WebAudio に対応していなければ nil を返します。
+// ブラウザは操作されるまで音を出さないので、クリックやキー入力のたびに Resume を呼んでください。
+func NewPlayer() *Player {
+	constructor := js.Global().Get("AudioContext")
+	if constructor.IsUndefined() {
+		constructor = js.Global().Get("webkitAudioContext")
+	}
+	if constructor.IsUndefined() {
+		return nil
+	}
+	ctx := constructor.New()
+	return &Player{ctx: ctx, rate: ctx.Get("sampleRate").Int(), buffers: map[string]js.Value{}}
+}
+
+// Load は曲を AudioContext のサンプリング周波数でレンダリングし、名前で鳴らせるようにします。
+func (pl *Player) Load(songs ...*Song) {
+	if pl == nil {
+		return
+	}
+	for _, song := range songs {
+		samples := song.Render(pl.rate)
+		if len(samples) == 0 {
+			continue
+		}
+		pl.buffers[song.Name] = pl.audioBuffer(samples)
+	}
+}
+
+// audioBuffer は PCM を AudioBuffer にコピーします。
+func (pl *Player) audioBuffer(samples []float32) js.Value {
+	b := make([]byte, len(samples)*4)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(s))
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(bytes, b)
+
+	buffer := pl.ctx.Call("createBuffer", 1, len(samples), pl.rate)
+	buffer.Call("getChannelData", 0).Call("set", js.Global().Get("Float32Array").New(bytes.Get("buffer")))
+	return buffer
+}
+
+// Resume は、止められている AudioContext を動かします。
+func (pl *Player) Resume() {
+	if pl == nil || pl.ctx.Get("state").String() != "suspended" {
+		return
+	}
+	pl.ctx.Call("resume")
+}
+
+// Play は name の曲を1回鳴らします。効果音は重ねて鳴らせます。
+func (pl *Player) Play(name string) {
+	pl.start(name, false)
+}
+
+// Loop は name の曲をくり返し鳴らします。すでに同じ曲をループしていれば何もしません。
+func (pl *Player) Loop(name string) {
+	if pl == nil || pl.looping == name {
+		return
+	}
+	pl.Stop()
+	if source, ok := pl.start(name, true); ok {
+		pl.loop, pl.looping = source, name
+	}
+}
+
+// Stop はループしている曲を止めます。
+func (pl *Player) Stop() {
+	if pl == nil || pl.looping == "" {
+		return
+	}
+	pl.loop.Call("stop")
+	pl.loop, pl.looping = js.Undefined(), ""
+}
+
+func (pl *Player) start(name string, loop bool) (js.Value, bool) {
+	if pl == nil {
+		return js.Undefined(), false
+	}
+	buffer, ok := pl.buffers[name]
+	if !ok {
+		return js.Undefined(), false
+	}
+	source := pl.ctx.Call("createBufferSource")
+	source.Set("buffer", buffer)
+	source.Set("loop", loop)
+	source.Call("connect", pl.ctx.Get("destination"))
+	source.Call("start")
+	return source, true
+}
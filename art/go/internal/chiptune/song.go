@@ -0,0 +1,302 @@
+package chiptune
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Song はトラッカー形式の1曲（または効果音）です。
+type Song struct {
+	Name   string
+	BPM    float64
+	Speed  int  // 1拍の行数
+	Loop   bool // 最後の行の次に最初の行へ戻る曲か
+	Voices []Voice
+	Rows   [][]Cell // Rows[行][チャンネル]
+}
+
+// CellKind はセルの種類です。
+type CellKind int
+
+const (
+	Hold CellKind = iota // ... 前の行の音をそのまま続ける
+	Off                  // --- 音を止める
+	Note                 // C-4 などの音を鳴らす
+)
+
+// Cell は譜面の1マスです。
+type Cell struct {
+	Kind CellKind
+	Note int // Kind が Note のときの MIDI のノート番号（C-4 = 60）
+}
+
+// Parse はトラッカー形式のテキストから曲を読み込みます。
+//
+//	# # から行末まではコメント（C#4 のような音名の # はそのまま）
+//	song battle              # 曲の名前。次の song までがこの曲
+//	bpm 150
+//	speed 4                  # 1拍の行数（省略すると 4）
+//	loop                     # ループする曲
+//	voice square duty=0.25 volume=0.3 env=0.005/0.1/0.6/0.05
+//	voice triangle volume=0.4
+//	voice noise volume=0.2 env=0.001/0.05/0/0.02
+//	C-5 C-3 C-6
+//	... ... ...
+//	E-5 --- ...
+//
+// voice の env は アタック/ディケイ/サステイン/リリース（秒とサステインの音量）です。
+// 行にはチャンネルごとに1つずつ、C-4・F#3 のような音名、--- （止める）、... （続ける）を並べます。
+// ノイズの音名は LFSR の速さで、高いほど細かいノイズになります。
+func Parse(b []byte) ([]*Song, error) {
+	var songs []*Song
+	var song *Song
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("chiptune: %d行目: %s", line, fmt.Sprintf(format, args...))
+		}
+
+		if fields[0] == "song" {
+			if len(fields) != 2 {
+				return nil, fail("song には名前を1つ書いてください")
+			}
+			song = &Song{Name: fields[1], BPM: 120, Speed: 4}
+			songs = append(songs, song)
+			continue
+		}
+		if song == nil {
+			return nil, fail("最初に song で曲の名前を書いてください")
+		}
+
+		switch fields[0] {
+		case "bpm":
+			bpm, err := parseArg(fields)
+			if err != nil || !(bpm > 0) {
+				return nil, fail("bpm が正しくありません")
+			}
+			song.BPM = bpm
+		case "speed":
+			speed, err := parseArg(fields)
+			if err != nil || speed < 1 || speed != float64(int(speed)) {
+				return nil, fail("speed は1以上の整数にしてください")
+			}
+			song.Speed = int(speed)
+		case "loop":
+			song.Loop = true
+		case "voice":
+			if len(song.Rows) > 0 {
+				return nil, fail("voice は譜面の行より前に書いてください")
+			}
+			voice, err := parseVoice(fields[1:])
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			song.Voices = append(song.Voices, voice)
+		default:
+			if len(fields) != len(song.Voices) {
+				return nil, fail("%d チャンネルの行に %d 個のセルがあります", len(song.Voices), len(fields))
+			}
+			row := make([]Cell, len(fields))
+			for i, field := range fields {
+				cell, err := parseCell(field)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				row[i] = cell
+			}
+			song.Rows = append(song.Rows, row)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, song := range songs {
+		if len(song.Rows) == 0 {
+			return nil, fmt.Errorf("chiptune: %s に譜面の行がありません", song.Name)
+		}
+		if song.BPM*float64(song.Speed)/60 > maxRowsPerSecond {
+			return nil, fmt.Errorf("chiptune: %s は bpm×speed が大きすぎます（1秒に %d 行まで）", song.Name, maxRowsPerSecond)
+		}
+	}
+	return songs, nil
+}
+
+// maxRowsPerSecond は1秒に進める行数の上限です。
+// これを超えると、低いサンプリング周波数では1行が1サンプルに満たなくなります。
+const maxRowsPerSecond = 1000
+
+// parseArg は "bpm 150" のような1つだけの数値の引数を読み込みます。
+func parseArg(fields []string) (float64, error) {
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("引数は1つです")
+	}
+	return strconv.ParseFloat(fields[1], 64)
+}
+
+// parseVoice は "square duty=0.25 volume=0.3 env=a/d/s/r" を読み込みます。
+func parseVoice(fields []string) (Voice, error) {
+	if len(fields) == 0 {
+		return Voice{}, fmt.Errorf("voice に音色がありません")
+	}
+	voice := Voice{Duty: 0.5, Volume: 0.3, Envelope: DefaultEnvelope}
+	switch fields[0] {
+	case "square":
+		voice.Wave = Square
+	case "triangle":
+		voice.Wave = Triangle
+	case "noise":
+		voice.Wave = Noise
+	default:
+		return Voice{}, fmt.Errorf("知らない音色 %q です", fields[0])
+	}
+
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		var err error
+		switch key {
+		case "duty":
+			voice.Duty, err = strconv.ParseFloat(value, 64)
+			if err == nil && (voice.Duty <= 0 || voice.Duty >= 1) {
+				err = fmt.Errorf("duty は 0 と 1 の間にしてください")
+			}
+		case "volume":
+			voice.Volume, err = strconv.ParseFloat(value, 64)
+		case "env":
+			voice.Envelope, err = parseEnvelope(value)
+		default:
+			err = fmt.Errorf("知らない設定 %q です", key)
+		}
+		if err != nil {
+			return Voice{}, err
+		}
+	}
+	return voice, nil
+}
+
+// parseEnvelope は "0.005/0.1/0.6/0.05" を読み込みます。
+func parseEnvelope(s string) (Envelope, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 4 {
+		return Envelope{}, fmt.Errorf("env は アタック/ディケイ/サステイン/リリース の4つです")
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return Envelope{}, fmt.Errorf("env の %q が正しくありません", part)
+		}
+		values[i] = v
+	}
+	if values[2] > 1 {
+		return Envelope{}, fmt.Errorf("env のサステインは 0～1 にしてください")
+	}
+	return Envelope{Attack: values[0], Decay: values[1], Sustain: values[2], Release: values[3]}, nil
+}
+
+// noteNames は音名の C～B の半音の位置です。
+var noteNames = map[string]int{
+	"C-": 0, "C#": 1, "D-": 2, "D#": 3, "E-": 4, "F-": 5,
+	"F#": 6, "G-": 7, "G#": 8, "A-": 9, "A#": 10, "B-": 11,
+}
+
+// parseCell は1マスを読み込みます。
+func parseCell(s string) (Cell, error) {
+	switch s {
+	case "...":
+		return Cell{Kind: Hold}, nil
+	case "---":
+		return Cell{Kind: Off}, nil
+	}
+	if len(s) == 3 {
+		semitone, ok := noteNames[s[:2]]
+		if octave := int(s[2] - '0'); ok && octave >= 0 && octave <= 9 {
+			return Cell{Kind: Note, Note: (octave+1)*12 + semitone}, nil
+		}
+	}
+	return Cell{}, fmt.Errorf("%q は音名ではありません", s)
+}
+
+// RowSamples は1行の長さのサンプル数です。Parse で読んだ曲なら rate が maxRowsPerSecond 以上のとき1以上になります。
+func (s *Song) RowSamples(rate int) int {
+	return int(float64(rate) * 60 / (s.BPM * float64(s.Speed)))
+}
+
+// Render は曲をサンプリング周波数 rate のモノラルの PCM にします。
+// ループする曲はちょうど1周分の長さなので、そのまま繰り返すとつながります。
+// ループしない曲は最後の音のリリースが消えるまでの長さになります。
+func (s *Song) Render(rate int) []float32 {
+	oscillators := make([]*oscillator, len(s.Voices))
+	tail := 0.0
+	for i, voice := range s.Voices {
+		oscillators[i] = newOscillator(voice, rate)
+		tail = max(tail, voice.Envelope.Release)
+	}
+
+	rowSamples := max(s.RowSamples(rate), 1)
+	length := rowSamples * len(s.Rows)
+	if !s.Loop {
+		length += int(tail*float64(rate)) + 1
+	}
+	samples := make([]float32, length)
+	for i := range samples {
+		if i%rowSamples == 0 {
+			if row := i / rowSamples; row < len(s.Rows) {
+				s.trigger(oscillators, s.Rows[row])
+			} else if row == len(s.Rows) {
+				for _, o := range oscillators {
+					o.noteOff()
+				}
+			}
+		}
+		samples[i] = mix(oscillators)
+	}
+	if s.Loop {
+		// 1周目の頭は前の周の終わりの音を引き継げないので、2周目の頭から録り直す
+		copy(samples[:rowSamples], s.continuation(oscillators, rowSamples))
+	}
+	return samples
+}
+
+// trigger は1行分の音を鳴らしたり止めたりします。
+func (s *Song) trigger(oscillators []*oscillator, row []Cell) {
+	for i, cell := range row {
+		switch cell.Kind {
+		case Note:
+			oscillators[i].noteOn(cell.Note)
+		case Off:
+			oscillators[i].noteOff()
+		}
+	}
+}
+
+// continuation は、最後の行から最初の行に戻ったときの最初の行の音です。
+func (s *Song) continuation(oscillators []*oscillator, rowSamples int) []float32 {
+	s.trigger(oscillators, s.Rows[0])
+	samples := make([]float32, rowSamples)
+	for i := range samples {
+		samples[i] = mix(oscillators)
+	}
+	return samples
+}
+
+// mix は全チャンネルの次のサンプルを足し合わせます。
+func mix(oscillators []*oscillator) float32 {
+	var sum float64
+	for _, o := range oscillators {
+		sum += o.next()
+	}
+	return float32(min(max(sum, -1), 1))
+}
@@ -0,0 +1,46 @@
+package chiptune
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// WriteWAV は PCM をサンプリング周波数 rate の16ビット・モノラルの WAV にして w に書き込みます。
+func WriteWAV(w io.Writer, samples []float32, rate int) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+		blockAlign    = channels * bitsPerSample / 8
+	)
+	dataSize := len(samples) * blockAlign
+
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16), // fmt チャンクの大きさ
+		uint16(1),  // リニア PCM
+		uint16(channels),
+		uint32(rate),
+		uint32(rate * blockAlign), // 1秒あたりのバイト数
+		uint16(blockAlign),
+		uint16(bitsPerSample),
+		[4]byte{'d', 'a', 't', 'a'},
+		uint32(dataSize),
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	data := make([]byte, dataSize)
+	for i, s := range samples {
+		v := int16(math.Round(float64(min(max(s, -1), 1)) * math.MaxInt16))
+		binary.LittleEndian.PutUint16(data[i*blockAlign:], uint16(v))
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,71 @@
+// Package encounter は、バトル背景（バイオーム）と天候ごとの出現テーブルから
+// 野生のモンスターの種族とレア度を抽選します。
+//
+// テーブルは JSON で書き、種族ごとの重みをバイオームで決め、天候でタイプごとに増減させます。
+// レア度も基本の重みにバイオームと天候の補正をかけて抽選します。
+package encounter
+
+import "fmt"
+
+// Biome はバトル背景の種類です。retro_game の backgroundType と同じ番号です。
+type Biome int
+
+const (
+	Grassland Biome = iota // 草原
+	Forest                 // 森
+	Mountain               // 山
+	Beach                  // 海辺
+	Cave                   // 洞窟
+	Volcano                // 火山
+	Snowfield              // 雪原
+	Desert                 // 砂漠
+	biomeCount
+)
+
+var biomeNames = [biomeCount]string{"grassland", "forest", "mountain", "beach", "cave", "volcano", "snowfield", "desert"}
+
+// String はテーブルの JSON で使う名前を返します。
+func (b Biome) String() string {
+	if b < 0 || b >= biomeCount {
+		return fmt.Sprintf("Biome(%d)", int(b))
+	}
+	return biomeNames[b]
+}
+
+// ParseBiome は名前（"grassland" など）からバイオームを返します。
+func ParseBiome(name string) (Biome, bool) {
+	for i, n := range biomeNames {
+		if n == name {
+			return Biome(i), true
+		}
+	}
+	return 0, false
+}
+
+// Weather は天候です。retro_game の weatherType と同じ番号です。
+type Weather int
+
+const (
+	Clear     Weather = iota // なし
+	Rain                     // 雨
+	Snow                     // 雪
+	Leaves                   // 落ち葉
+	Sandstorm                // 砂嵐
+	weatherCount
+)
+
+var weatherNames = [weatherCount]string{"clear", "rain", "snow", "leaves", "sandstorm"}
+
+// String はテーブルの JSON で使う名前を返します。
+func (w Weather) String() string {
+	if w < 0 || w >= weatherCount {
+		return fmt.Sprintf("Weather(%d)", int(w))
+	}
+	return weatherNames[w]
+}
+
+// Biomes はバイオームの数です。
+const Biomes = int(biomeCount)
+
+// Rarities はレア度の段階の数です（★1～★5）。
+const Rarities = 5
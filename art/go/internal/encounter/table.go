@@ -0,0 +1,209 @@
+package encounter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// tableJSON は出現テーブルの JSON の形です。
+//
+//	{
+//	  "rarity": [55, 25, 12, 6, 2],
+//	  "biomes": {"grassland": {"species": {"pikachu": 3}, "rarityBoost": 0}, ...},
+//	  "weather": {"rain": {"types": {"water": 2}, "rarityBoost": 0.2}, ...}
+//	}
+type tableJSON struct {
+	Rarity []float64 `json:"rarity"`
+	Biomes map[string]struct {
+		Species     map[string]float64 `json:"species"`
+		RarityBoost float64            `json:"rarityBoost"`
+	} `json:"biomes"`
+	Weather map[string]struct {
+		Types       map[string]float64 `json:"types"`
+		RarityBoost float64            `json:"rarityBoost"`
+	} `json:"weather"`
+}
+
+// Entry は出現する種族とその重みです。
+type Entry struct {
+	Species string
+	Weight  float64
+}
+
+type weatherModifier struct {
+	types       map[string]float64
+	rarityBoost float64
+}
+
+// Table は読み込んで検証した出現テーブルです。
+type Table struct {
+	rarity       [Rarities]float64
+	biomes       [biomeCount][]Entry
+	biomeBoost   [biomeCount]float64
+	weather      [weatherCount]weatherModifier
+	speciesTypes map[string]string
+}
+
+// Parse は出現テーブルの JSON を読み込みます。
+// speciesTypes は種族 ID からタイプ名への対応で、テーブルにない種族やタイプがあればエラーにします。
+// すべてのバイオームに 1 種族以上が必要です。天候は書かなければ補正なしになります。
+func Parse(b []byte, speciesTypes map[string]string) (*Table, error) {
+	var data tableJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	t := &Table{speciesTypes: speciesTypes}
+
+	if len(data.Rarity) != Rarities {
+		return nil, fmt.Errorf("rarity には %d 段階の重みが必要です", Rarities)
+	}
+	total := 0.0
+	for i, w := range data.Rarity {
+		if w < 0 || math.IsNaN(w) {
+			return nil, fmt.Errorf("rarity[%d] の重み %v が負です", i, w)
+		}
+		t.rarity[i] = w
+		total += w
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("rarity の重みがすべて 0 です")
+	}
+
+	knownTypes := map[string]bool{}
+	for _, typ := range speciesTypes {
+		knownTypes[typ] = true
+	}
+
+	for name := range data.Biomes {
+		if _, ok := ParseBiome(name); !ok {
+			return nil, fmt.Errorf("不明なバイオーム %q", name)
+		}
+	}
+	for biome := Biome(0); biome < biomeCount; biome++ {
+		entry, ok := data.Biomes[biome.String()]
+		if !ok || len(entry.Species) == 0 {
+			return nil, fmt.Errorf("%s に出現する種族がありません", biome)
+		}
+		for id, w := range entry.Species {
+			if _, ok := speciesTypes[id]; !ok {
+				return nil, fmt.Errorf("%s: 不明な種族 %q", biome, id)
+			}
+			if w <= 0 || math.IsNaN(w) {
+				return nil, fmt.Errorf("%s: %s の重み %v は正にしてください", biome, id, w)
+			}
+			t.biomes[biome] = append(t.biomes[biome], Entry{Species: id, Weight: w})
+		}
+		// map の順番は毎回違うので、同じ乱数で同じ結果になるよう並べる
+		sort.Slice(t.biomes[biome], func(i, j int) bool {
+			return t.biomes[biome][i].Species < t.biomes[biome][j].Species
+		})
+		t.biomeBoost[biome] = entry.RarityBoost
+	}
+
+	for name, entry := range data.Weather {
+		weather, ok := weatherByName(name)
+		if !ok {
+			return nil, fmt.Errorf("不明な天候 %q", name)
+		}
+		for typ, m := range entry.Types {
+			if !knownTypes[typ] {
+				return nil, fmt.Errorf("%s: 不明なタイプ %q", name, typ)
+			}
+			if m <= 0 || math.IsNaN(m) {
+				return nil, fmt.Errorf("%s: %s の倍率 %v は正にしてください", name, typ, m)
+			}
+		}
+		t.weather[weather] = weatherModifier{types: entry.Types, rarityBoost: entry.RarityBoost}
+	}
+	return t, nil
+}
+
+func weatherByName(name string) (Weather, bool) {
+	for i, n := range weatherNames {
+		if n == name {
+			return Weather(i), true
+		}
+	}
+	return 0, false
+}
+
+// Species は、biome と weather で出現する種族と、天候の補正をかけた重みを返します。
+// 範囲外の biome や weather は草原・天候なしとして扱います。
+func (t *Table) Species(biome Biome, weather Weather) []Entry {
+	biome, weather = clampBiome(biome), clampWeather(weather)
+	mod := t.weather[weather]
+	entries := make([]Entry, len(t.biomes[biome]))
+	for i, e := range t.biomes[biome] {
+		if m, ok := mod.types[t.speciesTypes[e.Species]]; ok {
+			e.Weight *= m
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+// RarityWeights は、biome と weather でのレア度（★1～★5）の重みを返します。
+// 補正 boost は ★k の重みに (1+boost)^(k-1) をかけるので、正なら高いレア度が出やすくなります。
+func (t *Table) RarityWeights(biome Biome, weather Weather) [Rarities]float64 {
+	biome, weather = clampBiome(biome), clampWeather(weather)
+	// 補正が -1 以下だと重みが消えたり符号が変わったりするので抑える
+	boost := math.Max(t.biomeBoost[biome]+t.weather[weather].rarityBoost, -0.9)
+	weights := t.rarity
+	for i := range weights {
+		weights[i] *= math.Pow(1+boost, float64(i))
+	}
+	return weights
+}
+
+// Sample は、biome と weather の出現テーブルから種族 ID とレア度（1～5）を抽選します。
+// roll は [0, 1) の乱数を返す関数です（rand.Float64 など）。
+func (t *Table) Sample(biome Biome, weather Weather, roll func() float64) (string, int) {
+	entries := t.Species(biome, weather)
+	weights := make([]float64, len(entries))
+	for i, e := range entries {
+		weights[i] = e.Weight
+	}
+	species := entries[Pick(weights, roll())].Species
+
+	rarityWeights := t.RarityWeights(biome, weather)
+	rarity := Pick(rarityWeights[:], roll()) + 1
+	return species, rarity
+}
+
+// Pick は、重み weights に比例した確率で番号を選びます。r は [0, 1) の値です。
+func Pick(weights []float64, r float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	target := r * total
+	for i, w := range weights {
+		if target < w {
+			return i
+		}
+		target -= w
+	}
+	// 丸め誤差で選べなかったときは、重みのある最後の番号にする
+	for i := len(weights) - 1; i > 0; i-- {
+		if weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+func clampBiome(b Biome) Biome {
+	if b < 0 || b >= biomeCount {
+		return Grassland
+	}
+	return b
+}
+
+func clampWeather(w Weather) Weather {
+	if w < 0 || w >= weatherCount {
+		return Clear
+	}
+	return w
+}
@@ -0,0 +1,237 @@
+package encounter
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+var testTypes = map[string]string{
+	"hitokage": "fire",
+	"zenigame": "water",
+	"pikachu":  "electric",
+}
+
+// testTable は、草原だけ種族を分け、ほかのバイオームは pikachu だけにしたテーブルです。
+func testTable(t *testing.T) *Table {
+	t.Helper()
+	biomes := map[string]any{}
+	for b := Biome(0); b < biomeCount; b++ {
+		biomes[b.String()] = map[string]any{"species": map[string]float64{"pikachu": 1}}
+	}
+	biomes["grassland"] = map[string]any{
+		"species": map[string]float64{"hitokage": 3, "zenigame": 1},
+	}
+	biomes["cave"] = map[string]any{
+		"species":     map[string]float64{"pikachu": 1},
+		"rarityBoost": 1,
+	}
+	b, err := json.Marshal(map[string]any{
+		"rarity": []float64{8, 4, 2, 1, 1},
+		"biomes": biomes,
+		"weather": map[string]any{
+			"rain": map[string]any{"types": map[string]float64{"water": 3}, "rarityBoost": 0.5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := Parse(b, testTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestPick(t *testing.T) {
+	weights := []float64{1, 0, 3}
+	tests := []struct {
+		r    float64
+		want int
+	}{
+		{0, 0},
+		{0.2499, 0},
+		{0.25, 2}, // 重み 0 の番号は選ばない
+		{0.9999, 2},
+	}
+	for _, tt := range tests {
+		if got := Pick(weights, tt.r); got != tt.want {
+			t.Errorf("Pick(%v, %v) = %d, want %d", weights, tt.r, got, tt.want)
+		}
+	}
+	// 丸め誤差で r*total が合計を超えても、重みのある最後の番号を返す
+	if got := Pick([]float64{1, 1, 0}, math.Nextafter(1, 2)); got != 1 {
+		t.Errorf("Pick(1+ε) = %d, want 1", got)
+	}
+}
+
+func TestSpeciesWeather(t *testing.T) {
+	table := testTable(t)
+	tests := []struct {
+		biome   Biome
+		weather Weather
+		want    []Entry
+	}{
+		{Grassland, Clear, []Entry{{"hitokage", 3}, {"zenigame", 1}}},
+		{Grassland, Rain, []Entry{{"hitokage", 3}, {"zenigame", 3}}}, // 雨で水タイプが3倍
+		{Grassland, Snow, []Entry{{"hitokage", 3}, {"zenigame", 1}}}, // 書いていない天候は補正なし
+		{Forest, Rain, []Entry{{"pikachu", 1}}},
+		{Biome(99), Weather(99), []Entry{{"hitokage", 3}, {"zenigame", 1}}}, // 範囲外は草原・天候なし
+	}
+	for _, tt := range tests {
+		got := table.Species(tt.biome, tt.weather)
+		if len(got) != len(tt.want) {
+			t.Errorf("Species(%s, %s) = %v, want %v", tt.biome, tt.weather, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Species(%s, %s) = %v, want %v", tt.biome, tt.weather, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRarityWeights(t *testing.T) {
+	table := testTable(t)
+	tests := []struct {
+		biome   Biome
+		weather Weather
+		want    [Rarities]float64
+	}{
+		{Grassland, Clear, [Rarities]float64{8, 4, 2, 1, 1}},
+		{Grassland, Rain, [Rarities]float64{8, 6, 4.5, 3.375, 5.0625}}, // 1.5^(k-1)
+		{Cave, Clear, [Rarities]float64{8, 8, 8, 8, 16}},               // 2^(k-1)
+	}
+	for _, tt := range tests {
+		got := table.RarityWeights(tt.biome, tt.weather)
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("RarityWeights(%s, %s) = %v, want %v", tt.biome, tt.weather, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// sampleCounts は seed の乱数で n 回抽選した種族とレア度の回数です。
+func sampleCounts(table *Table, biome Biome, weather Weather, seed int64, n int) (map[string]int, [Rarities]int) {
+	rng := rand.New(rand.NewSource(seed))
+	species := map[string]int{}
+	var rarities [Rarities]int
+	for i := 0; i < n; i++ {
+		id, rarity := table.Sample(biome, weather, rng.Float64)
+		species[id]++
+		rarities[rarity-1]++
+	}
+	return species, rarities
+}
+
+func TestSampleDistribution(t *testing.T) {
+	table := testTable(t)
+	const n = 20000
+	tests := []struct {
+		biome    Biome
+		weather  Weather
+		species  map[string]float64 // 期待する割合
+		rarities [Rarities]float64
+	}{
+		{Grassland, Clear, map[string]float64{"hitokage": 0.75, "zenigame": 0.25}, [Rarities]float64{0.5, 0.25, 0.125, 0.0625, 0.0625}},
+		{Grassland, Rain, map[string]float64{"hitokage": 0.5, "zenigame": 0.5}, [Rarities]float64{8 / 26.9375, 6 / 26.9375, 4.5 / 26.9375, 3.375 / 26.9375, 5.0625 / 26.9375}},
+		{Cave, Clear, map[string]float64{"pikachu": 1}, [Rarities]float64{1.0 / 6, 1.0 / 6, 1.0 / 6, 1.0 / 6, 1.0 / 3}},
+	}
+	for _, tt := range tests {
+		species, rarities := sampleCounts(table, tt.biome, tt.weather, 1, n)
+		for id, want := range tt.species {
+			if got := float64(species[id]) / n; math.Abs(got-want) > 0.02 {
+				t.Errorf("%s/%s: %s の割合 = %.3f, want %.3f", tt.biome, tt.weather, id, got, want)
+			}
+		}
+		if len(species) != len(tt.species) {
+			t.Errorf("%s/%s: 出現した種族 = %v", tt.biome, tt.weather, species)
+		}
+		for i, want := range tt.rarities {
+			if got := float64(rarities[i]) / n; math.Abs(got-want) > 0.02 {
+				t.Errorf("%s/%s: ★%d の割合 = %.3f, want %.3f", tt.biome, tt.weather, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestSampleDeterministic(t *testing.T) {
+	// 同じシードなら、JSON の map の順番によらず同じ結果になる
+	a, ar := sampleCounts(testTable(t), Grassland, Rain, 42, 500)
+	b, br := sampleCounts(testTable(t), Grassland, Rain, 42, 500)
+	if ar != br || a["hitokage"] != b["hitokage"] || a["zenigame"] != b["zenigame"] {
+		t.Errorf("同じシードで結果が違います: %v %v / %v %v", a, ar, b, br)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	allBiomes := func(species string) string {
+		parts := make([]string, 0, biomeCount)
+		for b := Biome(0); b < biomeCount; b++ {
+			parts = append(parts, `"`+b.String()+`": {"species": `+species+`}`)
+		}
+		return strings.Join(parts, ",")
+	}
+	valid := `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}}`
+	if _, err := Parse([]byte(valid), testTypes); err != nil {
+		t.Fatalf("正しいテーブルがエラーになりました: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"JSON ではない", `{"rarity": [1,1,1,1,1],`},
+		{"レア度の段階が足りない", `{"rarity": [1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}}`},
+		{"レア度の重みが負", `{"rarity": [1,-1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}}`},
+		{"レア度の重みがすべて 0", `{"rarity": [0,0,0,0,0], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}}`},
+		{"バイオームが足りない", `{"rarity": [1,1,1,1,1], "biomes": {"grassland": {"species": {"pikachu": 1}}}}`},
+		{"不明なバイオーム", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `, "moon": {"species": {"pikachu": 1}}}}`},
+		{"種族がいない", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{}`) + `}}`},
+		{"不明な種族", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"mew": 1}`) + `}}`},
+		{"種族の重みが 0", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 0}`) + `}}`},
+		{"不明な天候", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}, "weather": {"fog": {}}}`},
+		{"不明なタイプ", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}, "weather": {"rain": {"types": {"ghost": 2}}}}`},
+		{"タイプの倍率が負", `{"rarity": [1,1,1,1,1], "biomes": {` + allBiomes(`{"pikachu": 1}`) + `}, "weather": {"rain": {"types": {"water": -1}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.json), testTypes); err == nil {
+				t.Error("エラーになりませんでした")
+			}
+		})
+	}
+}
+
+func TestParseRetroGameTable(t *testing.T) {
+	// retro_game に同梱しているテーブルが species.json の種族で読み込める
+	speciesJSON, err := os.ReadFile("../../retro_game/species.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var species []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(speciesJSON, &species); err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	for _, sp := range species {
+		types[sp.ID] = sp.Type
+	}
+	tableJSON, err := os.ReadFile("../../retro_game/encounters.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(tableJSON, types); err != nil {
+		t.Error(err)
+	}
+}
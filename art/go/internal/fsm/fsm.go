@@ -0,0 +1,123 @@
+// Package fsm は、ゲームの画面や進行を有限状態機械で管理します。
+//
+// 状態ごとに enter/exit/update/draw のフックと、一定フレーム後の自動遷移を登録できます。
+// 遷移表にない遷移はエラーになり、状態は変わりません。
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIllegalTransition は、遷移表にない遷移をしようとしたときのエラーです。
+var ErrIllegalTransition = errors.New("fsm: 遷移できません")
+
+// Hooks は1つの状態のフックです。どれも nil でかまいません。
+type Hooks[S comparable] struct {
+	Enter  func(from S)     // その状態に入ったとき（from は直前の状態）
+	Exit   func(to S)       // その状態から出るとき（to は次の状態）
+	Update func(frames int) // 毎フレーム。frames はその状態に入ってからのフレーム数（1 から）
+	Draw   func()           // 毎フレームの描画
+
+	// After フレームたったら Next が返す状態へ移ります（0 なら時間では移りません）。
+	// Update で先に別の状態へ移っていれば何もしません。
+	After int
+	Next  func() S
+}
+
+// Machine は状態機械です。
+type Machine[S comparable] struct {
+	state       S
+	previous    S
+	frames      int
+	generation  int // 遷移するたびに増える（フックの中で遷移したかを調べる）
+	hooks       map[S]Hooks[S]
+	transitions map[S]map[S]bool
+}
+
+// New は initial から始まる状態機械を生成します。
+// transitions は状態ごとに、そこから移ってよい状態の一覧です（自分自身へ移るなら自分も書きます）。
+// initial の Enter フックは Start で呼ばれます。
+func New[S comparable](initial S, transitions map[S][]S) *Machine[S] {
+	m := &Machine[S]{
+		state:       initial,
+		previous:    initial,
+		hooks:       map[S]Hooks[S]{},
+		transitions: map[S]map[S]bool{},
+	}
+	for from, tos := range transitions {
+		m.transitions[from] = map[S]bool{}
+		for _, to := range tos {
+			m.transitions[from][to] = true
+		}
+	}
+	return m
+}
+
+// On は state のフックを登録します（同じ状態に登録しなおすと置き換えます）。
+func (m *Machine[S]) On(state S, hooks Hooks[S]) {
+	m.hooks[state] = hooks
+}
+
+// Start は最初の状態の Enter フックを呼びます。
+func (m *Machine[S]) Start() {
+	m.frames = 0
+	m.generation++
+	if enter := m.hooks[m.state].Enter; enter != nil {
+		enter(m.state)
+	}
+}
+
+// State は今の状態を返します。
+func (m *Machine[S]) State() S { return m.state }
+
+// Previous は直前の状態を返します（ポーズ画面などから戻るときに使います）。
+func (m *Machine[S]) Previous() S { return m.previous }
+
+// Frames は今の状態に入ってからのフレーム数を返します。
+func (m *Machine[S]) Frames() int { return m.frames }
+
+// Can は今の状態から to へ移れるかを返します。
+func (m *Machine[S]) Can(to S) bool {
+	return m.transitions[m.state][to]
+}
+
+// Go は to へ移ります。遷移表にない遷移なら ErrIllegalTransition を返し、状態は変わりません。
+// 今の状態の Exit、to の Enter の順にフックを呼びます。
+func (m *Machine[S]) Go(to S) error {
+	if !m.Can(to) {
+		return fmt.Errorf("%w: %v → %v", ErrIllegalTransition, m.state, to)
+	}
+	from := m.state
+	if exit := m.hooks[from].Exit; exit != nil {
+		exit(to)
+	}
+	m.previous, m.state = from, to
+	m.frames = 0
+	m.generation++
+	if enter := m.hooks[to].Enter; enter != nil {
+		enter(from)
+	}
+	return nil
+}
+
+// Update は今の状態の Update フックを呼び、時間がきていれば自動で遷移します。
+func (m *Machine[S]) Update() error {
+	m.frames++
+	generation := m.generation
+	hooks := m.hooks[m.state]
+	if hooks.Update != nil {
+		hooks.Update(m.frames)
+	}
+	if m.generation != generation || hooks.After <= 0 || hooks.Next == nil || m.frames < hooks.After {
+		return nil
+	}
+	return m.Go(hooks.Next())
+}
+
+// Draw は今の状態の Draw フックを呼びます。
+func (m *Machine[S]) Draw() {
+	if draw := m.hooks[m.state].Draw; draw != nil {
+		draw()
+	}
+}
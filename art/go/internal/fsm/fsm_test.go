@@ -0,0 +1,187 @@
+package fsm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type state int
+
+const (
+	idle state = iota
+	walking
+	running
+	resting
+)
+
+var testTransitions = map[state][]state{
+	idle:    {walking},
+	walking: {idle, running},
+	running: {resting},
+	resting: {idle},
+}
+
+func TestGoIllegal(t *testing.T) {
+	m := New(idle, testTransitions)
+	var calls []string
+	m.On(idle, Hooks[state]{Exit: func(state) { calls = append(calls, "exit idle") }})
+	m.On(running, Hooks[state]{Enter: func(state) { calls = append(calls, "enter running") }})
+	m.Start()
+	m.Update()
+
+	tests := []state{running, resting, idle} // 遷移表にない（自分自身も書いていなければ移れない）
+	for _, to := range tests {
+		err := m.Go(to)
+		if !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("Go(%v) = %v, want ErrIllegalTransition", to, err)
+		}
+		if m.State() != idle || m.Previous() != idle || m.Frames() != 1 {
+			t.Errorf("Go(%v) のあと State=%v Previous=%v Frames=%d, want 変わらない", to, m.State(), m.Previous(), m.Frames())
+		}
+		if m.Can(to) {
+			t.Errorf("Can(%v) = true", to)
+		}
+	}
+	if len(calls) != 0 {
+		t.Errorf("遷移できないのにフックが呼ばれました: %v", calls)
+	}
+
+	// 遷移表にない状態からはどこへも移れない
+	orphan := New(state(99), testTransitions)
+	if err := orphan.Go(idle); !errors.Is(err, ErrIllegalTransition) {
+		t.Errorf("Go from unknown = %v, want ErrIllegalTransition", err)
+	}
+}
+
+func TestGoHooks(t *testing.T) {
+	m := New(idle, testTransitions)
+	var calls []string
+	m.On(idle, Hooks[state]{
+		Enter: func(from state) { calls = append(calls, "enter idle") },
+		Exit: func(to state) {
+			if to != walking {
+				t.Errorf("Exit(%v), want walking", to)
+			}
+			calls = append(calls, "exit idle")
+		},
+	})
+	m.On(walking, Hooks[state]{
+		Enter: func(from state) {
+			if from != idle {
+				t.Errorf("Enter(%v), want idle", from)
+			}
+			calls = append(calls, "enter walking")
+		},
+	})
+	m.Start()
+	m.Update()
+	m.Update()
+	if err := m.Go(walking); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"enter idle", "exit idle", "enter walking"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if m.State() != walking || m.Previous() != idle || m.Frames() != 0 {
+		t.Errorf("State=%v Previous=%v Frames=%d", m.State(), m.Previous(), m.Frames())
+	}
+}
+
+func TestAfterNext(t *testing.T) {
+	m := New(running, testTransitions)
+	var frames []int
+	m.On(running, Hooks[state]{
+		Update: func(f int) { frames = append(frames, f) },
+		After:  3,
+		Next:   func() state { return resting },
+	})
+	m.Start()
+	for i := 1; i <= 2; i++ {
+		if err := m.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if m.State() != running {
+			t.Fatalf("%d フレーム目で %v に移りました", i, m.State())
+		}
+	}
+	// After フレーム目の Update のあとで移る
+	if err := m.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if m.State() != resting || m.Frames() != 0 {
+		t.Errorf("3 フレーム目のあと State=%v Frames=%d, want resting 0", m.State(), m.Frames())
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(frames, want) {
+		t.Errorf("Update に渡したフレーム数 = %v, want %v", frames, want)
+	}
+
+	// After が 0 なら時間では移らない
+	m.On(resting, Hooks[state]{Next: func() state { return idle }})
+	for i := 0; i < 10; i++ {
+		m.Update()
+	}
+	if m.State() != resting {
+		t.Errorf("After 0 で %v に移りました", m.State())
+	}
+}
+
+func TestUpdateMovedSkipsNext(t *testing.T) {
+	m := New(walking, testTransitions)
+	nextCalled := false
+	m.On(walking, Hooks[state]{
+		Update: func(f int) {
+			if f == 2 {
+				m.Go(idle)
+			}
+		},
+		After: 2,
+		Next: func() state {
+			nextCalled = true
+			return running
+		},
+	})
+	m.Start()
+	m.Update()
+	if err := m.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if nextCalled {
+		t.Error("Update で移ったのに Next が呼ばれました")
+	}
+	if m.State() != idle {
+		t.Errorf("State = %v, want idle", m.State())
+	}
+
+	// 移った先では新しくフレームを数え直す
+	m.Update()
+	if m.Frames() != 1 {
+		t.Errorf("Frames = %d, want 1", m.Frames())
+	}
+}
+
+func TestUpdateIllegalNext(t *testing.T) {
+	m := New(resting, testTransitions)
+	m.On(resting, Hooks[state]{After: 1, Next: func() state { return running }})
+	if err := m.Update(); !errors.Is(err, ErrIllegalTransition) {
+		t.Errorf("Update = %v, want ErrIllegalTransition", err)
+	}
+	if m.State() != resting {
+		t.Errorf("State = %v, want resting", m.State())
+	}
+}
+
+func TestDraw(t *testing.T) {
+	m := New(idle, testTransitions)
+	drawn := ""
+	m.On(idle, Hooks[state]{Draw: func() { drawn = "idle" }})
+	m.On(walking, Hooks[state]{Draw: func() { drawn = "walking" }})
+	m.Draw()
+	m.Go(walking)
+	m.Draw()
+	if drawn != "walking" {
+		t.Errorf("drawn = %q, want walking", drawn)
+	}
+	m.Go(running) // フックのない状態でも何もしない
+	m.Draw()
+}
@@ -0,0 +1,52 @@
+// Package gamestate は、retro_game の画面と捕獲の流れの状態と、状態のあいだの遷移表です。
+//
+// 遷移表は fsm.New にそのまま渡します。js に依存しないので、どの遷移ができるかをブラウザの外で確かめられます。
+package gamestate
+
+import "fmt"
+
+// State は画面と捕獲の流れの状態です。
+type State int
+
+const (
+	Title      State = iota // タイトル
+	Overworld               // フィールドを歩く
+	Encounter               // 野生のモンスターがあらわれた（メニュー）
+	Moves                   // わざを選ぶ
+	Bag                     // ボールを選ぶ
+	Attacking               // ターンの進行中
+	Throwing                // ボールが飛んでいる
+	Shaking                 // ボールが揺れている
+	Success                 // 捕まえた
+	Failed                  // ボールから出てきた（メニュー）
+	Fainted                 // どちらかがたおれた
+	GameOver                // 逃げられた
+	Collection              // ずかん
+)
+
+var stateNames = []string{"title", "overworld", "encounter", "moves", "bag", "attacking", "throwing", "shaking", "success", "failed", "fainted", "gameover", "collection"}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Transitions は状態ごとに移ってよい状態です。
+// ずかんは入力を待っている画面からだけ開け、閉じると開く前の画面に戻ります。
+var Transitions = map[State][]State{
+	Title:      {Overworld},
+	Overworld:  {Encounter, Collection},
+	Encounter:  {Overworld, Moves, Bag, Throwing, Collection},
+	Failed:     {Overworld, Encounter, Moves, Bag, Throwing, Collection},
+	Moves:      {Encounter, Attacking},
+	Bag:        {Encounter, Throwing},
+	Attacking:  {Encounter, Fainted},
+	Throwing:   {Shaking},
+	Shaking:    {Success, Failed, GameOver},
+	Success:    {Overworld, Collection},
+	Fainted:    {Overworld, Collection},
+	GameOver:   {Overworld, Collection},
+	Collection: {Overworld, Encounter, Failed, Success, Fainted, GameOver},
+}
@@ -0,0 +1,149 @@
+package gamestate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ryomak/sketch/art/internal/fsm"
+)
+
+// allStates はすべての状態です。
+var allStates = []State{Title, Overworld, Encounter, Moves, Bag, Attacking, Throwing, Shaking, Success, Failed, Fainted, GameOver, Collection}
+
+// machineAt は from にいる状態機械を作ります。
+func machineAt(from State) *fsm.Machine[State] {
+	return fsm.New(from, Transitions)
+}
+
+func TestIllegalTransitions(t *testing.T) {
+	tests := []struct{ from, to State }{
+		{Throwing, Encounter},  // 投げたボールは取り消せない
+		{Overworld, Throwing},  // バトルの外ではボールを投げられない
+		{Overworld, Success},   // 捕まえたことにはできない
+		{Title, Encounter},     // タイトルからはフィールドへ
+		{Shaking, Encounter},   // 揺れ終わるまで待つ
+		{Shaking, Collection},  // 揺れている間はずかんを開けない
+		{Throwing, Collection}, // 飛んでいる間も
+		{Attacking, Throwing},  // ターンの途中で投げられない
+		{Attacking, Overworld}, // ターンの途中でにげられない
+		{Success, Encounter},   // 捕まえたら終わり
+		{GameOver, Throwing},
+		{Fainted, Encounter},
+		{Collection, Throwing},
+		{Collection, Title},
+		{Moves, Throwing},
+		{Bag, Attacking},
+	}
+	for _, tt := range tests {
+		m := machineAt(tt.from)
+		if err := m.Go(tt.to); !errors.Is(err, fsm.ErrIllegalTransition) {
+			t.Errorf("%v → %v: err = %v, want ErrIllegalTransition", tt.from, tt.to, err)
+		}
+		if m.State() != tt.from {
+			t.Errorf("%v → %v: State = %v, want %v", tt.from, tt.to, m.State(), tt.from)
+		}
+	}
+}
+
+func TestLegalTransitions(t *testing.T) {
+	tests := []struct{ from, to State }{
+		{Title, Overworld},
+		{Overworld, Encounter},
+		{Encounter, Bag},
+		{Bag, Throwing},
+		{Encounter, Throwing},
+		{Throwing, Shaking},
+		{Shaking, Success},
+		{Shaking, Failed},
+		{Shaking, GameOver},
+		{Failed, Throwing},
+		{Encounter, Moves},
+		{Moves, Attacking},
+		{Attacking, Encounter},
+		{Attacking, Fainted},
+		{Encounter, Overworld},
+		{Success, Overworld},
+	}
+	for _, tt := range tests {
+		if err := machineAt(tt.from).Go(tt.to); err != nil {
+			t.Errorf("%v → %v: %v", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestOnlyTableTransitions(t *testing.T) {
+	// すべての組について、遷移表にあるものだけ移れる
+	for _, from := range allStates {
+		allowed := map[State]bool{}
+		for _, to := range Transitions[from] {
+			allowed[to] = true
+		}
+		for _, to := range allStates {
+			err := machineAt(from).Go(to)
+			if allowed[to] && err != nil {
+				t.Errorf("%v → %v: %v", from, to, err)
+			}
+			if !allowed[to] && !errors.Is(err, fsm.ErrIllegalTransition) {
+				t.Errorf("%v → %v: err = %v, want ErrIllegalTransition", from, to, err)
+			}
+		}
+	}
+}
+
+func TestCollectionReturns(t *testing.T) {
+	// ずかんを開ける状態へは、ずかんを閉じて戻れる
+	for _, from := range allStates {
+		m := machineAt(from)
+		if !m.Can(Collection) {
+			continue
+		}
+		if err := m.Go(Collection); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Go(m.Previous()); err != nil {
+			t.Errorf("ずかんから %v に戻れません: %v", from, err)
+		}
+	}
+}
+
+func TestReachable(t *testing.T) {
+	// タイトルからすべての状態に行けて、どの状態からもフィールドに戻れる（行き止まりがない）
+	reach := func(from State) map[State]bool {
+		seen := map[State]bool{from: true}
+		queue := []State{from}
+		for len(queue) > 0 {
+			s := queue[0]
+			queue = queue[1:]
+			for _, to := range Transitions[s] {
+				if !seen[to] {
+					seen[to] = true
+					queue = append(queue, to)
+				}
+			}
+		}
+		return seen
+	}
+	fromTitle := reach(Title)
+	for _, s := range allStates {
+		if !fromTitle[s] {
+			t.Errorf("タイトルから %v に行けません", s)
+		}
+		if s != Title && !reach(s)[Overworld] {
+			t.Errorf("%v からフィールドに戻れません", s)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	seen := map[string]bool{}
+	for _, s := range allStates {
+		name := s.String()
+		if seen[name] {
+			t.Errorf("%q が重複しています", name)
+		}
+		seen[name] = true
+	}
+	if got := State(-1).String(); got != "State(-1)" {
+		t.Errorf("State(-1).String() = %q", got)
+	}
+}
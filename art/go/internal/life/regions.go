@@ -0,0 +1,164 @@
+// Package life は、ライフゲームの盤面の生存セルを、つながった領域（形）ごとに分けます。
+//
+// 盤面は cells[列][行] の 0/1 で、端が反対側とつながるトーラスとして扱います。
+package life
+
+import (
+	"fmt"
+	"sort"
+)
+
+// neighbours は8近傍の向きです。
+var neighbours = [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+
+// LabelRegions は生存セルを8近傍でつながった領域ごとにラベル付けします。
+// 上下左右の端をまたいだ領域も1つにまとめます。
+// ラベルは列優先で走査したときに最初に現れた順に 1 から振り、死んだセルは 0 のままにします。
+func LabelRegions(cells [][]int) [][]int {
+	cols := len(cells)
+	if cols == 0 {
+		return [][]int{}
+	}
+	rows := len(cells[0])
+	labels := make2DArray(cols, rows)
+	uf := newUnionFind(cols * rows)
+
+	// 8近傍のうち半分（右上・右・右下・下）だけを見れば、すべての隣接関係を一度ずつ結合できる
+	directions := [][2]int{{1, -1}, {1, 0}, {1, 1}, {0, 1}}
+	for column := 0; column < cols; column++ {
+		for row := 0; row < rows; row++ {
+			if cells[column][row] == 0 {
+				continue
+			}
+			for _, dir := range directions {
+				col := (column + dir[0] + cols) % cols
+				r := (row + dir[1] + rows) % rows
+				if cells[col][r] == 1 {
+					uf.union(column*rows+row, col*rows+r)
+				}
+			}
+		}
+	}
+
+	// 代表元ごとに連番のラベルを振る
+	regionIDs := make(map[int]int)
+	for column := 0; column < cols; column++ {
+		for row := 0; row < rows; row++ {
+			if cells[column][row] == 0 {
+				continue
+			}
+			root := uf.find(column*rows + row)
+			id, exists := regionIDs[root]
+			if !exists {
+				id = len(regionIDs) + 1
+				regionIDs[root] = id
+			}
+			labels[column][row] = id
+		}
+	}
+	return labels
+}
+
+// ShapeKeys は LabelRegions のラベルごとに、形を表すキーを返します。
+// 端をまたいだ領域は反対側に回り込んだセルをつなげて（ほどいて）から、
+// 一番左上が原点になるように動かすので、同じ形ならどこにあっても同じキーになります。
+func ShapeKeys(labels [][]int) map[int]string {
+	cols := len(labels)
+	if cols == 0 {
+		return map[int]string{}
+	}
+	rows := len(labels[0])
+
+	// 領域ごとに最初のセルから幅優先でたどり、つながった向きに座標を伸ばしていく
+	unwrapped := make(map[int][][2]int)
+	visited := make2DArray(cols, rows)
+	for column := 0; column < cols; column++ {
+		for row := 0; row < rows; row++ {
+			id := labels[column][row]
+			if id == 0 || visited[column][row] == 1 {
+				continue
+			}
+			visited[column][row] = 1
+			queue := [][4]int{{column, row, column, row}} // 盤面上の位置とほどいた座標
+			for len(queue) > 0 {
+				cell := queue[0]
+				queue = queue[1:]
+				unwrapped[id] = append(unwrapped[id], [2]int{cell[2], cell[3]})
+				for _, dir := range neighbours {
+					col := (cell[0] + dir[0] + cols) % cols
+					r := (cell[1] + dir[1] + rows) % rows
+					if labels[col][r] == id && visited[col][r] == 0 {
+						visited[col][r] = 1
+						queue = append(queue, [4]int{col, r, cell[2] + dir[0], cell[3] + dir[1]})
+					}
+				}
+			}
+		}
+	}
+
+	keys := make(map[int]string, len(unwrapped))
+	for id, points := range unwrapped {
+		minX, minY := points[0][0], points[0][1]
+		for _, p := range points {
+			minX, minY = min(minX, p[0]), min(minY, p[1])
+		}
+		shape := make([]string, len(points))
+		for i, p := range points {
+			shape[i] = fmt.Sprintf("%d,%d", p[0]-minX, p[1]-minY)
+		}
+		sort.Strings(shape) // 形状を一意にするためソート
+		keys[id] = fmt.Sprintf("%v", shape)
+	}
+	return keys
+}
+
+// 2次元配列を作成する関数
+func make2DArray(cols, rows int) [][]int {
+	arr := make([][]int, cols)
+	for i := range arr {
+		arr[i] = make([]int, rows)
+	}
+	return arr
+}
+
+// Union-Find（素集合データ構造）
+// 再帰を使わないので、大きな盤面でもスタックを消費しない
+type unionFind struct {
+	parent []int
+	rank   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{
+		parent: make([]int, n),
+		rank:   make([]int, n),
+	}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+// 代表元を探す（経路半減で木を平らにする）
+func (uf *unionFind) find(x int) int {
+	for uf.parent[x] != x {
+		uf.parent[x] = uf.parent[uf.parent[x]]
+		x = uf.parent[x]
+	}
+	return x
+}
+
+// 2つの集合を結合する（ランクの低い木を高い木の下につなぐ）
+func (uf *unionFind) union(a, b int) {
+	ra, rb := uf.find(a), uf.find(b)
+	if ra == rb {
+		return
+	}
+	if uf.rank[ra] < uf.rank[rb] {
+		ra, rb = rb, ra
+	}
+	uf.parent[rb] = ra
+	if uf.rank[ra] == uf.rank[rb] {
+		uf.rank[ra]++
+	}
+}
@@ -0,0 +1,174 @@
+package life
+
+import "testing"
+
+// grid は行ごとの文字列（# が生存セル）から cells[列][行] を作ります。
+func grid(lines ...string) [][]int {
+	cells := make2DArray(len(lines[0]), len(lines))
+	for row, line := range lines {
+		for column, c := range line {
+			if c == '#' {
+				cells[column][row] = 1
+			}
+		}
+	}
+	return cells
+}
+
+// regionCount はラベルの種類の数です。
+func regionCount(labels [][]int) int {
+	seen := map[int]bool{}
+	for _, column := range labels {
+		for _, id := range column {
+			if id != 0 {
+				seen[id] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+func TestLabelRegions(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells [][]int
+		want  int
+	}{
+		{"空の盤面", grid("....", "....", "...."), 0},
+		{"ブロック", grid(
+			"......",
+			".##...",
+			".##...",
+			"......",
+		), 1},
+		{"離れた2つのグライダー", grid(
+			".#........",
+			"..#.......",
+			"###.......",
+			"..........",
+			"......#...",
+			".......#..",
+			".....###..",
+			"..........",
+		), 2},
+		{"斜めだけでつながる", grid(
+			"#...",
+			".#..",
+			"..#.",
+			"....",
+		), 1},
+		{"左右の端をまたぐ", grid(
+			"......",
+			"#....#",
+			"#....#",
+			"......",
+		), 1},
+		{"上下の端をまたぐ", grid(
+			"..#...",
+			"......",
+			"......",
+			"..#...",
+		), 1},
+		{"角を斜めにまたぐ", grid(
+			"#....",
+			".....",
+			".....",
+			"....#",
+		), 1},
+		{"ブリンカーが3つ", grid(
+			"###.....",
+			"........",
+			"....###.",
+			"........",
+			"#.......",
+			"#.......",
+			"#.......",
+			"........",
+		), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := regionCount(LabelRegions(tt.cells)); got != tt.want {
+				t.Errorf("領域の数 = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelRegionsOrder(t *testing.T) {
+	labels := LabelRegions(grid(
+		"#..#.",
+		".....",
+		"..#..",
+		".....",
+		".....",
+	))
+	// 列優先で最初に現れた順に 1 から振る
+	if labels[0][0] != 1 || labels[2][2] != 2 || labels[3][0] != 3 {
+		t.Errorf("labels = %v", labels)
+	}
+	if labels[1][1] != 0 {
+		t.Errorf("死んだセルのラベル = %d, want 0", labels[1][1])
+	}
+}
+
+func TestShapeKeys(t *testing.T) {
+	key := func(cells [][]int) string {
+		keys := ShapeKeys(LabelRegions(cells))
+		if len(keys) != 1 {
+			t.Fatalf("領域が %d 個あります", len(keys))
+		}
+		return keys[1]
+	}
+
+	glider := key(grid(
+		".#....",
+		"..#...",
+		"###...",
+		"......",
+		"......",
+	))
+	tests := []struct {
+		name  string
+		cells [][]int
+	}{
+		{"動かしたグライダー", grid(
+			"......",
+			"......",
+			"...#..",
+			"....#.",
+			"..###.",
+		)},
+		{"左右の端をまたぐグライダー", grid(
+			"#.....",
+			".#....",
+			"##...#",
+			"......",
+			"......",
+		)},
+		{"上下の端をまたぐグライダー", grid(
+			"###...",
+			"......",
+			"......",
+			".#....",
+			"..#...",
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := key(tt.cells); got != glider {
+				t.Errorf("key = %s, want %s", got, glider)
+			}
+		})
+	}
+
+	block := key(grid(
+		"#..#",
+		"....",
+		"....",
+		"#..#",
+	))
+	if want := "[0,0 0,1 1,0 1,1]"; block != want {
+		t.Errorf("四隅をまたぐブロックの key = %s, want %s", block, want)
+	}
+}
@@ -0,0 +1,95 @@
+package motion
+
+import "math"
+
+// 背景モデル（ピクセルごとのガウス分布）のパラメータ
+const (
+	foregroundLearningRatio   = 0.05  // 前景ピクセルの学習率の倍率（止まった人が背景に溶けるまでの速さ）
+	foregroundSigma           = 2.5   // 背景の標準偏差の何倍離れたら前景とみなすか
+	backgroundInitialVariance = 225.0 // 分散の初期値
+	backgroundMinVariance     = 36.0  // 分散の下限（カメラノイズ程度）
+)
+
+// BackgroundModel は、ピクセルごとの色をガウス分布（平均と分散）で近似した背景モデルです。
+// フレームを与えるたびに学習率に応じて平均と分散を更新し、背景から外れたピクセルを前景とします。
+type BackgroundModel struct {
+	mean         []float64 // ピクセルごとの RGB の平均
+	variance     []float64 // ピクセルごとの分散（RGB で共通）
+	learningRate float64
+}
+
+// NewBackgroundModel は、学習率 learningRate の空の背景モデルを生成します。
+// 最初に与えたフレームがそのまま背景の初期値になります。
+func NewBackgroundModel(learningRate float64) BackgroundModel {
+	return BackgroundModel{learningRate: learningRate}
+}
+
+// Apply は、frame で背景モデルを更新し、ピクセルごとの前景の重みを dst に書き込んで返します。
+// 前景の重みは背景との RGB の差の合計で、背景と判定されたピクセルは 0 です。
+func (m *BackgroundModel) Apply(dst []float64, frame []byte, width, height int) []float64 {
+	n := width * height
+	if cap(dst) < n {
+		dst = make([]float64, n)
+	}
+	dst = dst[:n]
+
+	// 映像サイズが変わったら最初のフレームから学習し直す
+	if len(m.variance) != n {
+		m.mean = make([]float64, n*3)
+		m.variance = make([]float64, n)
+		for i := 0; i < n; i++ {
+			m.mean[i*3] = float64(frame[i*4])
+			m.mean[i*3+1] = float64(frame[i*4+1])
+			m.mean[i*3+2] = float64(frame[i*4+2])
+			m.variance[i] = backgroundInitialVariance
+			dst[i] = 0
+		}
+		return dst
+	}
+
+	for i := 0; i < n; i++ {
+		var dist2, weight float64
+		var diff [3]float64
+		for ch := 0; ch < 3; ch++ {
+			diff[ch] = float64(frame[i*4+ch]) - m.mean[i*3+ch]
+			dist2 += diff[ch] * diff[ch]
+			weight += math.Abs(diff[ch])
+		}
+		dist2 /= 3
+
+		isForeground := dist2 > foregroundSigma*foregroundSigma*m.variance[i]
+		rate := m.learningRate
+		if isForeground {
+			dst[i] = weight
+			rate *= foregroundLearningRatio
+		} else {
+			dst[i] = 0
+		}
+
+		for ch := 0; ch < 3; ch++ {
+			m.mean[i*3+ch] += rate * diff[ch]
+		}
+		m.variance[i] = math.Max(backgroundMinVariance, m.variance[i]+rate*(dist2-m.variance[i]))
+	}
+	return dst
+}
+
+// FrameDifference は、前フレームとの RGB の差の合計が threshold を超えたピクセルの重みを dst に書き込んで返します。
+func FrameDifference(dst []float64, cur, prev []byte, width, height int, threshold float64) []float64 {
+	n := width * height
+	if cap(dst) < n {
+		dst = make([]float64, n)
+	}
+	dst = dst[:n]
+	for i := 0; i < n; i++ {
+		diff := absDiff(cur[i*4], prev[i*4]) +
+			absDiff(cur[i*4+1], prev[i*4+1]) +
+			absDiff(cur[i*4+2], prev[i*4+2])
+		if diff > threshold {
+			dst[i] = diff
+		} else {
+			dst[i] = 0
+		}
+	}
+	return dst
+}
@@ -0,0 +1,94 @@
+package motion
+
+// Blob は差分マスク上でつながった動きの塊を表します。
+type Blob struct {
+	X, Y float64 // 重み付き重心
+	Area float64 // 面積
+}
+
+// BuildMask は、ピクセルごとの前景の重み fg を cellSize 四方のセルごとに集計して mask に書き込みます。
+// 前景のピクセルの割合が fillRatio 以上のセルには重みの合計を、それ以外には 0 を入れます。
+// マスクの列数と行数を返します。
+func BuildMask(mask []float64, fg []float64, width, height, cellSize, step int, fillRatio float64) ([]float64, int, int) {
+	cols := width / cellSize
+	rows := height / cellSize
+	if cap(mask) < cols*rows {
+		mask = make([]float64, cols*rows)
+	}
+	mask = mask[:cols*rows]
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			var weight float64
+			var hits, total int
+			for py := row * cellSize; py < (row+1)*cellSize; py += step {
+				for px := col * cellSize; px < (col+1)*cellSize; px += step {
+					total++
+					if w := fg[py*width+px]; w > 0 {
+						hits++
+						weight += w
+					}
+				}
+			}
+			if float64(hits) < fillRatio*float64(total) {
+				weight = 0
+			}
+			mask[row*cols+col] = weight
+		}
+	}
+	return mask, cols, rows
+}
+
+// ExtractBlobs は、マスク上で8近傍につながったセルをまとめてブロブにします。
+// 位置と面積はセルの大きさ cellW × cellH で換算し、minCells 未満の塊は捨てます。
+func ExtractBlobs(mask []float64, cols, rows int, cellW, cellH float64, minCells int) []Blob {
+	visited := make([]bool, len(mask))
+	queue := make([]int, 0, len(mask))
+	var blobs []Blob
+
+	for start := range mask {
+		if mask[start] == 0 || visited[start] {
+			continue
+		}
+
+		// 幅優先探索で塊を集める（再帰しないので大きな塊でも安全）
+		var sumX, sumY, sumWeight float64
+		cells := 0
+		queue = append(queue[:0], start)
+		visited[start] = true
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			col, row := i%cols, i/cols
+			w := mask[i]
+			sumX += (float64(col) + 0.5) * w
+			sumY += (float64(row) + 0.5) * w
+			sumWeight += w
+			cells++
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nc, nr := col+dx, row+dy
+					if nc < 0 || nc >= cols || nr < 0 || nr >= rows {
+						continue
+					}
+					j := nr*cols + nc
+					if mask[j] != 0 && !visited[j] {
+						visited[j] = true
+						queue = append(queue, j)
+					}
+				}
+			}
+		}
+
+		if cells < minCells {
+			continue
+		}
+		blobs = append(blobs, Blob{
+			X:    sumX / sumWeight * cellW,
+			Y:    sumY / sumWeight * cellH,
+			Area: float64(cells) * cellW * cellH,
+		})
+	}
+	return blobs
+}
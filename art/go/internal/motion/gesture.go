@@ -0,0 +1,276 @@
+package motion
+
+import "math"
+
+// Gesture はポインタの軌跡から認識したジェスチャーの種類です。
+type Gesture string
+
+const (
+	GestureNone       Gesture = ""
+	GestureSwipeLeft  Gesture = "swipe-left"
+	GestureSwipeRight Gesture = "swipe-right"
+	GestureSwipeUp    Gesture = "swipe-up"
+	GestureSwipeDown  Gesture = "swipe-down"
+	GestureCircle     Gesture = "circle"
+	GestureHold       Gesture = "hold"
+)
+
+// $1 Unistroke Recognizer のパラメータ
+const (
+	unistrokePoints     = 64    // 比較の前に軌跡を等間隔に打ち直す点の数
+	unistrokeSquareSize = 250.0 // 大きさをそろえる正方形の一辺
+)
+
+// GesturePoint は時刻つきの軌跡上の点です（T は秒）。
+type GesturePoint struct {
+	X, Y, T float64
+}
+
+// GestureTemplate は、認識の手本となる軌跡です。
+type GestureTemplate struct {
+	Gesture Gesture
+	Points  []GesturePoint // 正規化済みの点列
+}
+
+// NewGestureTemplate は、points を正規化して手本にします。
+func NewGestureTemplate(gesture Gesture, points []GesturePoint) GestureTemplate {
+	return GestureTemplate{Gesture: gesture, Points: normalizeStroke(points)}
+}
+
+// DefaultGestureTemplates は、上下左右のスワイプと円（時計回り・反時計回り）の手本を返します。
+// 座標は画面と同じく y が下向きです。
+func DefaultGestureTemplates() []GestureTemplate {
+	line := func(x0, y0, x1, y1 float64) []GesturePoint {
+		points := make([]GesturePoint, 16)
+		for i := range points {
+			t := float64(i) / float64(len(points)-1)
+			points[i] = GesturePoint{X: x0 + (x1-x0)*t, Y: y0 + (y1-y0)*t}
+		}
+		return points
+	}
+	circle := func(direction float64) []GesturePoint {
+		points := make([]GesturePoint, 33)
+		for i := range points {
+			angle := direction * 2 * math.Pi * float64(i) / float64(len(points)-1)
+			points[i] = GesturePoint{X: math.Cos(angle), Y: math.Sin(angle)}
+		}
+		return points
+	}
+	return []GestureTemplate{
+		NewGestureTemplate(GestureSwipeLeft, line(1, 0, 0, 0)),
+		NewGestureTemplate(GestureSwipeRight, line(0, 0, 1, 0)),
+		NewGestureTemplate(GestureSwipeUp, line(0, 1, 0, 0)),
+		NewGestureTemplate(GestureSwipeDown, line(0, 0, 0, 1)),
+		NewGestureTemplate(GestureCircle, circle(1)),
+		NewGestureTemplate(GestureCircle, circle(-1)),
+	}
+}
+
+// Recognize は、軌跡 points に最も近い手本とそのスコア（0～1、1 で完全一致）を返します。
+//
+// $1 Unistroke Recognizer をもとにしていますが、スワイプの向きを区別するため回転の正規化は行わず、
+// 直線でも形が崩れないよう縦横の比率を保ったまま大きさをそろえます。
+func Recognize(points []GesturePoint, templates []GestureTemplate) (Gesture, float64) {
+	if len(points) < 2 || len(templates) == 0 {
+		return GestureNone, 0
+	}
+	candidate := normalizeStroke(points)
+
+	best := GestureNone
+	bestDistance := math.Inf(1)
+	for _, template := range templates {
+		if d := pathDistance(candidate, template.Points); d < bestDistance {
+			best, bestDistance = template.Gesture, d
+		}
+	}
+	halfDiagonal := 0.5 * math.Hypot(unistrokeSquareSize, unistrokeSquareSize)
+	return best, math.Max(0, 1-bestDistance/halfDiagonal)
+}
+
+// normalizeStroke は、軌跡を等間隔に打ち直し、比率を保って正方形に収め、重心を原点に移します。
+func normalizeStroke(points []GesturePoint) []GesturePoint {
+	resampled := resampleStroke(points, unistrokePoints)
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	var cx, cy float64
+	for _, pt := range resampled {
+		minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+		minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
+		cx += pt.X
+		cy += pt.Y
+	}
+	cx /= float64(len(resampled))
+	cy /= float64(len(resampled))
+
+	scale := 1.0
+	if size := math.Max(maxX-minX, maxY-minY); size > 0 {
+		scale = unistrokeSquareSize / size
+	}
+	for i := range resampled {
+		resampled[i].X = (resampled[i].X - cx) * scale
+		resampled[i].Y = (resampled[i].Y - cy) * scale
+	}
+	return resampled
+}
+
+// resampleStroke は、軌跡を弧長に沿って n 個の等間隔な点に打ち直します。
+func resampleStroke(points []GesturePoint, n int) []GesturePoint {
+	interval := strokeLength(points) / float64(n-1)
+	resampled := make([]GesturePoint, 0, n)
+	resampled = append(resampled, points[0])
+	if interval == 0 {
+		for len(resampled) < n {
+			resampled = append(resampled, points[0])
+		}
+		return resampled
+	}
+
+	walked := 0.0
+	prev := points[0]
+	for i := 1; i < len(points); i++ {
+		pt := points[i]
+		d := math.Hypot(pt.X-prev.X, pt.Y-prev.Y)
+		for walked+d >= interval && len(resampled) < n {
+			// prev から pt への途中に新しい点を置き、そこから歩き直す
+			t := (interval - walked) / d
+			prev = GesturePoint{
+				X: prev.X + t*(pt.X-prev.X),
+				Y: prev.Y + t*(pt.Y-prev.Y),
+				T: prev.T + t*(pt.T-prev.T),
+			}
+			resampled = append(resampled, prev)
+			d = math.Hypot(pt.X-prev.X, pt.Y-prev.Y)
+			walked = 0
+		}
+		walked += d
+		prev = pt
+	}
+	// 丸め誤差で足りなければ最後の点で埋める
+	for len(resampled) < n {
+		resampled = append(resampled, points[len(points)-1])
+	}
+	return resampled
+}
+
+// strokeLength は、軌跡の長さを返します。
+func strokeLength(points []GesturePoint) float64 {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	return length
+}
+
+// pathDistance は、同じ点数の2つの点列の対応する点どうしの平均距離を返します。
+func pathDistance(a, b []GesturePoint) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += math.Hypot(a[i].X-b[i].X, a[i].Y-b[i].Y)
+	}
+	return sum / float64(len(a))
+}
+
+// GestureConfig はジェスチャーの切り出しと認識の設定です。距離は入力と同じ座標系です。
+type GestureConfig struct {
+	MoveThreshold   float64 // 1サンプルでこれ以上動いたら動いているとみなす
+	StillDuration   float64 // これだけの秒数止まったら軌跡の終わりとみなす
+	MinStrokeLength float64 // これより短い軌跡は認識しない
+	MaxStrokeTime   float64 // 軌跡として扱う最長の秒数（それより古い点は捨てる）
+	MinScore        float64 // これ未満のスコアは認識しない
+	HoldRadius      float64 // この半径の中にとどまっていれば静止とみなす
+	HoldDuration    float64 // これだけの秒数静止したら hold とする
+}
+
+// DefaultGestureConfig は、400×400 程度のキャンバス座標で使うときの設定を返します。
+func DefaultGestureConfig() GestureConfig {
+	return GestureConfig{
+		MoveThreshold:   3,
+		StillDuration:   0.15,
+		MinStrokeLength: 80,
+		MaxStrokeTime:   1.5,
+		MinScore:        0.75,
+		HoldRadius:      12,
+		HoldDuration:    1.0,
+	}
+}
+
+// GestureRecognizer は、ポインタの位置を1サンプルずつ受け取り、
+// 動き出してから止まるまでを1つの軌跡として切り出してジェスチャーを認識します。
+type GestureRecognizer struct {
+	config    GestureConfig
+	templates []GestureTemplate
+
+	stroke     []GesturePoint // 動いている間の軌跡
+	last       GesturePoint   // 直前のサンプル
+	hasLast    bool
+	stillSince float64      // 最後に動いた時刻
+	anchor     GesturePoint // 静止判定の中心
+	held       bool         // 今の静止ですでに hold を発火したか
+}
+
+// NewGestureRecognizer は、templates を手本にする GestureRecognizer を生成します。
+func NewGestureRecognizer(config GestureConfig, templates []GestureTemplate) *GestureRecognizer {
+	return &GestureRecognizer{config: config, templates: templates}
+}
+
+// Add は、時刻 t（秒）のポインタの位置を追加し、ジェスチャーを認識したらそれと true を返します。
+func (r *GestureRecognizer) Add(x, y, t float64) (Gesture, bool) {
+	pt := GesturePoint{X: x, Y: y, T: t}
+	if !r.hasLast {
+		r.last, r.hasLast = pt, true
+		r.anchor, r.stillSince = pt, t
+		return GestureNone, false
+	}
+
+	prev := r.last
+	moving := math.Hypot(x-prev.X, y-prev.Y) >= r.config.MoveThreshold
+	r.last = pt
+
+	// 静止: 中心から一定の範囲にとどまり続けたら hold
+	if math.Hypot(x-r.anchor.X, y-r.anchor.Y) > r.config.HoldRadius {
+		r.anchor = pt
+		r.held = false
+	} else if !r.held && t-r.anchor.T >= r.config.HoldDuration {
+		r.held = true
+		r.stroke = r.stroke[:0]
+		return GestureHold, true
+	}
+
+	if moving {
+		if len(r.stroke) == 0 {
+			r.stroke = append(r.stroke, prev)
+		}
+		r.stroke = append(r.stroke, pt)
+		r.stillSince = t
+		// 古すぎる点は捨てる
+		start := 0
+		for start < len(r.stroke) && t-r.stroke[start].T > r.config.MaxStrokeTime {
+			start++
+		}
+		r.stroke = r.stroke[start:]
+		return GestureNone, false
+	}
+
+	// 止まってしばらく経ったら軌跡の終わりとして認識する
+	if len(r.stroke) == 0 || t-r.stillSince < r.config.StillDuration {
+		return GestureNone, false
+	}
+	stroke := r.stroke
+	r.stroke = r.stroke[:0]
+	if strokeLength(stroke) < r.config.MinStrokeLength {
+		return GestureNone, false
+	}
+	gesture, score := Recognize(stroke, r.templates)
+	if score < r.config.MinScore {
+		return GestureNone, false
+	}
+	return gesture, true
+}
+
+// Reset は、途中の軌跡を捨てて最初からやり直します（ポインタを見失ったときなど）。
+func (r *GestureRecognizer) Reset() {
+	r.stroke = r.stroke[:0]
+	r.hasLast = false
+	r.held = false
+}
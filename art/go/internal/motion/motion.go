@@ -0,0 +1,47 @@
+// Package motion は、Webカメラなどの映像から動体を検出・追跡し、
+// スケッチの入力（マウスの代わりのポインタなど）として使えるようにします。
+//
+// 映像の入力元（FrameSource）から1フレームずつ読み込み、背景モデルまたは前フレームとの差分で
+// 前景を求め、つながった領域（ブロブ）をフレーム間で対応づけて ID つきの動体（Track）にします。
+package motion
+
+// Config は動体検出と追跡の設定です。
+type Config struct {
+	UseBackgroundModel bool    // false なら前フレームとの差分で動きを検出する
+	LearningRate       float64 // 背景モデルの学習率（0～1、大きいほど早く背景になじむ）
+	Threshold          float64 // 前フレームとの差分とみなす RGB の差の合計の閾値
+	SampleStep         int     // 差分を調べる間隔（ピクセル）
+	BlobCellSize       int     // 差分マスクのセルの大きさ（映像のピクセル）
+	BlobFillRatio      float64 // セル内の前景ピクセルの割合がこれ以上なら動きありとみなす
+	MinBlobCells       int     // これより小さいブロブはノイズとして捨てる
+	MaxMatchDistance   float64 // 前フレームの動体と対応づける最大距離（出力座標）
+	MaxMissedFrames    int     // 見失ってからこのフレーム数を超えたら追跡をやめる
+	VelocitySmoothing  float64 // 速度の EMA の係数
+	Smoothing          float64 // ポインタ位置の EMA の係数（0～1、値が大きいほど素早く追従）
+	Mirror             bool    // true なら左右反転する（カメラに向かって鏡のように動かすとき）
+}
+
+// DefaultConfig は、400×400 程度のキャンバスで Webカメラを使うときの設定を返します。
+func DefaultConfig() Config {
+	return Config{
+		UseBackgroundModel: true,
+		LearningRate:       0.02,
+		Threshold:          100,
+		SampleStep:         1,
+		BlobCellSize:       8,
+		BlobFillRatio:      0.2,
+		MinBlobCells:       4,
+		MaxMatchDistance:   80,
+		MaxMissedFrames:    10,
+		VelocitySmoothing:  0.5,
+		Smoothing:          0.3,
+	}
+}
+
+// absDiff は、2つの輝度値の差の絶対値を返します。
+func absDiff(a, b byte) float64 {
+	if a > b {
+		return float64(a - b)
+	}
+	return float64(b - a)
+}
@@ -0,0 +1,258 @@
+package motion
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// squareSource は、無地の背景の上を正方形が等速で動く映像です。
+// 最初の frames フレームは背景だけを映します。
+type squareSource struct {
+	width, height int
+	squares       []movingSquare
+	frame         int
+}
+
+type movingSquare struct {
+	start      int     // 映り始めるフレーム
+	x, y       float64 // start のときの中心
+	vx, vy     float64
+	half       float64 // 一辺の半分
+	brightness byte
+}
+
+// center は frame のときの正方形の中心です。
+func (sq movingSquare) center(frame int) (float64, float64) {
+	t := float64(frame - sq.start)
+	return sq.x + sq.vx*t, sq.y + sq.vy*t
+}
+
+func (s *squareSource) ReadFrame(dst []byte) ([]byte, int, int, bool) {
+	n := s.width * s.height * 4
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	for y := 0; y < s.height; y++ {
+		for x := 0; x < s.width; x++ {
+			v := byte(40)
+			for _, sq := range s.squares {
+				cx, cy := sq.center(s.frame)
+				if s.frame >= sq.start && math.Abs(float64(x)+0.5-cx) < sq.half && math.Abs(float64(y)+0.5-cy) < sq.half {
+					v = sq.brightness
+				}
+			}
+			i := (y*s.width + x) * 4
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = v, v, v, 255
+		}
+	}
+	s.frame++
+	return dst, s.width, s.height, true
+}
+
+func TestTrackerFollowsSquares(t *testing.T) {
+	source := &squareSource{width: 160, height: 120, squares: []movingSquare{
+		{start: 2, x: 40, y: 40, vx: 2, vy: 0, half: 12, brightness: 220},
+		{start: 12, x: 120, y: 90, vx: 0, vy: -1, half: 10, brightness: 160},
+	}}
+	tracker := NewTracker(source, DefaultConfig())
+
+	// 出力座標は映像の2倍
+	const scale = 2
+	for frame := 0; frame < 20; frame++ {
+		tracker.Update(160*scale, 120*scale)
+	}
+	last := source.frame - 1
+
+	tracks := tracker.Tracks()
+	if len(tracks) != 2 {
+		t.Fatalf("動体の数 = %d, want 2: %+v", len(tracks), tracks)
+	}
+	for i, tr := range tracks {
+		if tr.ID != i+1 {
+			t.Errorf("tracks[%d].ID = %d, want %d（現れた順に振る）", i, tr.ID, i+1)
+		}
+		cx, cy := source.squares[i].center(last)
+		// 位置はセル（8ピクセル）単位なので、1セル分までのずれは許す
+		if math.Abs(tr.X-cx*scale) > 8*scale || math.Abs(tr.Y-cy*scale) > 8*scale {
+			t.Errorf("ID %d の位置 = (%.1f, %.1f), want (%.1f, %.1f)", tr.ID, tr.X, tr.Y, cx*scale, cy*scale)
+		}
+		if tr.Missed != 0 {
+			t.Errorf("ID %d を見失っています", tr.ID)
+		}
+	}
+	if tracks[0].VX <= 0 || math.Abs(tracks[0].VY) > 0.5 {
+		t.Errorf("右に動く正方形の速度 = (%.2f, %.2f)", tracks[0].VX, tracks[0].VY)
+	}
+
+	primary, ok := tracker.Primary()
+	if !ok || primary.ID != 1 {
+		t.Errorf("Primary = %+v, %v, want 大きい方の ID 1", primary, ok)
+	}
+}
+
+func TestTrackerMirror(t *testing.T) {
+	square := movingSquare{start: 1, x: 40, y: 60, half: 12, brightness: 220}
+	config := DefaultConfig()
+	config.Mirror = true
+	tracker := NewTracker(&squareSource{width: 160, height: 120, squares: []movingSquare{square}}, config)
+	for frame := 0; frame < 5; frame++ {
+		tracker.Update(160, 120)
+	}
+	primary, ok := tracker.Primary()
+	if !ok {
+		t.Fatal("動体が見つかりません")
+	}
+	if math.Abs(primary.X-(160-40)) > 8 || math.Abs(primary.Y-60) > 8 {
+		t.Errorf("左右反転した位置 = (%.1f, %.1f), want (120, 60)", primary.X, primary.Y)
+	}
+}
+
+func TestTrackerSyntheticSource(t *testing.T) {
+	source := NewSyntheticSource(SyntheticWidth, SyntheticHeight, 1)
+	config := DefaultConfig()
+	config.UseBackgroundModel = false // 最初のフレームの図形が背景に残らないように前フレームとの差分を使う
+	tracker := NewTracker(source, config)
+	for frame := 0; frame < 30; frame++ {
+		tracker.Update(SyntheticWidth, SyntheticHeight)
+	}
+
+	// 動いている図形にはそれぞれ近くに見えている動体がある
+	for _, sh := range source.shapes {
+		found := false
+		for _, tr := range tracker.Tracks() {
+			if tr.Missed == 0 && math.Hypot(tr.X-sh.x, tr.Y-sh.y) < sh.radius+float64(config.BlobCellSize) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("(%.0f, %.0f) の図形に対応する動体がありません: %+v", sh.x, sh.y, tracker.Tracks())
+		}
+	}
+
+	// 同じシードなら同じ結果になる
+	again := NewTracker(NewSyntheticSource(SyntheticWidth, SyntheticHeight, 1), config)
+	for frame := 0; frame < 30; frame++ {
+		again.Update(SyntheticWidth, SyntheticHeight)
+	}
+	if a, b := tracker.Tracks(), again.Tracks(); len(a) != len(b) || (len(a) > 0 && a[0] != b[0]) {
+		t.Errorf("同じシードで結果が違います: %+v / %+v", a, b)
+	}
+}
+
+func TestBackgroundModel(t *testing.T) {
+	const width, height = 4, 1
+	frame := []byte{
+		50, 50, 50, 255,
+		50, 50, 50, 255,
+		50, 50, 50, 255,
+		50, 50, 50, 255,
+	}
+	model := NewBackgroundModel(0.02)
+	fg := model.Apply(nil, frame, width, height)
+	for i, w := range fg {
+		if w != 0 {
+			t.Errorf("最初のフレームの前景[%d] = %v, want 0", i, w)
+		}
+	}
+
+	frame[8], frame[9], frame[10] = 200, 200, 200
+	fg = model.Apply(fg, frame, width, height)
+	if fg[2] != 450 {
+		t.Errorf("変わったピクセルの前景の重み = %v, want 450", fg[2])
+	}
+	if fg[0] != 0 || fg[1] != 0 || fg[3] != 0 {
+		t.Errorf("変わっていないピクセルの前景 = %v", fg)
+	}
+}
+
+func TestExtractBlobs(t *testing.T) {
+	const cols, rows = 8, 4
+	mask := make([]float64, cols*rows)
+	set := func(cells ...[2]int) {
+		for _, c := range cells {
+			mask[c[1]*cols+c[0]] = 1
+		}
+	}
+	set([2]int{0, 0}, [2]int{1, 0}, [2]int{0, 1}, [2]int{1, 1}) // 左上の 2×2
+	set([2]int{5, 2}, [2]int{6, 3}, [2]int{7, 2}, [2]int{6, 2}) // 斜めにもつながる右下の塊
+	set([2]int{3, 3})                                           // 小さすぎる塊
+
+	blobs := ExtractBlobs(mask, cols, rows, 10, 10, 2)
+	want := []Blob{
+		{X: 10, Y: 10, Area: 400},
+		{X: 65, Y: 27.5, Area: 400},
+	}
+	if len(blobs) != len(want) {
+		t.Fatalf("blobs = %+v, want %+v", blobs, want)
+	}
+	for i := range want {
+		if math.Abs(blobs[i].X-want[i].X) > 1e-9 || math.Abs(blobs[i].Y-want[i].Y) > 1e-9 || blobs[i].Area != want[i].Area {
+			t.Errorf("blobs[%d] = %+v, want %+v", i, blobs[i], want[i])
+		}
+	}
+}
+
+func TestPNGSequenceSource(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i, v := range []uint8{10, 20} {
+		img := image.NewGray(image.Rect(0, 0, 2, 1))
+		img.SetGray(1, 0, color.Gray{Y: v})
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		fsys[[]string{"b.png", "a.png"}[i]] = &fstest.MapFile{Data: buf.Bytes()}
+	}
+
+	source, err := NewPNGSequenceSource(fsys, "*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 名前順に再生し、最後まで行ったら先頭に戻る
+	for _, want := range []byte{20, 10, 20} {
+		frame, width, height, ok := source.ReadFrame(nil)
+		if !ok || width != 2 || height != 1 || frame[4] != want {
+			t.Errorf("ReadFrame = %v, %d, %d, %v, want 2 番目のピクセルが %d", frame, width, height, ok, want)
+		}
+	}
+
+	if _, _, _, ok := (&PNGSequenceSource{}).ReadFrame(nil); ok {
+		t.Error("読み込み前の ReadFrame が ok を返しました")
+	}
+}
+
+func TestSequenceURLs(t *testing.T) {
+	urls, err := sequenceURLs("/frames/%03d.png", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/frames/000.png", "/frames/001.png", "/frames/002.png"}; !slices.Equal(urls, want) {
+		t.Errorf("urls = %v, want %v", urls, want)
+	}
+
+	tests := []struct{ frames, count string }{
+		{"/a/%d.png", ""},
+		{"/a/%d.png", "abc"},
+		{"/a/%d.png", "0"},
+		{"/a/%d.png", "-1"},
+		{"/a/%d.png", "1.5"},
+		{"/a/%d.png", "10001"},
+		{"", "3"},
+		{"/a/0.png", "3"},
+		{"/a/%%.png", "3"},
+		{"/a/%s.png", "3"},
+		{"/a/%d-%d.png", "3"},
+	}
+	for _, tt := range tests {
+		if urls, err := sequenceURLs(tt.frames, tt.count); err == nil {
+			t.Errorf("sequenceURLs(%q, %q) = %v, want エラー", tt.frames, tt.count, urls)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package motion
+
+import (
+	"syscall/js"
+
+	"github.com/ryomak/p5go"
+)
+
+// Pointer は、動体の位置をマウスの代わりに使うための入力です。
+// 動体が見えている間は最も大きな動体の滑らかな位置を、見えないときや
+// カメラがないときはマウスの位置を返すので、p.MouseX()/p.MouseY() をそのまま置き換えられます。
+type Pointer struct {
+	tracker    *Tracker
+	x, y       float64
+	fromMotion bool
+}
+
+// NewPointer は、tracker の動体を追いかける Pointer を生成します。
+// tracker が nil なら常にマウスの位置を返します。
+func NewPointer(tracker *Tracker) *Pointer {
+	return &Pointer{tracker: tracker}
+}
+
+// NewCameraPointer は、Webカメラの映像を左右反転して追いかける Pointer を生成します。
+// ブラウザがカメラに対応していなければマウスだけを使う Pointer を返します。
+func NewCameraPointer(c *p5go.Canvas) *Pointer {
+	if !CameraAvailable() {
+		return NewPointer(nil)
+	}
+	config := DefaultConfig()
+	config.Mirror = true // カメラに向かって動いた方向にポインタが動くように
+	return NewPointer(NewTracker(NewCameraSource(c), config))
+}
+
+// Update は、フレームごとに一度呼び出して位置を更新します。
+func (p *Pointer) Update(c *p5go.Canvas) {
+	if p.tracker != nil {
+		p.tracker.Update(c.Width(), c.Height())
+		if track, ok := p.tracker.Primary(); ok {
+			p.x, p.y = track.SmoothX, track.SmoothY
+			p.fromMotion = true
+			return
+		}
+	}
+	// 動体が見えなければマウスに戻す
+	p.x, p.y = c.MouseX(), c.MouseY()
+	p.fromMotion = false
+}
+
+// X は、ポインタの x 座標を返します。
+func (p *Pointer) X() float64 {
+	return p.x
+}
+
+// Y は、ポインタの y 座標を返します。
+func (p *Pointer) Y() float64 {
+	return p.y
+}
+
+// FromMotion は、現在の位置が動体から得たものなら true を返します。
+func (p *Pointer) FromMotion() bool {
+	return p.fromMotion
+}
+
+// Now は、ページを開いてからの経過時間を秒で返します（GestureRecognizer.Add の時刻に使う）。
+func Now() float64 {
+	return js.Global().Get("performance").Call("now").Float() / 1000
+}
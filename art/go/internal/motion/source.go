@@ -0,0 +1,217 @@
+package motion
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 合成映像のパラメータ
+const (
+	SyntheticWidth      = 320 // 合成映像の既定の幅
+	SyntheticHeight     = 240 // 合成映像の既定の高さ
+	syntheticShapeCount = 3   // 動く図形の数
+	syntheticNoise      = 8   // 各ピクセルに加えるノイズの最大値
+)
+
+// FrameSource は動き検出に使う映像の入力元を表します。
+// ReadFrame は次のフレームを RGBA で dst に書き込み（容量が足りなければ確保し直し）、
+// そのバッファと幅・高さを返します。フレームの準備ができていなければ ok は false です。
+type FrameSource interface {
+	ReadFrame(dst []byte) (frame []byte, width, height int, ok bool)
+}
+
+// SyntheticSource は、模様の入った図形が背景の上を動き回る合成映像を生成します。
+// 同じシードからは常に同じ映像が得られるので、カメラなしでの開発や動作確認に使えます。
+type SyntheticSource struct {
+	width, height int
+	shapes        []syntheticShape
+	rng           *rand.Rand
+}
+
+// syntheticShape は合成映像の中を動く図形を表します。
+type syntheticShape struct {
+	x, y, vx, vy float64
+	radius       float64
+	color        [3]byte
+	square       bool // true なら正方形、false なら円
+}
+
+// NewSyntheticSource は、width × height の合成映像を seed から生成します。
+func NewSyntheticSource(width, height int, seed int64) *SyntheticSource {
+	rng := rand.New(rand.NewSource(seed))
+	s := &SyntheticSource{width: width, height: height, rng: rng}
+	for i := 0; i < syntheticShapeCount; i++ {
+		radius := 15 + rng.Float64()*25
+		s.shapes = append(s.shapes, syntheticShape{
+			x:      radius + rng.Float64()*(float64(width)-2*radius),
+			y:      radius + rng.Float64()*(float64(height)-2*radius),
+			vx:     (rng.Float64()*2 - 1) * 4,
+			vy:     (rng.Float64()*2 - 1) * 4,
+			radius: radius,
+			color:  [3]byte{byte(100 + rng.Intn(140)), byte(100 + rng.Intn(140)), byte(100 + rng.Intn(140))},
+			square: rng.Intn(2) == 0,
+		})
+	}
+	return s
+}
+
+// ReadFrame は、図形を1フレーム分動かしてから描き込みます。
+func (s *SyntheticSource) ReadFrame(dst []byte) ([]byte, int, int, bool) {
+	n := s.width * s.height * 4
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+
+	// 図形を動かし、端で跳ね返す
+	for i := range s.shapes {
+		sh := &s.shapes[i]
+		sh.x += sh.vx
+		sh.y += sh.vy
+		if sh.x < sh.radius || sh.x > float64(s.width)-sh.radius {
+			sh.vx = -sh.vx
+		}
+		if sh.y < sh.radius || sh.y > float64(s.height)-sh.radius {
+			sh.vy = -sh.vy
+		}
+	}
+
+	for y := 0; y < s.height; y++ {
+		for x := 0; x < s.width; x++ {
+			// 背景：動かない斜めの縞模様
+			v := byte(40 + ((x+y)/6%2)*20)
+			r, g, b := v, v, v
+			for _, sh := range s.shapes {
+				dx := float64(x) - sh.x
+				dy := float64(y) - sh.y
+				inside := dx*dx+dy*dy < sh.radius*sh.radius
+				if sh.square {
+					inside = math.Abs(dx) < sh.radius && math.Abs(dy) < sh.radius
+				}
+				if inside {
+					// 図形に貼りつく市松模様（オプティカルフローが手がかりにできるように）
+					shade := byte(0)
+					if (int(dx+100)/5+int(dy+100)/5)%2 == 0 {
+						shade = 60
+					}
+					r, g, b = sh.color[0]-shade, sh.color[1]-shade, sh.color[2]-shade
+				}
+			}
+			// カメラのようなわずかなノイズ
+			noise := byte(s.rng.Intn(syntheticNoise + 1))
+			i := (y*s.width + x) * 4
+			dst[i] = r + noise
+			dst[i+1] = g + noise
+			dst[i+2] = b + noise
+			dst[i+3] = 255
+		}
+	}
+	return dst, s.width, s.height, true
+}
+
+// PNGSequenceSource は、PNG の連番画像を1フレームずつ順に（最後まで行ったら先頭に戻って）再生します。
+// LoadURLs で別の goroutine から読み込んでいる間も ReadFrame を呼び出せます。
+type PNGSequenceSource struct {
+	mu     sync.Mutex // frames を守る
+	frames []*image.RGBA
+	index  int
+}
+
+// NewPNGSequenceSource は、fsys の中で pattern に一致する PNG を名前順に読み込みます。
+func NewPNGSequenceSource(fsys fs.FS, pattern string) (*PNGSequenceSource, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	files := make([][]byte, 0, len(names))
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, data)
+	}
+
+	s := &PNGSequenceSource{}
+	if err := s.decode(files); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// maxSequenceFrames は URL で指定できる PNG 連番の枚数の上限です。
+const maxSequenceFrames = 10000
+
+// sequenceURLs は、fmt 形式のパス frames（/a/%03d.png など）に 0 から count-1 の番号を入れた URL を返します。
+// count が 1～maxSequenceFrames の整数でないときや、frames に番号を入れる書式がないときはエラーを返します。
+func sequenceURLs(frames, count string) ([]string, error) {
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 || n > maxSequenceFrames {
+		return nil, fmt.Errorf("count %q は 1～%d の整数にしてください", count, maxSequenceFrames)
+	}
+	// 書式がないと %!(EXTRA int=0) が、数でない書式だと %!s(int=0) が混ざる
+	if !strings.Contains(frames, "%") || strings.Contains(fmt.Sprintf(frames, 0), "%!") {
+		return nil, fmt.Errorf("frames %q に番号を入れる %%d などの書式がありません", frames)
+	}
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf(frames, i)
+	}
+	return urls, nil
+}
+
+// decode は、PNG のバイト列をすべて RGBA 画像に変換します。
+// 読み込みが終わるまで ReadFrame はフレームを返しません。
+func (s *PNGSequenceSource) decode(files [][]byte) error {
+	frames := make([]*image.RGBA, 0, len(files))
+	for i, data := range files {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		bounds := img.Bounds()
+		rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				rgba.Set(x, y, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			}
+		}
+		frames = append(frames, rgba)
+	}
+	// 全部読み込めてから一度に入れ替える
+	s.mu.Lock()
+	s.frames = frames
+	s.mu.Unlock()
+	return nil
+}
+
+// ReadFrame は、次のフレームを dst にコピーします。
+func (s *PNGSequenceSource) ReadFrame(dst []byte) ([]byte, int, int, bool) {
+	s.mu.Lock()
+	frames := s.frames
+	s.mu.Unlock()
+	if len(frames) == 0 {
+		return dst, 0, 0, false
+	}
+	img := frames[s.index%len(frames)]
+	s.index++
+
+	n := len(img.Pix)
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	copy(dst, img.Pix)
+	return dst, img.Rect.Dx(), img.Rect.Dy(), true
+}
@@ -0,0 +1,136 @@
+package motion
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/ryomak/p5go"
+)
+
+// SourceFromURL は、ページの URL のクエリで指定された入力元を生成します。
+//
+//	?source=camera（既定）            Webカメラ
+//	?source=synthetic&seed=1         動く図形の合成映像（カメラの許可が不要）
+//	?source=png&frames=/a/%03d.png&count=30  PNG 連番（frames は fmt 形式のパス）
+//
+// PNG 連番の frames か count が正しくなければ、Webカメラに戻します。
+func SourceFromURL(c *p5go.Canvas) FrameSource {
+	query, _ := url.ParseQuery(strings.TrimPrefix(js.Global().Get("location").Get("search").String(), "?"))
+
+	switch query.Get("source") {
+	case "synthetic":
+		seed, _ := strconv.ParseInt(query.Get("seed"), 10, 64)
+		return NewSyntheticSource(SyntheticWidth, SyntheticHeight, seed)
+	case "png":
+		urls, err := sequenceURLs(query.Get("frames"), query.Get("count"))
+		if err != nil {
+			js.Global().Get("console").Call("error", "source=png: "+err.Error())
+			break
+		}
+		src := &PNGSequenceSource{}
+		go src.LoadURLs(urls)
+		return src
+	}
+	return NewCameraSource(c)
+}
+
+// CameraAvailable は、ブラウザが Webカメラの取得（getUserMedia）に対応しているかを返します。
+func CameraAvailable() bool {
+	devices := js.Global().Get("navigator").Get("mediaDevices")
+	return devices.Truthy() && devices.Get("getUserMedia").Truthy()
+}
+
+// CameraSource は p5.js の CreateCapture で取得した Webカメラ映像を入力元にします。
+type CameraSource struct {
+	video js.Value
+}
+
+// NewCameraSource は、Webカメラ映像を取得して非表示の video 要素として保持します。
+func NewCameraSource(c *p5go.Canvas) *CameraSource {
+	video := c.CreateCapture("VIDEO")
+	video.Call("hide") // video要素自体は非表示
+	return &CameraSource{video: video}
+}
+
+// ReadFrame は、映像のピクセルデータを dst に一括でコピーします。
+// Go と JS の境界をまたぐのは1フレームにつき1回だけです。
+func (s *CameraSource) ReadFrame(dst []byte) ([]byte, int, int, bool) {
+	s.video.Call("loadPixels")
+	pixels := s.video.Get("pixels")
+	if pixels.IsUndefined() || pixels.IsNull() {
+		return dst, 0, 0, false
+	}
+	n := pixels.Get("length").Int()
+	width := s.video.Get("width").Int()
+	if n == 0 || width == 0 {
+		return dst, 0, 0, false
+	}
+
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	js.CopyBytesToGo(dst, pixels)
+	return dst, width, n / 4 / width, true
+}
+
+// LoadURLs は、ブラウザの fetch で urls の PNG を読み込みます。
+// JS の Promise の完了を待つので、メインの処理とは別の goroutine から呼び出してください。
+func (s *PNGSequenceSource) LoadURLs(urls []string) {
+	files := make([][]byte, 0, len(urls))
+	for _, u := range urls {
+		data, err := fetchBytes(u)
+		if err != nil {
+			js.Global().Get("console").Call("error", err.Error())
+			return
+		}
+		files = append(files, data)
+	}
+	if err := s.decode(files); err != nil {
+		js.Global().Get("console").Call("error", err.Error())
+	}
+}
+
+// fetchBytes は、ブラウザの fetch で url の内容をバイト列として取得します。
+func fetchBytes(u string) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+
+	var onBuffer, onResponse, onError js.Func
+	release := func() {
+		onBuffer.Release()
+		onResponse.Release()
+		onError.Release()
+	}
+	onBuffer = js.FuncOf(func(this js.Value, args []js.Value) any {
+		array := js.Global().Get("Uint8Array").New(args[0])
+		data := make([]byte, array.Get("length").Int())
+		js.CopyBytesToGo(data, array)
+		done <- result{data: data}
+		return nil
+	})
+	onError = js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{err: fmt.Errorf("fetch %s: %s", u, args[0].Call("toString").String())}
+		return nil
+	})
+	onResponse = js.FuncOf(func(this js.Value, args []js.Value) any {
+		response := args[0]
+		if !response.Get("ok").Bool() {
+			done <- result{err: fmt.Errorf("fetch %s: status %d", u, response.Get("status").Int())}
+			return nil
+		}
+		response.Call("arrayBuffer").Call("then", onBuffer, onError)
+		return nil
+	})
+
+	js.Global().Call("fetch", u).Call("then", onResponse, onError)
+	r := <-done
+	release()
+	return r.data, r.err
+}
@@ -0,0 +1,209 @@
+package motion
+
+import (
+	"math"
+	"sort"
+)
+
+// Track はフレームをまたいで追跡している動体を表します。
+// 位置と速度は Tracker.Update に渡した出力座標（通常はキャンバス座標）で表します。
+type Track struct {
+	ID               int
+	X, Y             float64 // 最新の位置
+	VX, VY           float64 // 1フレームあたりの速度
+	Area             float64 // 最新の面積
+	SmoothX, SmoothY float64 // EMA による滑らかな位置（ポインタ）
+	Missed           int     // 見失っているフレーム数
+}
+
+// Tracker は、映像の入力元から読み込んだフレームで動体を検出し、ID つきで追跡します。
+type Tracker struct {
+	config Config
+	source FrameSource
+
+	cur, prev     []byte // 現フレームと前フレーム（RGBA）
+	hasCur        bool   // cur に有効なデータが入っているか
+	hasPrev       bool   // prev に有効なデータが入っているか
+	width, height int    // 映像のサイズ（ピクセル）
+
+	background         BackgroundModel
+	foreground         []float64 // ピクセルごとの前景の重み（背景なら 0）
+	mask               []float64 // セルごとの前景の重み（動きなしのセルは 0）
+	maskCols, maskRows int
+
+	tracks []Track
+	nextID int
+}
+
+// NewTracker は、source から映像を読み込む Tracker を生成します。
+func NewTracker(source FrameSource, config Config) *Tracker {
+	return &Tracker{
+		config:     config,
+		source:     source,
+		background: NewBackgroundModel(config.LearningRate),
+		nextID:     1,
+	}
+}
+
+// Update は、次のフレームを読み込んで動体の検出と追跡を1ステップ進めます。
+// 位置は映像を outW × outH の出力座標に引き伸ばして表します。
+// 新しいフレームが読み込めなければ false を返し、動体は前回のまま保持します。
+func (t *Tracker) Update(outW, outH float64) bool {
+	// 前回のフレームを前フレームとして残す（バッファを入れ替えて再利用）
+	if t.hasCur {
+		t.cur, t.prev = t.prev, t.cur
+		t.hasPrev = true
+	}
+
+	frame, width, height, ok := t.source.ReadFrame(t.cur)
+	t.cur = frame
+	t.hasCur = ok
+	if !ok {
+		return false
+	}
+	if width != t.width || height != t.height {
+		// 映像サイズが変わったら前フレームは使えない
+		t.width, t.height = width, height
+		t.hasPrev = false
+	}
+
+	// 背景モデルを使う場合は静止している人も前景として残る
+	if t.config.UseBackgroundModel {
+		t.foreground = t.background.Apply(t.foreground, t.cur, width, height)
+	} else if t.hasPrev {
+		t.foreground = FrameDifference(t.foreground, t.cur, t.prev, width, height, t.config.Threshold)
+	} else {
+		return true
+	}
+
+	t.mask, t.maskCols, t.maskRows = BuildMask(t.mask, t.foreground, width, height,
+		t.config.BlobCellSize, t.config.SampleStep, t.config.BlobFillRatio)
+
+	// セルの大きさを出力座標に換算
+	cellW := float64(t.config.BlobCellSize) * outW / float64(width)
+	cellH := float64(t.config.BlobCellSize) * outH / float64(height)
+	blobs := ExtractBlobs(t.mask, t.maskCols, t.maskRows, cellW, cellH, t.config.MinBlobCells)
+	if t.config.Mirror {
+		for i := range blobs {
+			blobs[i].X = outW - blobs[i].X
+		}
+	}
+	t.match(blobs)
+
+	// EMA を用いて動体ごとのポインタを滑らかに更新
+	a := t.config.Smoothing
+	for i := range t.tracks {
+		tr := &t.tracks[i]
+		tr.SmoothX = (1-a)*tr.SmoothX + a*tr.X
+		tr.SmoothY = (1-a)*tr.SmoothY + a*tr.Y
+	}
+	return true
+}
+
+// match は、検出したブロブを既存の動体に対応づけます。
+// 予測位置（前回位置＋速度）に近い組から順に貪欲に割り当て、余ったブロブは新しい ID で追跡を始めます。
+// 対応がつかなかった動体は MaxMissedFrames を超えるまで予測位置で保持します。
+func (t *Tracker) match(blobs []Blob) {
+	type pair struct {
+		track, blob int
+		dist        float64
+	}
+	var pairs []pair
+	for ti, tr := range t.tracks {
+		px, py := tr.X+tr.VX, tr.Y+tr.VY
+		for bi, b := range blobs {
+			if d := math.Hypot(b.X-px, b.Y-py); d < t.config.MaxMatchDistance {
+				pairs = append(pairs, pair{ti, bi, d})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].dist < pairs[j].dist })
+
+	a := t.config.VelocitySmoothing
+	trackUsed := make([]bool, len(t.tracks))
+	blobUsed := make([]bool, len(blobs))
+	for _, pr := range pairs {
+		if trackUsed[pr.track] || blobUsed[pr.blob] {
+			continue
+		}
+		trackUsed[pr.track] = true
+		blobUsed[pr.blob] = true
+
+		tr := &t.tracks[pr.track]
+		b := blobs[pr.blob]
+		tr.VX = (1-a)*tr.VX + a*(b.X-tr.X)
+		tr.VY = (1-a)*tr.VY + a*(b.Y-tr.Y)
+		tr.X, tr.Y = b.X, b.Y
+		tr.Area = b.Area
+		tr.Missed = 0
+	}
+
+	result := t.tracks[:0]
+	for ti := range t.tracks {
+		tr := t.tracks[ti]
+		if !trackUsed[ti] {
+			// 見失った動体は速度を弱めながら予測位置へ進める
+			tr.Missed++
+			tr.X += tr.VX
+			tr.Y += tr.VY
+			tr.VX *= 0.5
+			tr.VY *= 0.5
+			if tr.Missed > t.config.MaxMissedFrames {
+				continue
+			}
+		}
+		result = append(result, tr)
+	}
+
+	for bi, b := range blobs {
+		if blobUsed[bi] {
+			continue
+		}
+		result = append(result, Track{
+			ID:      t.nextID,
+			X:       b.X,
+			Y:       b.Y,
+			Area:    b.Area,
+			SmoothX: b.X,
+			SmoothY: b.Y,
+		})
+		t.nextID++
+	}
+	t.tracks = result
+}
+
+// Tracks は、追跡中の動体を返します。
+func (t *Tracker) Tracks() []Track {
+	return t.tracks
+}
+
+// Primary は、いま見えている動体のうち最も面積の大きいものを返します。
+// 見えている動体がなければ ok は false です。
+func (t *Tracker) Primary() (track Track, ok bool) {
+	for _, tr := range t.tracks {
+		if tr.Missed > 0 {
+			continue
+		}
+		if !ok || tr.Area > track.Area {
+			track, ok = tr, true
+		}
+	}
+	return track, ok
+}
+
+// Frame は、直近に読み込んだフレーム（RGBA）とその幅・高さを返します。
+// まだ読み込めていなければ ok は false です。
+func (t *Tracker) Frame() (frame []byte, width, height int, ok bool) {
+	return t.cur, t.width, t.height, t.hasCur
+}
+
+// Mask は、直近のフレームのセルごとの前景の重みと、その列数・行数を返します。
+// マスクは Mirror の設定にかかわらず映像と同じ向きです。
+func (t *Tracker) Mask() ([]float64, int, int) {
+	return t.mask, t.maskCols, t.maskRows
+}
+
+// Config は、Tracker の設定を返します。
+func (t *Tracker) Config() Config {
+	return t.config
+}
@@ -0,0 +1,138 @@
+package overworld
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ryomak/sketch/art/internal/encounter"
+)
+
+// kindOf はテキスト形式のタイルの文字を種類にします。
+//
+//	.  地面
+//	,  草むら（" でもよい）
+//	#  障害物
+//	@  スタート地点（地面）
+func kindOf(r rune) (Kind, bool) {
+	switch r {
+	case '.', '@':
+		return Ground, true
+	case ',', '"':
+		return TallGrass, true
+	case '#':
+		return Block, true
+	}
+	return 0, false
+}
+
+// ParseText はテキスト形式のマップを読み込みます。テキストの1行がマップの1行で、空行は読み飛ばします。
+// 先頭に "biome: forest" の行を書くと全体のバイオームになります（省略すると草原）。
+func ParseText(text string) (*Map, error) {
+	biome := encounter.Grassland
+	var rows []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if name, ok := strings.CutPrefix(line, "biome:"); ok && len(rows) == 0 {
+			b, ok := encounter.ParseBiome(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("不明なバイオーム %q", strings.TrimSpace(name))
+			}
+			biome = b
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		rows = append(rows, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return buildMap(rows, nil, biome)
+}
+
+// mapJSON は JSON 形式のマップです。
+//
+//	{
+//	  "biome": "grassland",
+//	  "tiles":  ["#####", "#@.,#", "#####"],
+//	  "biomes": ["00000", "01230", "00000"]
+//	}
+//
+// tiles はテキスト形式と同じ文字で書きます。biomes は省略でき、書くならタイルごとに
+// バイオームの番号（0: 草原 … 7: 砂漠）を tiles と同じ大きさで並べます。
+type mapJSON struct {
+	Biome  string   `json:"biome"`
+	Tiles  []string `json:"tiles"`
+	Biomes []string `json:"biomes"`
+}
+
+// ParseJSON は JSON 形式のマップを読み込みます。
+func ParseJSON(b []byte) (*Map, error) {
+	var data mapJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	biome := encounter.Grassland
+	if data.Biome != "" {
+		var ok bool
+		if biome, ok = encounter.ParseBiome(data.Biome); !ok {
+			return nil, fmt.Errorf("不明なバイオーム %q", data.Biome)
+		}
+	}
+	return buildMap(data.Tiles, data.Biomes, biome)
+}
+
+// buildMap は行ごとのタイルの文字からマップを作ります。
+func buildMap(rows, biomeRows []string, biome encounter.Biome) (*Map, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("タイルがありません")
+	}
+	if biomeRows != nil && len(biomeRows) != len(rows) {
+		return nil, fmt.Errorf("biomes の行数 %d が tiles の行数 %d と違います", len(biomeRows), len(rows))
+	}
+	width := len([]rune(rows[0]))
+	m := &Map{Width: width, Height: len(rows), Tiles: make([]Tile, 0, width*len(rows))}
+	hasStart := false
+	for y, row := range rows {
+		cells := []rune(row)
+		if len(cells) != width {
+			return nil, fmt.Errorf("%d 行目の幅 %d が1行目の幅 %d と違います", y+1, len(cells), width)
+		}
+		var biomeCells []rune
+		if biomeRows != nil {
+			biomeCells = []rune(biomeRows[y])
+			if len(biomeCells) != width {
+				return nil, fmt.Errorf("biomes の %d 行目の幅が tiles と違います", y+1)
+			}
+		}
+		for x, r := range cells {
+			kind, ok := kindOf(r)
+			if !ok {
+				return nil, fmt.Errorf("%d 行 %d 列: 不明なタイル %q", y+1, x+1, r)
+			}
+			tile := Tile{Kind: kind, Biome: biome}
+			if biomeCells != nil {
+				n := int(biomeCells[x] - '0')
+				if n < 0 || n >= encounter.Biomes {
+					return nil, fmt.Errorf("biomes の %d 行 %d 列: 不明なバイオーム %q", y+1, x+1, biomeCells[x])
+				}
+				tile.Biome = encounter.Biome(n)
+			}
+			if r == '@' {
+				if hasStart {
+					return nil, fmt.Errorf("%d 行 %d 列: スタート地点 @ が2つあります", y+1, x+1)
+				}
+				m.Start, hasStart = Point{x, y}, true
+			}
+			m.Tiles = append(m.Tiles, tile)
+		}
+	}
+	if !hasStart {
+		return nil, fmt.Errorf("スタート地点 @ がありません")
+	}
+	return m, nil
+}
@@ -0,0 +1,103 @@
+package overworld
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ryomak/sketch/art/internal/encounter"
+)
+
+// GenerateConfig はマップ生成の設定です。
+type GenerateConfig struct {
+	Width, Height int
+	GrassPatches  int     // 草むらのかたまりの数
+	BlockRatio    float64 // 障害物を置く割合
+}
+
+// DefaultGenerateConfig は 64×64 のマップの設定を返します。
+func DefaultGenerateConfig() GenerateConfig {
+	return GenerateConfig{Width: 64, Height: 64, GrassPatches: 40, BlockRatio: 0.06}
+}
+
+// Generate は seed からマップを生成します。同じ seed と設定からはいつも同じマップになります。
+//
+// 8つのバイオームの中心をばらまいて、各タイルを一番近い中心のバイオームにし、
+// 草むらのかたまりと障害物を置きます。外周は障害物で囲み、スタート地点から
+// 歩いて行けないところは障害物で埋めます。
+func Generate(seed int64, config GenerateConfig) *Map {
+	rng := rand.New(rand.NewSource(seed))
+	w, h := max(config.Width, 8), max(config.Height, 8)
+	m := &Map{Width: w, Height: h, Tiles: make([]Tile, w*h), Start: Point{w / 2, h / 2}}
+
+	// バイオームの境目がまっすぐにならないよう、距離にゆらぎを足す
+	centers := make([][2]float64, encounter.Biomes)
+	for i := range centers {
+		centers[i] = [2]float64{rng.Float64() * float64(w), rng.Float64() * float64(h)}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			best, bestDistance := 0, math.Inf(1)
+			for i, c := range centers {
+				wobble := math.Sin(float64(x)*0.3+float64(i)) * math.Cos(float64(y)*0.3-float64(i)) * 3
+				if d := math.Hypot(float64(x)-c[0], float64(y)-c[1]) + wobble; d < bestDistance {
+					best, bestDistance = i, d
+				}
+			}
+			m.Tiles[y*w+x].Biome = encounter.Biome(best)
+		}
+	}
+
+	// 草むらのかたまり
+	for i := 0; i < config.GrassPatches; i++ {
+		cx, cy := rng.Intn(w), rng.Intn(h)
+		r := 2 + rng.Float64()*2.5
+		for y := cy - 5; y <= cy+5; y++ {
+			for x := cx - 5; x <= cx+5; x++ {
+				if m.In(x, y) && math.Hypot(float64(x-cx), float64(y-cy)) <= r {
+					m.Tiles[y*w+x].Kind = TallGrass
+				}
+			}
+		}
+	}
+
+	// 障害物と外周
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x == 0 || y == 0 || x == w-1 || y == h-1 || rng.Float64() < config.BlockRatio {
+				m.Tiles[y*w+x].Kind = Block
+			}
+		}
+	}
+
+	// スタート地点のまわりは何もない地面にする
+	for y := m.Start.Y - 1; y <= m.Start.Y+1; y++ {
+		for x := m.Start.X - 1; x <= m.Start.X+1; x++ {
+			m.Tiles[y*w+x].Kind = Ground
+		}
+	}
+	m.fillUnreachable()
+	return m
+}
+
+// fillUnreachable は、スタート地点から歩いて行けないタイルを障害物にします。
+func (m *Map) fillUnreachable() {
+	reached := make([]bool, len(m.Tiles))
+	queue := []Point{m.Start}
+	reached[m.Start.Y*m.Width+m.Start.X] = true
+	for len(queue) > 0 {
+		pt := queue[0]
+		queue = queue[1:]
+		for _, d := range []Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			x, y := pt.X+d.X, pt.Y+d.Y
+			if m.Walkable(x, y) && !reached[y*m.Width+x] {
+				reached[y*m.Width+x] = true
+				queue = append(queue, Point{x, y})
+			}
+		}
+	}
+	for i := range m.Tiles {
+		if !reached[i] {
+			m.Tiles[i].Kind = Block
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package overworld は、上から見下ろしたタイルマップ（フィールド）を扱います。
+//
+// マップはシードから生成するか、テキストや JSON のタイル形式から読み込みます。
+// タイルごとにバイオーム（encounter.Biome）と種類（地面・草むら・障害物）を持ち、
+// 草むらに入ると野生のモンスターに出会います。
+package overworld
+
+import (
+	"math"
+
+	"github.com/ryomak/sketch/art/internal/encounter"
+)
+
+// Kind はタイルの種類です。
+type Kind int
+
+const (
+	Ground    Kind = iota // 歩ける地面
+	TallGrass             // 草むら（野生のモンスターが出る）
+	Block                 // 木や岩、水など通れないもの
+)
+
+// Tile はマップの1マスです。
+type Tile struct {
+	Kind  Kind
+	Biome encounter.Biome
+}
+
+// Point はタイルの座標です。
+type Point struct {
+	X, Y int
+}
+
+// Map はタイルマップです。
+type Map struct {
+	Width, Height int
+	Tiles         []Tile // 行優先（y*Width + x）
+	Start         Point  // プレイヤーの最初の位置
+}
+
+// In は (x, y) がマップの中かを返します。
+func (m *Map) In(x, y int) bool {
+	return x >= 0 && x < m.Width && y >= 0 && y < m.Height
+}
+
+// At は (x, y) のタイルを返します。マップの外は障害物です。
+func (m *Map) At(x, y int) Tile {
+	if !m.In(x, y) {
+		return Tile{Kind: Block}
+	}
+	return m.Tiles[y*m.Width+x]
+}
+
+// Walkable は (x, y) に歩いて入れるかを返します。
+func (m *Map) Walkable(x, y int) bool {
+	return m.At(x, y).Kind != Block
+}
+
+// Camera は、プレイヤーのピクセル座標 (px, py) を画面の中央に映す
+// カメラの左上の座標を、マップの外が映らないように返します。
+// マップが画面より小さい向きは、マップを画面の中央に置きます。
+func Camera(px, py, viewW, viewH, mapW, mapH float64) (float64, float64) {
+	clamp := func(center, view, size float64) float64 {
+		if size <= view {
+			return (size - view) / 2
+		}
+		return math.Max(0, math.Min(center-view/2, size-view))
+	}
+	return clamp(px, viewW, mapW), clamp(py, viewH, mapH)
+}
@@ -0,0 +1,221 @@
+package overworld
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ryomak/sketch/art/internal/encounter"
+)
+
+// reachable はスタート地点から歩いて行けるタイルの集合を返します。
+func reachable(m *Map) map[Point]bool {
+	seen := map[Point]bool{m.Start: true}
+	queue := []Point{m.Start}
+	for len(queue) > 0 {
+		pt := queue[0]
+		queue = queue[1:]
+		for _, d := range []Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := Point{pt.X + d.X, pt.Y + d.Y}
+			if m.Walkable(next.X, next.Y) && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	config := DefaultGenerateConfig()
+	for _, seed := range []int64{0, 1, 42, -7} {
+		a, b := Generate(seed, config), Generate(seed, config)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("seed %d: 同じ seed から違うマップができました", seed)
+		}
+	}
+	if reflect.DeepEqual(Generate(1, config).Tiles, Generate(2, config).Tiles) {
+		t.Error("seed 1 と 2 が同じマップになりました")
+	}
+}
+
+func TestGenerateReachable(t *testing.T) {
+	configs := []GenerateConfig{
+		DefaultGenerateConfig(),
+		{Width: 8, Height: 8, GrassPatches: 2, BlockRatio: 0.3},
+		{Width: 40, Height: 20, GrassPatches: 10, BlockRatio: 0.45},
+		{Width: 1, Height: 1}, // 8×8 に広げる
+	}
+	for _, config := range configs {
+		for seed := int64(0); seed < 20; seed++ {
+			m := Generate(seed, config)
+			if len(m.Tiles) != m.Width*m.Height || m.Width < 8 || m.Height < 8 {
+				t.Fatalf("%+v: 大きさ %d×%d、タイル %d 枚", config, m.Width, m.Height, len(m.Tiles))
+			}
+			if !m.Walkable(m.Start.X, m.Start.Y) {
+				t.Errorf("%+v seed %d: スタート地点 %v が歩けません", config, seed, m.Start)
+			}
+			seen := reachable(m)
+			for y := 0; y < m.Height; y++ {
+				for x := 0; x < m.Width; x++ {
+					if m.Walkable(x, y) && !seen[Point{x, y}] {
+						t.Errorf("%+v seed %d: (%d, %d) にスタート地点から行けません", config, seed, x, y)
+					}
+					edge := x == 0 || y == 0 || x == m.Width-1 || y == m.Height-1
+					if edge && m.Walkable(x, y) {
+						t.Errorf("%+v seed %d: 外周の (%d, %d) が歩けます", config, seed, x, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	m, err := ParseText("biome: forest\n\n#####\n#@.,#\n#\"#.#\n#####\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 5 || m.Height != 4 || m.Start != (Point{1, 1}) {
+		t.Errorf("大きさ %d×%d、スタート地点 %v", m.Width, m.Height, m.Start)
+	}
+	tests := []struct {
+		x, y int
+		kind Kind
+	}{
+		{0, 0, Block},
+		{1, 1, Ground},
+		{2, 1, Ground},
+		{3, 1, TallGrass},
+		{1, 2, TallGrass},
+		{2, 2, Block},
+		{-1, 0, Block}, // マップの外
+		{5, 1, Block},
+	}
+	for _, tt := range tests {
+		if got := m.At(tt.x, tt.y).Kind; got != tt.kind {
+			t.Errorf("At(%d, %d) = %v, want %v", tt.x, tt.y, got, tt.kind)
+		}
+	}
+	if m.At(3, 1).Biome != encounter.Forest {
+		t.Errorf("バイオーム = %v, want forest", m.At(3, 1).Biome)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	m, err := ParseJSON([]byte(`{"biome": "cave", "tiles": ["####", "#@,#", "####"], "biomes": ["0000", "0170", "0000"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Start != (Point{1, 1}) {
+		t.Errorf("スタート地点 = %v", m.Start)
+	}
+	if got := m.At(1, 1).Biome; got != encounter.Biome(1) {
+		t.Errorf("(1, 1) のバイオーム = %v", got)
+	}
+	if got := m.At(2, 1).Biome; got != encounter.Biome(7) {
+		t.Errorf("(2, 1) のバイオーム = %v", got)
+	}
+
+	// biomes を省略すると全体が biome になる
+	m, err = ParseJSON([]byte(`{"biome": "cave", "tiles": ["@,"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cave, _ := encounter.ParseBiome("cave"); m.At(1, 0).Biome != cave {
+		t.Errorf("バイオーム = %v, want cave", m.At(1, 0).Biome)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := map[string]string{
+		"空":           "",
+		"@ がない":       "###\n#.#\n###",
+		"@ が2つ":       "####\n#@@#\n####",
+		"幅がそろっていない":   "####\n#@.\n####",
+		"知らない文字":      "###\n#@x\n###",
+		"知らないバイオーム":   "biome: moon\n#@#",
+		"バイオームの行だけ":   "biome: forest\n",
+		"2つ目の @ が別の行": "#@#\n#.#\n#@#",
+	}
+	for name, text := range tests {
+		if m, err := ParseText(text); err == nil {
+			t.Errorf("%s: エラーになりません（%+v）", name, m)
+		}
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	tests := map[string]string{
+		"JSON でない":        `{"tiles": [`,
+		"tiles がない":       `{"biome": "forest"}`,
+		"@ がない":           `{"tiles": ["#.#"]}`,
+		"@ が2つ":           `{"tiles": ["@.", ".@"]}`,
+		"幅がそろっていない":       `{"tiles": ["@..", ".."]}`,
+		"知らないバイオーム":       `{"biome": "moon", "tiles": ["@"]}`,
+		"biomes の行数":      `{"tiles": ["@.", ".."], "biomes": ["00"]}`,
+		"biomes の幅":       `{"tiles": ["@.", ".."], "biomes": ["00", "0"]}`,
+		"biomes の数字が大きい":  `{"tiles": ["@.", ".."], "biomes": ["00", "08"]}`,
+		"biomes が数字でない":   `{"tiles": ["@.", ".."], "biomes": ["0a", "00"]}`,
+		"biomes がマイナスの文字": `{"tiles": ["@.", ".."], "biomes": ["0-", "00"]}`,
+	}
+	for name, text := range tests {
+		if m, err := ParseJSON([]byte(text)); err == nil {
+			t.Errorf("%s: エラーになりません（%+v）", name, m)
+		}
+	}
+}
+
+func TestShippedMaps(t *testing.T) {
+	paths, err := filepath.Glob("../../retro_game/maps/*")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("retro_game/maps が見つかりません: %v", err)
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m *Map
+		if strings.HasSuffix(path, ".json") {
+			m, err = ParseJSON(b)
+		} else {
+			m, err = ParseText(string(b))
+		}
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !m.Walkable(m.Start.X, m.Start.Y) {
+			t.Errorf("%s: スタート地点が歩けません", path)
+		}
+		seen := reachable(m)
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				if m.Walkable(x, y) && !seen[Point{x, y}] {
+					t.Errorf("%s: (%d, %d) にスタート地点から行けません", path, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestCamera(t *testing.T) {
+	tests := []struct {
+		px, py, viewW, viewH, mapW, mapH float64
+		x, y                             float64
+	}{
+		{100, 100, 200, 100, 1000, 1000, 0, 50},    // 左上の端
+		{500, 500, 200, 100, 1000, 1000, 400, 450}, // 真ん中
+		{990, 990, 200, 100, 1000, 1000, 800, 900}, // 右下の端
+		{50, 500, 200, 100, 100, 1000, -50, 450},   // マップが画面より狭い
+	}
+	for _, tt := range tests {
+		x, y := Camera(tt.px, tt.py, tt.viewW, tt.viewH, tt.mapW, tt.mapH)
+		if x != tt.x || y != tt.y {
+			t.Errorf("Camera(%v, %v, ...) = (%v, %v), want (%v, %v)", tt.px, tt.py, x, y, tt.x, tt.y)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package trade は、捕まえたモンスターを友だちに送るための短いこうかんコードを作り、読み取ります。
+//
+// コードは Crockford の base32（0-9 と I・L・O・U を除く A-Z）で、5文字ごとにハイフンで区切ります。
+// 英大文字と数字とハイフンだけなので、そのまま QR コードの英数字モードにも載せられます。
+//
+// 先頭の4ビットがバージョンで、バージョンごとにビットの並びと長さが決まっています。
+// フィールドを増やすときはバージョンを上げて新しい並びを足し、古いバージョンのコードも読めるようにします。
+// 最後の16ビットはそれまでのビットの CRC で、書き換えたコードや打ち間違いを見つけます。
+package trade
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strings"
+)
+
+// Version は Encode が作るコードのバージョンです。
+const Version = 1
+
+// Monster はこうかんコードに入れるモンスターの情報です。
+type Monster struct {
+	Species   int     // 種族の番号（0～255。種族データの並び順なので、種族は最後に足すだけにする）
+	Level     int     // 1～127
+	Rarity    int     // 1～5
+	Size      int     // サイズの番号（0: XS … 4: XL）
+	SizeValue float64 // 0.5～1.5 の大きさの倍率（約 0.03 きざみに丸める）
+	Shiny     bool
+	Accessory int // アクセサリーの番号（0: なし … 7）
+}
+
+var (
+	// ErrMalformed は、コードの文字や長さが正しくないときのエラーです。
+	ErrMalformed = errors.New("trade: コードの形が正しくありません")
+	// ErrChecksum は、チェックサムが合わない（書き換えられたか打ち間違えた）ときのエラーです。
+	ErrChecksum = errors.New("trade: コードが正しくありません")
+	// ErrVersion は、このプログラムが知らないバージョンのコードのときのエラーです。
+	ErrVersion = errors.New("trade: 新しいバージョンのコードです")
+)
+
+// field はビット列の1つのフィールドです。
+type field struct {
+	bits int
+	get  func(m *Monster) uint64
+	set  func(m *Monster, v uint64)
+}
+
+// sizeValueSteps は SizeValue を 0.5～1.5 の範囲で何段階に丸めるかです。
+const sizeValueSteps = 31
+
+// layouts はバージョンごとのフィールドの並びです（バージョンの4ビットとチェックサムは含みません）。
+// 一度使ったバージョンの並びは変えないでください。
+var layouts = map[int][]field{
+	1: {
+		{8, func(m *Monster) uint64 { return uint64(m.Species) }, func(m *Monster, v uint64) { m.Species = int(v) }},
+		{7, func(m *Monster) uint64 { return uint64(m.Level) }, func(m *Monster, v uint64) { m.Level = int(v) }},
+		{3, func(m *Monster) uint64 { return uint64(m.Rarity) }, func(m *Monster, v uint64) { m.Rarity = int(v) }},
+		{3, func(m *Monster) uint64 { return uint64(m.Size) }, func(m *Monster, v uint64) { m.Size = int(v) }},
+		{5, func(m *Monster) uint64 {
+			return uint64(math.Round((m.SizeValue - 0.5) * sizeValueSteps))
+		}, func(m *Monster, v uint64) {
+			m.SizeValue = 0.5 + float64(v)/sizeValueSteps
+		}},
+		{1, func(m *Monster) uint64 {
+			if m.Shiny {
+				return 1
+			}
+			return 0
+		}, func(m *Monster, v uint64) { m.Shiny = v == 1 }},
+		{3, func(m *Monster) uint64 { return uint64(m.Accessory) }, func(m *Monster, v uint64) { m.Accessory = int(v) }},
+	},
+}
+
+const (
+	versionBits  = 4
+	checksumBits = 16
+	groupSize    = 5 // ハイフンで区切る文字数
+)
+
+// Encode はモンスターをこうかんコードにします。範囲外の値があればエラーを返します。
+func Encode(m Monster) (string, error) {
+	if err := validate(m); err != nil {
+		return "", err
+	}
+	var w bitWriter
+	w.write(Version, versionBits)
+	for _, f := range layouts[Version] {
+		w.write(f.get(&m), f.bits)
+	}
+	w.write(uint64(checksum(w.bits)), checksumBits)
+	return format(w.encode()), nil
+}
+
+// Decode はこうかんコードを読み取ります。
+// 大文字・小文字、ハイフンや空白、見まちがえやすい O・I・L（0・1 とみなす）は気にしません。
+func Decode(code string) (Monster, error) {
+	bits, err := decodeBase32(code)
+	if err != nil {
+		return Monster{}, err
+	}
+	if len(bits) < versionBits {
+		return Monster{}, ErrMalformed
+	}
+	r := bitReader{bits: bits}
+	version := int(r.read(versionBits))
+	layout, ok := layouts[version]
+	if !ok {
+		if version > Version {
+			return Monster{}, fmt.Errorf("%w（バージョン %d）", ErrVersion, version)
+		}
+		return Monster{}, ErrMalformed
+	}
+	if len(bits) != encodedBits(layout) {
+		return Monster{}, ErrMalformed
+	}
+
+	var m Monster
+	for _, f := range layout {
+		f.set(&m, r.read(f.bits))
+	}
+	payload := bits[:r.pos]
+	if uint32(r.read(checksumBits)) != checksum(payload) {
+		return Monster{}, ErrChecksum
+	}
+	// 文字の区切りに合わせて埋めたビットも 0 のはず
+	for _, b := range bits[r.pos:] {
+		if b != 0 {
+			return Monster{}, ErrChecksum
+		}
+	}
+	if err := validate(m); err != nil {
+		return Monster{}, fmt.Errorf("%w: %v", ErrChecksum, err)
+	}
+	return m, nil
+}
+
+// validate はフィールドが範囲内かを調べます。
+func validate(m Monster) error {
+	switch {
+	case m.Species < 0 || m.Species > 255:
+		return fmt.Errorf("trade: 種族の番号 %d は 0～255 にしてください", m.Species)
+	case m.Level < 1 || m.Level > 127:
+		return fmt.Errorf("trade: レベル %d は 1～127 にしてください", m.Level)
+	case m.Rarity < 1 || m.Rarity > 5:
+		return fmt.Errorf("trade: レア度 %d は 1～5 にしてください", m.Rarity)
+	case m.Size < 0 || m.Size > 4:
+		return fmt.Errorf("trade: サイズの番号 %d は 0～4 にしてください", m.Size)
+	case m.SizeValue < 0.5 || m.SizeValue > 1.5 || math.IsNaN(m.SizeValue):
+		return fmt.Errorf("trade: 大きさ %v は 0.5～1.5 にしてください", m.SizeValue)
+	case m.Accessory < 0 || m.Accessory > 7:
+		return fmt.Errorf("trade: アクセサリーの番号 %d は 0～7 にしてください", m.Accessory)
+	}
+	return nil
+}
+
+// encodedBits は、layout のコードのビット数を5の倍数（base32 の1文字分）に切り上げて返します。
+func encodedBits(layout []field) int {
+	n := versionBits + checksumBits
+	for _, f := range layout {
+		n += f.bits
+	}
+	return (n + 4) / 5 * 5
+}
+
+// checksum は、ビット列の CRC-32 の下位16ビットを返します。
+func checksum(bits []byte) uint32 {
+	return crc32.ChecksumIEEE(bits) & 0xffff
+}
+
+// ─────────────────────────────
+// ビット列と base32
+
+// bitWriter はビットを上位から順に並べます（1要素に1ビット）。
+type bitWriter struct {
+	bits []byte
+}
+
+func (w *bitWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, byte(v>>i&1))
+	}
+}
+
+// encode は5ビットずつ base32 の文字にします（足りない分は0で埋めます）。
+func (w *bitWriter) encode() string {
+	var sb strings.Builder
+	for i := 0; i < len(w.bits); i += 5 {
+		v := 0
+		for j := i; j < i+5; j++ {
+			v <<= 1
+			if j < len(w.bits) {
+				v |= int(w.bits[j])
+			}
+		}
+		sb.WriteByte(alphabet[v])
+	}
+	return sb.String()
+}
+
+// bitReader はビット列を上位から順に読みます。
+type bitReader struct {
+	bits []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.pos < len(r.bits) {
+			v |= uint64(r.bits[r.pos])
+		}
+		r.pos++
+	}
+	return v
+}
+
+// alphabet は Crockford の base32 の文字です。
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// decodeBase32 はコードをビット列にします。
+func decodeBase32(code string) ([]byte, error) {
+	var bits []byte
+	for _, c := range strings.ToUpper(code) {
+		switch c {
+		case '-', ' ':
+			continue
+		case 'O':
+			c = '0'
+		case 'I', 'L':
+			c = '1'
+		}
+		v := strings.IndexRune(alphabet, c)
+		if v < 0 {
+			return nil, fmt.Errorf("%w: %q は使えない文字です", ErrMalformed, c)
+		}
+		for i := 4; i >= 0; i-- {
+			bits = append(bits, byte(v>>i&1))
+		}
+	}
+	return bits, nil
+}
+
+// format は groupSize 文字ごとにハイフンで区切ります。
+func format(code string) string {
+	var groups []string
+	for i := 0; i < len(code); i += groupSize {
+		groups = append(groups, code[i:min(i+groupSize, len(code))])
+	}
+	return strings.Join(groups, "-")
+}
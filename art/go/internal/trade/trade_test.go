@@ -0,0 +1,211 @@
+package trade
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []Monster{
+		{Species: 0, Level: 1, Rarity: 1, Size: 0, SizeValue: 0.5},
+		{Species: 255, Level: 127, Rarity: 5, Size: 4, SizeValue: 1.5, Shiny: true, Accessory: 7},
+		{Species: 3, Level: 42, Rarity: 3, Size: 2, SizeValue: 1, Shiny: true, Accessory: 2},
+		{Species: 128, Level: 64, Rarity: 4, Size: 1, SizeValue: 0.77, Accessory: 5},
+	}
+	for _, m := range tests {
+		code, err := Encode(m)
+		if err != nil {
+			t.Fatalf("Encode(%+v): %v", m, err)
+		}
+		got, err := Decode(code)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", code, err)
+		}
+		// SizeValue は 1/31 きざみに丸めるので、ずれは半きざみまで
+		if math.Abs(got.SizeValue-m.SizeValue) > 0.5/sizeValueSteps+1e-9 {
+			t.Errorf("%q: SizeValue = %v, want ≈%v", code, got.SizeValue, m.SizeValue)
+		}
+		got.SizeValue = m.SizeValue
+		if got != m {
+			t.Errorf("%q: Decode = %+v, want %+v", code, got, m)
+		}
+	}
+}
+
+func TestSizeValueEnds(t *testing.T) {
+	// 両端はちょうど戻り、丸めても 0.5～1.5 をはみ出さない
+	for _, v := range []float64{0.5, 1.5, 0.5 + 0.4/sizeValueSteps, 1.5 - 0.4/sizeValueSteps} {
+		code, err := Encode(Monster{Level: 1, Rarity: 1, SizeValue: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0.5 + math.Round((v-0.5)*sizeValueSteps)/sizeValueSteps
+		if m.SizeValue != want || m.SizeValue < 0.5 || m.SizeValue > 1.5 {
+			t.Errorf("SizeValue %v → %v, want %v", v, m.SizeValue, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	code, err := Encode(Monster{Species: 5, Level: 30, Rarity: 2, Size: 3, SizeValue: 1.2, Accessory: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, group := range strings.Split(code, "-") {
+		if len(group) == 0 || len(group) > groupSize {
+			t.Errorf("%q: %d 番目の区切り %q", code, i, group)
+		}
+		for _, c := range group {
+			if !strings.ContainsRune(alphabet, c) {
+				t.Errorf("%q: %q は base32 の文字ではありません", code, c)
+			}
+		}
+	}
+
+	// 小文字、空白、ハイフンなし、O・I・L の読みかえも読める
+	want, _ := Decode(code)
+	plain := strings.ReplaceAll(code, "-", "")
+	variants := []string{
+		strings.ToLower(code),
+		plain,
+		" " + strings.Join(strings.Split(plain, ""), " ") + " ",
+		strings.NewReplacer("0", "O", "1", "l").Replace(code),
+	}
+	for _, v := range variants {
+		if got, err := Decode(v); err != nil || got != want {
+			t.Errorf("Decode(%q) = %+v, %v, want %+v", v, got, err, want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	code, err := Encode(Monster{Species: 7, Level: 50, Rarity: 4, Size: 2, SizeValue: 1.1, Shiny: true, Accessory: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := strings.ReplaceAll(code, "-", "")
+
+	// CRC は線形なので、1文字の書き換えを見つけられるかはモンスターによらない。
+	// すべての位置をすべての別の文字に書き換えて、どれも読めないことを確かめる
+	for i := range plain {
+		for _, c := range alphabet {
+			if byte(c) == plain[i] {
+				continue
+			}
+			edited := plain[:i] + string(c) + plain[i+1:]
+			if m, err := Decode(edited); err == nil {
+				t.Errorf("%d 文字目を %c にした %q が %+v として読めました", i+1, c, edited, m)
+			}
+		}
+	}
+
+	// 隣どうしの入れかえ
+	for i := 0; i+1 < len(plain); i++ {
+		if plain[i] == plain[i+1] {
+			continue
+		}
+		swapped := plain[:i] + plain[i+1:i+2] + plain[i:i+1] + plain[i+2:]
+		if _, err := Decode(swapped); err == nil {
+			t.Errorf("%d 文字目と %d 文字目を入れかえた %q が読めました", i+1, i+2, swapped)
+		}
+	}
+}
+
+// encodeBits は、バージョンと layout の並びの値とチェックサムを書いた base32 を返します。
+func encodeBits(version int, layout []field, m Monster, pad uint64) string {
+	var w bitWriter
+	w.write(uint64(version), versionBits)
+	for _, f := range layout {
+		w.write(f.get(&m), f.bits)
+	}
+	w.write(uint64(checksum(w.bits)), checksumBits)
+	if n := encodedBits(layout) - len(w.bits); n > 0 {
+		w.write(pad, n)
+	}
+	return w.encode()
+}
+
+func TestDecodeErrors(t *testing.T) {
+	m := Monster{Species: 1, Level: 10, Rarity: 2, Size: 1, SizeValue: 1}
+	code, _ := Encode(m)
+	plain := strings.ReplaceAll(code, "-", "")
+
+	// 範囲外の値を入れて、チェックサムは合わせたコード
+	outOfRange := m
+	outOfRange.Rarity = 7
+
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"空", "", ErrMalformed},
+		{"使えない文字", plain[:3] + "U" + plain[4:], ErrMalformed},
+		{"記号", plain[:3] + "!" + plain[4:], ErrMalformed},
+		{"短い", plain[:len(plain)-1], ErrMalformed},
+		{"長い", plain + "0", ErrMalformed},
+		{"バージョン 0", encodeBits(0, layouts[1], m, 0), ErrMalformed},
+		{"新しいバージョン", encodeBits(Version+1, layouts[1], m, 0), ErrVersion},
+		{"いちばん新しいバージョン", encodeBits(15, layouts[1], m, 0), ErrVersion},
+		{"範囲外の値", encodeBits(Version, layouts[1], outOfRange, 0), ErrChecksum},
+	}
+	for _, tt := range tests {
+		if got, err := Decode(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Decode(%q) = %+v, %v, want %v", tt.name, tt.code, got, err, tt.want)
+		}
+	}
+}
+
+func TestPadding(t *testing.T) {
+	// バージョン 1 はちょうど文字の区切りで終わるので、埋めるビットのある並びを仮に足して確かめる
+	const testVersion = 14
+	layout := append(append([]field(nil), layouts[1]...),
+		field{2, func(*Monster) uint64 { return 0 }, func(*Monster, uint64) {}})
+	layouts[testVersion] = layout
+	defer delete(layouts, testVersion)
+
+	m := Monster{Species: 9, Level: 99, Rarity: 5, Size: 4, SizeValue: 0.5, Accessory: 6}
+	if got, err := Decode(encodeBits(testVersion, layout, m, 0)); err != nil || got != m {
+		t.Fatalf("埋めるビットが 0 のコード: %+v, %v", got, err)
+	}
+	for pad := uint64(1); pad < 8; pad++ {
+		if _, err := Decode(encodeBits(testVersion, layout, m, pad)); !errors.Is(err, ErrChecksum) {
+			t.Errorf("埋めるビット %03b: err = %v, want ErrChecksum", pad, err)
+		}
+	}
+}
+
+func TestEncodeRange(t *testing.T) {
+	valid := Monster{Species: 0, Level: 1, Rarity: 1, Size: 0, SizeValue: 1}
+	tests := map[string]func(m *Monster){
+		"種族がマイナス":     func(m *Monster) { m.Species = -1 },
+		"種族が大きい":      func(m *Monster) { m.Species = 256 },
+		"レベル 0":       func(m *Monster) { m.Level = 0 },
+		"レベル 128":     func(m *Monster) { m.Level = 128 },
+		"レア度 0":       func(m *Monster) { m.Rarity = 0 },
+		"レア度 6":       func(m *Monster) { m.Rarity = 6 },
+		"サイズがマイナス":    func(m *Monster) { m.Size = -1 },
+		"サイズ 5":       func(m *Monster) { m.Size = 5 },
+		"大きさが小さい":     func(m *Monster) { m.SizeValue = 0.49 },
+		"大きさが大きい":     func(m *Monster) { m.SizeValue = 1.51 },
+		"大きさが NaN":    func(m *Monster) { m.SizeValue = math.NaN() },
+		"アクセサリーがマイナス": func(m *Monster) { m.Accessory = -1 },
+		"アクセサリー 8":    func(m *Monster) { m.Accessory = 8 },
+	}
+	for name, edit := range tests {
+		m := valid
+		edit(&m)
+		if code, err := Encode(m); err == nil {
+			t.Errorf("%s: Encode(%+v) = %q, want エラー", name, m, code)
+		}
+	}
+	if _, err := Encode(valid); err != nil {
+		t.Errorf("Encode(%+v): %v", valid, err)
+	}
+}
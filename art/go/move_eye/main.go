@@ -4,8 +4,12 @@ import (
 	"fmt"
     "math"
     "math/rand"
+	"net/url"
+	"strings"
+	"syscall/js"
 
 	"github.com/ryomak/p5go"
+	"github.com/ryomak/sketch/art/internal/motion"
 )
 
 func main() {
@@ -18,10 +22,21 @@ func main() {
 
 var faces []*face
 
+// pointer は目が追いかける位置（?input=motion ならカメラに映った人、それ以外はマウス）
+var pointer *motion.Pointer
+
 func setup(p *p5go.Canvas) {
     canvas := 300
 	p.CreateCanvas(canvas, canvas)
 	p.ColorMode(p5go.HSB)
+
+	query, _ := url.ParseQuery(strings.TrimPrefix(js.Global().Get("location").Get("search").String(), "?"))
+	if query.Get("input") == "motion" {
+		pointer = motion.NewCameraPointer(p)
+	} else {
+		pointer = motion.NewPointer(nil)
+	}
+
 	width := 100
 	for x := 0; x < canvas; x += width {
 		for y := 0; y < canvas; y += width {
@@ -40,6 +55,7 @@ func setup(p *p5go.Canvas) {
 }
 
 func draw(p *p5go.Canvas) {
+	pointer.Update(p)
 	for _, f := range faces {
 		f.draw(p)
 	}
@@ -55,7 +71,7 @@ type face struct {
 
 // eye は目を描画する
 func (f *face) eye(p *p5go.Canvas, x, y float64) {
-	angle := p.Atan2(p.MouseY()-y, p.MouseX()-x)
+	angle := p.Atan2(pointer.Y()-y, pointer.X()-x)
 	p.NoStroke()
 
 	p.Push()
@@ -71,7 +87,7 @@ func (f *face) eye(p *p5go.Canvas, x, y float64) {
 
 // mouth は口を描画する
 func (f *face) mouth(p *p5go.Canvas) {
-	distance := math.Hypot(p.MouseX()-f.x-f.width/2, p.MouseY()-f.y-f.height/2)
+	distance := math.Hypot(pointer.X()-f.x-f.width/2, pointer.Y()-f.y-f.height/2)
 
 	// 距離を 0 から 200 の範囲に補正し、滑らかな変化をつける
 	clampedDistance := math.Min(math.Max(distance, 0), 200)
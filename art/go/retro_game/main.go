@@ -1,31 +1,50 @@
 package main
 
 import (
+	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
-	"time"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall/js"
 
 	"github.com/ryomak/p5go"
+	"github.com/ryomak/sketch/art/internal/capture"
+	"github.com/ryomak/sketch/art/internal/chiptune"
+	"github.com/ryomak/sketch/art/internal/encounter"
+	"github.com/ryomak/sketch/art/internal/fsm"
+	"github.com/ryomak/sketch/art/internal/gamestate"
+	"github.com/ryomak/sketch/art/internal/motion"
+	"github.com/ryomak/sketch/art/internal/overworld"
+	"github.com/ryomak/sketch/art/internal/trade"
 )
 
 var (
 	p          *p5go.Canvas
-	gameMode   int
+	scene      *fsm.Machine[gamestate.State] // 画面と捕獲の流れ
 	frameCount int
 	pixelSize  = 4 // ドット絵のピクセルサイズ
 
 	// 捕獲シーン変数
 	wildMonster     Monster
-	captureState    string // "encounter", "throwing", "shaking", "success", "failed", "gameover"
-	selectedAction  int
+	selectedAction  int // メニューでカーソルが合っている行
 	pokeball        Pokeball
 	particles       []Particle
 	shakeOffset     float64
 	textAnimation   TextAnimation
 	captureAttempts int
-	selectedBall    int // 選択中のボールタイプ
-	maxAttempts     = 3 // 最大試行回数
+	selectedBall    int                               // 選択中のボールタイプ
+	inventory       = [capture.Balls]int{10, 5, 2, 1} // ボールタイプごとの所持数（戦闘をまたいで持ち越す）
+	maxAttempts     = 3                               // 最大試行回数
+
+	// バトル変数
+	playerMonster Monster    // 手持ちのモンスター
+	battle        BattleTurn // 進行中のターン
 
 	// 背景変数
 	backgroundPixels [][]int
@@ -34,16 +53,32 @@ var (
 	animatedObjects  []AnimatedObject
 	weatherParticles []WeatherParticle
 	weatherType      int // 0: なし, 1: 雨, 2: 雪, 3: 落ち葉, 4: 砂嵐
+
+	// フィールド
+	world    *overworld.Map
+	player   Walker
+	heldKeys = map[string]bool{} // 押されているキー（KeyboardEvent.key）
+
+	// 効果音と BGM（?sound=0 か WebAudio がないときは nil で鳴らさない）
+	sound *chiptune.Player
+
+	// ジェスチャー入力（?input=motion のときだけ使う）
+	gesturePointer *motion.Pointer
+	gestures       *motion.GestureRecognizer
 )
 
 type Monster struct {
 	name        string
+	species     int // speciesList の番号
 	monsterType int
+	sprite      string  // 体のつくり（"dragon", "fish", "plant", "mouse", "floater"）
+	seed        int64   // ドット絵を生成するシード
+	pixels      [][]int // 生成したドット絵
 	x, y        float64
 	hp, maxHP   int
 	animOffset  float64
 	color       struct{ r, g, b uint8 }
-	catchRate   float64 // 0.0 から 1.0
+	catchRate   float64 // 種族ごとの基本の捕獲率 0.0 から 1.0
 	level       int
 	rarity      int     // 1-5 stars
 	hasHat      bool    // レア度の表示用
@@ -51,6 +86,9 @@ type Monster struct {
 	accessory   string  // "none", "crown", "scarf", "glasses", "bowtie", "cape"
 	size        string  // "XS", "S", "M", "L", "XL"
 	sizeValue   float64 // 0.5 to 1.5 for actual size multiplier
+	moves       []Move
+	shownHP     float64 // HPバーに表示中のHP（hp に向かって減っていく）
+	hitTimer    int     // 攻撃を受けて揺れる残りフレーム
 }
 
 type Pokeball struct {
@@ -114,59 +152,471 @@ func setup(canvas *p5go.Canvas) {
 	p.CreateCanvas(400, 400)
 	p.FrameRate(60) // 60FPSでアニメーションを滑らかに
 
-	initBattleScene()
+	playerMonster = newPlayerMonster()
+	loadGame()
+
+	// ?input=motion ならカメラに映った手の動き（カメラがなければマウス）でジェスチャー操作する
+	query, _ := url.ParseQuery(strings.TrimPrefix(js.Global().Get("location").Get("search").String(), "?"))
+	if query.Get("input") == "motion" {
+		gesturePointer = motion.NewCameraPointer(p)
+		gestures = motion.NewGestureRecognizer(motion.DefaultGestureConfig(), motion.DefaultGestureTemplates())
+	}
+
+	if query.Get("sound") != "0" {
+		sound = loadSounds()
+	}
+
+	// ?map=名前 なら maps/ のマップ、なければ ?seed= のシードからフィールドを作る
+	world = loadWorld(query)
+	player = Walker{tile: world.Start, from: world.Start, facing: overworld.Point{X: 0, Y: 1}}
+	listenKeys()
+
+	// タイトルの後ろにスタート地点のバイオームの背景を見せる
+	initBattleScene(world.At(world.Start.X, world.Start.Y).Biome)
+	scene = newScene()
+	scene.Start()
 }
 
 func draw(canvas *p5go.Canvas) {
 	p = canvas
 	frameCount++
 
-	drawBattleScene()
+	if gestures != nil {
+		gesturePointer.Update(p)
+		if gesture, ok := gestures.Add(gesturePointer.X(), gesturePointer.Y(), motion.Now()); ok {
+			handleGesture(gesture)
+		}
+	}
+
+	updateMenuCursor()
+	if err := scene.Update(); err != nil {
+		fmt.Println(err)
+	}
+	scene.Draw()
+
+	if gesturePointer != nil && gesturePointer.FromMotion() {
+		// カメラで操作しているときは手の位置を表示
+		p.NoFill()
+		p.Stroke(255, 255, 255, 180)
+		p.StrokeWeight(2)
+		p.Ellipse(gesturePointer.X(), gesturePointer.Y(), 16, 16)
+		p.NoStroke()
+	}
+}
+
+// handleGesture はジェスチャーをゲームの操作に割り当てます。
+// 上スワイプでボールを投げ、円を描くとにげ、ゲームオーバー中に静止するとフィールドに戻ります。
+// タイトルではどのジェスチャーでも始め、フィールドではスワイプの向きに1マス歩きます。
+func handleGesture(gesture motion.Gesture) {
+	state := scene.State()
+	switch state {
+	case gamestate.Title:
+		goTo(gamestate.Overworld)
+		return
+	case gamestate.Overworld:
+		swipes := map[motion.Gesture]overworld.Point{
+			motion.GestureSwipeUp:    {X: 0, Y: -1},
+			motion.GestureSwipeDown:  {X: 0, Y: 1},
+			motion.GestureSwipeLeft:  {X: -1, Y: 0},
+			motion.GestureSwipeRight: {X: 1, Y: 0},
+		}
+		if dir, ok := swipes[gesture]; ok && player.step == 0 {
+			player.walk(dir)
+		}
+		return
+	}
+	switch gesture {
+	case motion.GestureSwipeUp:
+		throwPokeball()
+	case motion.GestureCircle:
+		if state == gamestate.Encounter || state == gamestate.Failed || state == gamestate.GameOver || state == gamestate.Fainted {
+			leaveBattle()
+		}
+	case motion.GestureHold:
+		if state == gamestate.GameOver || state == gamestate.Fainted {
+			leaveBattle()
+		}
+	}
 }
 
 func mousePressed(canvas *p5go.Canvas) {
 	p = canvas
+	sound.Resume() // ブラウザはクリックされるまで音を出さない
 
-	if captureState == "encounter" || captureState == "failed" {
-		// ランダムにボールを選択して投げる
-		throwPokeball()
-	} else if captureState == "gameover" {
-		// ゲームオーバー時はリセット
-		initBattleScene()
-	}
-}
-
-func initBattleScene() {
-	// ポケモン風の名前
-	monsterNames := []string{"ヒトカゲ", "ゼニガメ", "フシギダネ", "ピカチュウ", "ミュウツー"}
-	monsterColors := []struct{ r, g, b uint8 }{
-		{255, 100, 50},  // ほのお - 赤/オレンジ
-		{50, 150, 255},  // みず - 青
-		{100, 255, 100}, // くさ - 緑
-		{255, 255, 50},  // でんき - 黄色
-		{200, 100, 255}, // エスパー - 紫
-	}
-	catchRates := []float64{0.3, 0.4, 0.5, 0.35, 0.25}
-
-	monsterType := rand.Intn(5)
-
-	// レアリティを決定（1-5星）
-	rarityRoll := rand.Float64()
-	rarity := 1
-	if rarityRoll < 0.02 {
-		rarity = 5 // ★★★★★ (2%)
-	} else if rarityRoll < 0.08 {
-		rarity = 4 // ★★★★ (6%)
-	} else if rarityRoll < 0.20 {
-		rarity = 3 // ★★★ (12%)
-	} else if rarityRoll < 0.45 {
-		rarity = 2 // ★★ (25%)
-	} else {
-		rarity = 1 // ★ (55%)
+	mx, my := p.MouseX(), p.MouseY()
+	if scene.Can(gamestate.Collection) && isCollectionButton(mx, my) {
+		goTo(gamestate.Collection)
+		return
+	}
+
+	switch scene.State() {
+	case gamestate.Title:
+		goTo(gamestate.Overworld)
+	case gamestate.Collection:
+		clickCollection(mx, my)
+	case gamestate.Encounter, gamestate.Failed:
+		switch menuRowAt(mx, my) {
+		case 0:
+			// 「たたかう」でわざを選ぶ
+			goTo(gamestate.Moves)
+		case 1:
+			// 「ボール」でバッグを開く
+			goTo(gamestate.Bag)
+		case 2:
+			// 「にげる」でフィールドに戻る
+			leaveBattle()
+		}
+	case gamestate.Moves:
+		if isBackButton(mx, my) {
+			goTo(gamestate.Encounter)
+		} else if index := listRowAt(mx, my); index >= 0 && index < len(playerMonster.moves) {
+			startTurn(index)
+		}
+	case gamestate.Bag:
+		if isBackButton(mx, my) {
+			goTo(gamestate.Encounter)
+		} else if index := listRowAt(mx, my); index >= 0 && index < len(inventory) && inventory[index] > 0 {
+			selectedBall = index
+			throwPokeball()
+		}
+	case gamestate.GameOver, gamestate.Fainted, gamestate.Success:
+		// ゲームオーバー時や捕獲後はフィールドに戻る
+		leaveBattle()
+	}
+}
+
+// ─────────────────────────────
+// 状態遷移
+
+// ボールが揺れる間隔と回数
+const (
+	shakeFrames = 5
+	shakeTimes  = 3
+)
+
+// newScene は画面と捕獲の流れの状態機械を作ります
+func newScene() *fsm.Machine[gamestate.State] {
+	m := fsm.New(gamestate.Title, gamestate.Transitions)
+	m.On(gamestate.Title, fsm.Hooks[gamestate.State]{Draw: drawTitle})
+	m.On(gamestate.Overworld, fsm.Hooks[gamestate.State]{
+		Enter:  func(gamestate.State) { sound.Stop() },
+		Update: func(int) { updateOverworld() },
+		Draw:   drawOverworld,
+	})
+	m.On(gamestate.Collection, fsm.Hooks[gamestate.State]{
+		Enter: func(gamestate.State) { collectionListing = false },
+		Draw:  drawCollection,
+	})
+
+	m.On(gamestate.Encounter, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(gamestate.State) { sound.Loop("battle") },
+	}, drawEncounterText))
+	m.On(gamestate.Failed, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(from gamestate.State) {
+			pokeball.state = "idle"
+			if from == gamestate.Shaking {
+				sound.Play("escape")
+			}
+		},
+	}, drawFailedText))
+	m.On(gamestate.Moves, battleHooks(fsm.Hooks[gamestate.State]{}, drawMoveMenu))
+	m.On(gamestate.Bag, battleHooks(fsm.Hooks[gamestate.State]{}, drawBagMenu))
+	m.On(gamestate.Attacking, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(gamestate.State) {
+			attack(&playerMonster, &wildMonster, playerMonster.moves[battle.moveIndex])
+		},
+		Update: updateTurn,
+		After:  turnStepFrames * 2,
+		Next:   endTurn,
+	}, drawTurnText))
+	m.On(gamestate.Fainted, battleHooks(fsm.Hooks[gamestate.State]{}, drawFaintedText))
+	m.On(gamestate.Throwing, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter:  func(gamestate.State) { launchPokeball() },
+		Update: func(int) { updatePokeballFlight() },
+	}, drawThrowingText))
+	m.On(gamestate.Shaking, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(gamestate.State) {
+			pokeball.state = "shaking"
+			pokeball.shakeCount = 0
+			pokeball.shakeTimer = 0
+		},
+		Update: func(int) {
+			pokeball.shakeTimer++
+			if pokeball.shakeTimer%shakeFrames == 0 {
+				pokeball.shakeCount++
+				sound.Play("shake")
+			}
+		},
+		After: shakeFrames * shakeTimes,
+		Next:  resolveCapture,
+	}, drawShakingText))
+	m.On(gamestate.Success, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(from gamestate.State) {
+			pokeball.state = "captured"
+			if from == gamestate.Shaking {
+				// BGM を止めてファンファーレを聞かせる
+				sound.Stop()
+				sound.Play("success")
+			}
+		},
+	}, drawSuccessText))
+	m.On(gamestate.GameOver, battleHooks(fsm.Hooks[gamestate.State]{
+		Enter: func(from gamestate.State) {
+			pokeball.state = "idle"
+			if from == gamestate.Shaking {
+				sound.Stop()
+				sound.Play("escape")
+			}
+		},
+	}, drawGameOverText))
+	return m
+}
+
+// battleHooks は捕獲シーンの状態のフックに、毎フレームの演出の更新と、
+// テキストボックスに text を出すバトル画面の描画を足します
+func battleHooks(h fsm.Hooks[gamestate.State], text func()) fsm.Hooks[gamestate.State] {
+	update := h.Update
+	h.Update = func(frames int) {
+		updateAnimations()
+		if update != nil {
+			update(frames)
+		}
+	}
+	h.Draw = func() { drawBattleScene(text) }
+	return h
+}
+
+// goTo は state へ移ります。遷移表にない遷移はログに出して無視します
+func goTo(state gamestate.State) {
+	if err := scene.Go(state); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// startEncounter は biome で野生のモンスターに出会います
+func startEncounter(biome encounter.Biome) {
+	if !scene.Can(gamestate.Encounter) {
+		return
+	}
+	initBattleScene(biome)
+	goTo(gamestate.Encounter)
+}
+
+// leaveBattle はバトルを終えてフィールドに戻ります
+func leaveBattle() {
+	goTo(gamestate.Overworld)
+}
+
+// drawTitle はタイトル画面を描画します
+func drawTitle() {
+	drawPixelBackground()
+	p.NoStroke()
+	p.Fill(0, 0, 40, 170)
+	p.Rect(0, 0, 400, 400)
+
+	p.Fill(255, 215, 0, 255)
+	p.TextSize(28)
+	p.Text("レトロ モンスター", 80, 150)
+	seen := 0
+	for _, rarities := range dexCompletion(dex, speciesList).caught {
+		if slices.Contains(rarities[:], true) {
+			seen++
+		}
+	}
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(14)
+	p.Text(fmt.Sprintf("ずかん %d/%d しゅるい", seen, len(speciesList)), 125, 190)
+	if frameCount%60 < 40 {
+		p.Text("クリックで はじめる", 130, 280)
+	}
+}
+
+// タイプごとの色（わざのエフェクトなどに使う）
+var typeColors = []struct{ r, g, b uint8 }{
+	{255, 100, 50},  // ほのお - 赤/オレンジ
+	{50, 150, 255},  // みず - 青
+	{100, 255, 100}, // くさ - 緑
+	{255, 255, 50},  // でんき - 黄色
+	{200, 100, 255}, // エスパー - 紫
+}
+
+// ─────────────────────────────
+// 効果音と BGM（sounds.txt）
+
+//go:embed sounds.txt
+var soundsText []byte
+
+// loadSounds は sounds.txt の曲をレンダリングして WebAudio で鳴らせるようにします
+func loadSounds() *chiptune.Player {
+	songs, err := chiptune.Parse(soundsText)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	sounds := chiptune.NewPlayer()
+	sounds.Load(songs...)
+	return sounds
+}
+
+// ─────────────────────────────
+// 種族データ（species.json）
+//
+// こうかんコードには species.json の並び順の番号が入るので、新しい種族は最後に足すだけにして、
+// 並べかえたり消したりしないでください（main_test.go で並びを確かめています）。
+
+//go:embed species.json
+var speciesJSON []byte
+
+// IntRange は最小値と最大値（両端を含む）です
+type IntRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// roll は範囲内の値をランダムに返します
+func (r IntRange) roll() int {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + rand.Intn(r.Max-r.Min+1)
+}
+
+// Species はモンスターの種族です
+type Species struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // "fire", "water", "grass", "electric", "psychic"
+	CatchRate  float64  `json:"catchRate"`
+	Color      [3]uint8 `json:"color"`
+	ShinyColor [3]uint8 `json:"shinyColor"`
+	Level      IntRange `json:"level"`
+	HP         IntRange `json:"hp"`
+	Sprite     string   `json:"sprite"` // ドット絵の種類
+}
+
+// typeNames は species.json のタイプ名です
+var typeNames = map[string]int{
+	"fire":     typeFire,
+	"water":    typeWater,
+	"grass":    typeGrass,
+	"electric": typeElectric,
+	"psychic":  typePsychic,
+}
+
+// speciesList は species.json から読み込んだ種族の一覧です
+var speciesList = mustLoadSpecies(speciesJSON)
+
+// loadSpecies は種族データを読み込んで検証します
+func loadSpecies(b []byte) ([]Species, error) {
+	var list []Species
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("種族がひとつもありません")
+	}
+	seen := map[string]bool{}
+	for i, sp := range list {
+		if sp.ID == "" || seen[sp.ID] {
+			return nil, fmt.Errorf("%d番目の種族の id %q が空か重複しています", i, sp.ID)
+		}
+		seen[sp.ID] = true
+		if _, ok := typeNames[sp.Type]; !ok {
+			return nil, fmt.Errorf("%s: 不明なタイプ %q", sp.ID, sp.Type)
+		}
+		if sp.CatchRate <= 0 || sp.CatchRate > 1 {
+			return nil, fmt.Errorf("%s: 捕獲率 %v は 0 より大きく 1 以下にしてください", sp.ID, sp.CatchRate)
+		}
+		if sp.Level.Min < 1 || sp.Level.Max < sp.Level.Min || sp.HP.Min < 1 || sp.HP.Max < sp.HP.Min {
+			return nil, fmt.Errorf("%s: レベルかHPの範囲が正しくありません", sp.ID)
+		}
+	}
+	return list, nil
+}
+
+// mustLoadSpecies は埋め込んだ種族データを読み込みます（壊れていたら起動できないので panic する）
+func mustLoadSpecies(b []byte) []Species {
+	list, err := loadSpecies(b)
+	if err != nil {
+		panic("species.json: " + err.Error())
+	}
+	return list
+}
+
+// ─────────────────────────────
+// 出現テーブル（encounters.json）
+
+//go:embed encounters.json
+var encountersJSON []byte
+
+// encounters はバトル背景と天候ごとの出現テーブルです
+var encounters = mustLoadEncounters(encountersJSON, speciesList)
+
+// mustLoadEncounters は埋め込んだ出現テーブルを読み込みます（壊れていたら起動できないので panic する）
+func mustLoadEncounters(b []byte, species []Species) *encounter.Table {
+	types := make(map[string]string, len(species))
+	for _, sp := range species {
+		types[sp.ID] = sp.Type
+	}
+	table, err := encounter.Parse(b, types)
+	if err != nil {
+		panic("encounters.json: " + err.Error())
+	}
+	return table
+}
+
+// speciesIndex は種族 ID から speciesList の番号を返します（こうかんコードの種族の番号）
+func speciesIndex(id string) (int, bool) {
+	for i, sp := range speciesList {
+		if sp.ID == id {
+			return i, true
+		}
 	}
+	return 0, false
+}
+
+// サイズとアクセサリーの名前（こうかんコードではこの番号を使うので、足すときは最後に）
+var (
+	sizeNames      = []string{"XS", "S", "M", "L", "XL"}
+	accessoryNames = []string{"none", "crown", "scarf", "glasses", "bowtie", "cape"}
+)
+
+// newMonster は種族データから基本のモンスターを作ります
+func newMonster(speciesNo int) Monster {
+	sp := speciesList[speciesNo]
+	monsterType := typeNames[sp.Type]
+	hp := sp.HP.roll()
+	seed := rand.Int63()
+	return Monster{
+		name:        sp.Name,
+		species:     speciesNo,
+		monsterType: monsterType,
+		sprite:      sp.Sprite,
+		seed:        seed,
+		pixels:      generateMonsterSprite(seed, sp.Sprite),
+		hp:          hp,
+		maxHP:       hp,
+		shownHP:     float64(hp),
+		color:       struct{ r, g, b uint8 }{sp.Color[0], sp.Color[1], sp.Color[2]},
+		catchRate:   sp.CatchRate, // レア度・サイズ・HP・ボールの補正は capture.Probability で
+		level:       sp.Level.roll(),
+		rarity:      1,
+		accessory:   "none",
+		size:        "M",
+		sizeValue:   1.0,
+		moves:       movesByType[monsterType],
+	}
+}
 
-	// レベルを決定
-	level := rand.Intn(50) + 1 + rarity*10 // レア度が高いほどレベルも高い
+func initBattleScene(biome encounter.Biome) {
+	// 背景と天候を先に決め、その出現テーブルから種族とレア度（1-5星）を抽選する
+	initBackground(int(biome))
+	speciesID, rarity := encounters.Sample(encounter.Biome(backgroundType), encounter.Weather(weatherType), rand.Float64)
+	speciesNo, _ := speciesIndex(speciesID)
+
+	wildMonster = newMonster(speciesNo)
+	// レア度が高いほどレベルも高い
+	level := wildMonster.level + rarity*10
 
 	// 色違い判定 (1/100の確率)
 	isShiny := rand.Float64() < 0.01
@@ -197,15 +647,14 @@ func initBattleScene() {
 
 	// アクセサリー決定
 	accessory := "none"
-	accessories := []string{"none", "crown", "scarf", "glasses", "bowtie", "cape"}
 	// レア度が高いほど良いアクセサリー
 	if rarity == 5 {
 		accessory = "crown" // ★5は必ず王冠
 	} else if rarity == 4 {
-		accessory = accessories[rand.Intn(2)+4] // cape or bowtie
+		accessory = accessoryNames[rand.Intn(2)+4] // cape or bowtie
 	} else if rarity == 3 {
 		if rand.Float64() < 0.7 {
-			accessory = accessories[rand.Intn(2)+2] // glasses or scarf
+			accessory = accessoryNames[rand.Intn(2)+2] // glasses or scarf
 		}
 	} else if rarity == 2 {
 		if rand.Float64() < 0.3 {
@@ -213,45 +662,27 @@ func initBattleScene() {
 		}
 	}
 
-	// 色を決定（色違いの場合は変更）
-	monsterColor := monsterColors[monsterType]
+	// 色違いは種族ごとの特別な色に
 	if isShiny {
-		// 色違いは特別な色に
-		switch monsterType {
-		case 0: // 火タイプは青色に
-			monsterColor = struct{ r, g, b uint8 }{50, 100, 255}
-		case 1: // 水タイプは赤色に
-			monsterColor = struct{ r, g, b uint8 }{255, 50, 50}
-		case 2: // 草タイプは金色に
-			monsterColor = struct{ r, g, b uint8 }{255, 215, 0}
-		case 3: // 電気タイプは黒色に
-			monsterColor = struct{ r, g, b uint8 }{50, 50, 50}
-		case 4: // エスパータイプは白色に
-			monsterColor = struct{ r, g, b uint8 }{255, 255, 255}
-		}
-	}
-
-	// サイズによる捕獲率調整（大きいほど捕まえにくい）
-	sizeCatchModifier := 2.0 - sizeValue // XS(1.5～1.3) to XL(0.7～0.5)
-
-	wildMonster = Monster{
-		name:        monsterNames[monsterType],
-		monsterType: monsterType,
-		x:           200,
-		y:           150,
-		hp:          100,
-		maxHP:       100,
-		color:       monsterColor,
-		catchRate:   (catchRates[monsterType] - float64(rarity-1)*0.15) * sizeCatchModifier, // レア度とサイズが捕獲率に影響
-		level:       level,
-		rarity:      rarity,
-		hasHat:      hasHat,
-		isShiny:     isShiny,
-		accessory:   accessory,
-		size:        size,
-		sizeValue:   sizeValue,
+		c := speciesList[speciesNo].ShinyColor
+		wildMonster.color = struct{ r, g, b uint8 }{c[0], c[1], c[2]}
 	}
 
+	wildMonster.x = 200
+	wildMonster.y = 150
+	wildMonster.level = level
+	wildMonster.rarity = rarity
+	wildMonster.hasHat = hasHat
+	wildMonster.isShiny = isShiny
+	wildMonster.accessory = accessory
+	wildMonster.size = size
+	wildMonster.sizeValue = sizeValue
+
+	// 手持ちのモンスターは戦闘ごとに全回復
+	playerMonster.hp = playerMonster.maxHP
+	playerMonster.shownHP = float64(playerMonster.maxHP)
+	playerMonster.hitTimer = 0
+
 	// モンスターボール初期化
 	pokeball = Pokeball{
 		x:     200,
@@ -259,48 +690,15 @@ func initBattleScene() {
 		state: "idle",
 	}
 
-	captureState = "encounter"
 	captureAttempts = 0
 	selectedAction = 0
-	selectedBall = 0
+	restockInventory()
 	particles = make([]Particle, 0)
-
-	// 背景初期化
-	initBackground()
 }
 
-func initBackground() {
-	// ランダムに背景タイプを選択
-	backgroundType = rand.Intn(8)
-
-	// ピクセルアート背景作成 (400x400キャンバス用100x100グリッド)
-	backgroundPixels = make([][]int, 100)
-	grassPixels = make([][]int, 100)
-
-	for i := range backgroundPixels {
-		backgroundPixels[i] = make([]int, 100)
-		grassPixels[i] = make([]int, 100)
-	}
-
-	// 背景タイプに応じて生成
-	switch backgroundType {
-	case 0: // 草原
-		generateGrassland()
-	case 1: // 森
-		generateForest()
-	case 2: // 山
-		generateMountain()
-	case 3: // 海辺
-		generateBeach()
-	case 4: // 洞窟
-		generateCave()
-	case 5: // 火山
-		generateVolcano()
-	case 6: // 雪原
-		generateSnowfield()
-	case 7: // 砂漠
-		generateDesert()
-	}
+func initBackground(biome int) {
+	backgroundType = biome
+	generateBackground(backgroundType)
 
 	// 天候エフェクトを設定
 	switch backgroundType {
@@ -337,6 +735,38 @@ func initBackground() {
 	}
 }
 
+// generateBackground は背景タイプ biome のバトル背景を backgroundPixels と grassPixels に生成します
+func generateBackground(biome int) {
+	// ピクセルアート背景作成 (400x400キャンバス用100x100グリッド)
+	backgroundPixels = make([][]int, 100)
+	grassPixels = make([][]int, 100)
+
+	for i := range backgroundPixels {
+		backgroundPixels[i] = make([]int, 100)
+		grassPixels[i] = make([]int, 100)
+	}
+
+	// 背景タイプに応じて生成
+	switch biome {
+	case 0: // 草原
+		generateGrassland()
+	case 1: // 森
+		generateForest()
+	case 2: // 山
+		generateMountain()
+	case 3: // 海辺
+		generateBeach()
+	case 4: // 洞窟
+		generateCave()
+	case 5: // 火山
+		generateVolcano()
+	case 6: // 雪原
+		generateSnowfield()
+	case 7: // 砂漠
+		generateDesert()
+	}
+}
+
 func generateGrassland() {
 	for i := 0; i < 100; i++ {
 		for j := 0; j < 100; j++ {
@@ -512,35 +942,100 @@ func abs(x int) int {
 	return x
 }
 
-func drawBattleScene() {
+// drawBattleScene はバトル画面を描画し、テキストボックスに text で今の状態の表示を出します
+func drawBattleScene(text func()) {
+	state := scene.State()
+
 	// ピクセルアート背景を描画
 	drawPixelBackground()
 
 	// 天候エフェクトを描画
 	drawWeatherEffects()
 
-	// アニメーションを更新
-	updateAnimations()
-
 	// パーティクルを更新して描画
 	updateParticles()
 
 	// 野生のモンスターを描画
-	if captureState != "shaking" {
+	if state != gamestate.Shaking {
 		// 揺れ中は非表示、成功時とその他は表示
 		drawMonster(wildMonster)
 	}
 
+	// 手持ちのモンスターを描画
+	drawMonster(playerMonster)
+
 	// モンスターボールを描画
 	drawPokeball()
 
+	// HPバーを描画（表示中のHPでなめらかに減らす）
+	if state != gamestate.Success {
+		shownWild := wildMonster
+		shownWild.hp = int(math.Ceil(wildMonster.shownHP))
+		drawHPBar_old(20, 20, shownWild, false)
+	}
+	shownPlayer := playerMonster
+	shownPlayer.hp = int(math.Ceil(playerMonster.shownHP))
+	drawHPBar_old(275, 230, shownPlayer, true)
+
 	// UIを描画
-	drawCaptureUI()
+	drawCaptureUI(text)
 
 	// テキストアニメーションを描画
 	if textAnimation.text != "" {
 		drawTextAnimation()
 	}
+
+	if scene.Can(gamestate.Collection) {
+		drawCollectionButton()
+	}
+}
+
+// fillBackground は背景ピクセルの色番号 pixel の色を塗りの色にします
+func fillBackground(pixel int) {
+	switch pixel {
+	case 1: // 薄い青空
+		p.Fill(150, 200, 255, 255)
+	case 2: // 中間の青空
+		p.Fill(120, 180, 240, 255)
+	case 3: // 濃い青空
+		p.Fill(100, 160, 220, 255)
+	case 4: // 雲
+		p.Fill(255, 255, 255, 200)
+	case 5: // 地面（草原）
+		p.Fill(120, 180, 80, 255)
+	case 6: // 暗い緑（森の空）
+		p.Fill(40, 80, 60, 255)
+	case 7: // 森の地面
+		p.Fill(60, 100, 40, 255)
+	case 8: // 岩（山）
+		p.Fill(120, 120, 140, 255)
+	case 9: // 波
+		p.Fill(100, 200, 255, 255)
+	case 10: // 海
+		p.Fill(50, 150, 220, 255)
+	case 11: // 砂浜
+		p.Fill(255, 230, 150, 255)
+	case 12: // 洞窟暗い部分
+		p.Fill(30, 30, 40, 255)
+	case 13: // 洞窟岩壁
+		p.Fill(80, 70, 90, 255)
+	case 14: // 赤い空（火山）
+		p.Fill(200, 80, 60, 255)
+	case 15: // 溶岩
+		p.Fill(255, 100, 0, 255)
+	case 16: // 火山岩
+		p.Fill(60, 40, 30, 255)
+	case 17: // 白い空（雪原）
+		p.Fill(230, 240, 255, 255)
+	case 18: // 雪
+		p.Fill(255, 255, 255, 255)
+	case 19: // 黄色い空（砂漠）
+		p.Fill(255, 220, 150, 255)
+	case 20: // 砂の模様
+		p.Fill(230, 200, 100, 255)
+	case 21: // 砂
+		p.Fill(255, 220, 130, 255)
+	}
 }
 
 func drawPixelBackground() {
@@ -551,51 +1046,7 @@ func drawPixelBackground() {
 			x := float64(j * pixelSize)
 			y := float64(i * pixelSize)
 
-			switch pixel {
-			case 1: // 薄い青空
-				p.Fill(150, 200, 255, 255)
-			case 2: // 中間の青空
-				p.Fill(120, 180, 240, 255)
-			case 3: // 濃い青空
-				p.Fill(100, 160, 220, 255)
-			case 4: // 雲
-				p.Fill(255, 255, 255, 200)
-			case 5: // 地面（草原）
-				p.Fill(120, 180, 80, 255)
-			case 6: // 暗い緑（森の空）
-				p.Fill(40, 80, 60, 255)
-			case 7: // 森の地面
-				p.Fill(60, 100, 40, 255)
-			case 8: // 岩（山）
-				p.Fill(120, 120, 140, 255)
-			case 9: // 波
-				p.Fill(100, 200, 255, 255)
-			case 10: // 海
-				p.Fill(50, 150, 220, 255)
-			case 11: // 砂浜
-				p.Fill(255, 230, 150, 255)
-			case 12: // 洞窟暗い部分
-				p.Fill(30, 30, 40, 255)
-			case 13: // 洞窟岩壁
-				p.Fill(80, 70, 90, 255)
-			case 14: // 赤い空（火山）
-				p.Fill(200, 80, 60, 255)
-			case 15: // 溶岩
-				p.Fill(255, 100, 0, 255)
-			case 16: // 火山岩
-				p.Fill(60, 40, 30, 255)
-			case 17: // 白い空（雪原）
-				p.Fill(230, 240, 255, 255)
-			case 18: // 雪
-				p.Fill(255, 255, 255, 255)
-			case 19: // 黄色い空（砂漠）
-				p.Fill(255, 220, 150, 255)
-			case 20: // 砂の模様
-				p.Fill(230, 200, 100, 255)
-			case 21: // 砂
-				p.Fill(255, 220, 130, 255)
-			}
-
+			fillBackground(pixel)
 			p.NoStroke()
 			p.Rect(x, y, float64(pixelSize), float64(pixelSize))
 
@@ -725,638 +1176,567 @@ func drawMonster(monster Monster) {
 	x := monster.x + shakeOffset
 	y := monster.y + monster.animOffset
 
-	// Draw based on monster type with detailed pixel art
-	switch monster.monsterType {
-	case 0: // Fire type - Dragon-like
-		drawFireMonster(x, y, monster)
-	case 1: // Water type - Fish-like
-		drawWaterMonster(x, y, monster)
-	case 2: // Grass type - Plant-like
-		drawGrassMonster(x, y, monster)
-	case 3: // Electric type - Mouse-like
-		drawElectricMonster(x, y, monster)
-	case 4: // Psychic type - Floating creature
-		drawPsychicMonster(x, y, monster)
+	if monster.hitTimer > 0 {
+		// 攻撃を受けると左右に揺れる
+		x += math.Sin(float64(monster.hitTimer)*1.5) * 5
 	}
 
+	// 出会うたびに生成したドット絵
+	drawSprite(x, y, monster)
+
 	// アクセサリーを描画
 	drawAccessories(x, y, monster)
 }
 
-func drawFireMonster(x, y float64, monster Monster) {
-	scale := 1.2 * monster.sizeValue // サイズを適用
-	ps := float64(pixelSize) * scale
-
-	// ヒトカゲ風ピクセルアート（より詳細に）
-	charmanderPixels := [][]int{
-		{0, 0, 0, 0, 2, 2, 2, 2, 0, 0, 0, 0},
-		{0, 0, 0, 2, 1, 1, 1, 1, 2, 0, 0, 0},
-		{0, 0, 2, 1, 1, 1, 1, 1, 1, 2, 0, 0},
-		{0, 2, 1, 1, 3, 1, 1, 3, 1, 1, 2, 0},
-		{0, 2, 1, 1, 7, 1, 1, 7, 1, 1, 2, 0},
-		{2, 1, 1, 1, 1, 8, 8, 1, 1, 1, 1, 2},
-		{2, 1, 1, 1, 4, 4, 4, 4, 1, 1, 1, 2},
-		{2, 1, 1, 4, 4, 4, 4, 4, 4, 1, 1, 2},
-		{2, 1, 1, 1, 4, 4, 4, 4, 1, 1, 1, 2},
-		{0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 2, 0},
-		{0, 0, 2, 5, 5, 0, 0, 5, 5, 2, 0, 0},
-		{0, 0, 5, 5, 5, 0, 0, 5, 5, 5, 0, 0},
-		{0, 0, 0, 6, 6, 6, 6, 6, 6, 0, 0, 0},
-		{0, 0, 6, 6, 6, 9, 9, 6, 6, 6, 0, 0},
-	}
-
-	// カラーマッピング: 0=透明, 1=メインカラー, 2=ダークシェード, 3=目白, 4=腹, 5=足, 6=尻尾の炎, 7=目黒, 8=鼻, 9=炎コア
-	for row, pixels := range charmanderPixels {
-		for col, pixel := range pixels {
-			if pixel == 0 {
-				continue
-			}
-
-			px := x + float64(col-6)*ps
-			py := y + float64(row-7)*ps
+// ─────────────────────────────
+// ドット絵モンスターの自動生成
 
-			switch pixel {
-			case 1: // メインボディ
-				p.Fill(monster.color.r, monster.color.g, monster.color.b, 255)
-			case 2: // ダークアウトライン
-				p.Fill(monster.color.r*3/4, monster.color.g*3/4, monster.color.b*3/4, 255)
-			case 3: // 目白
-				p.Fill(255, 255, 255, 255)
-			case 4: // 腹（クリーム色）
-				p.Fill(255, 230, 180, 255)
-			case 5: // 足
-				p.Fill(monster.color.r*4/5, monster.color.g*4/5, monster.color.b*4/5, 255)
-			case 6: // 尻尾の炎
-				if frameCount%10 < 5 {
-					p.Fill(255, 100, 0, 255)
-				} else {
-					p.Fill(255, 200, 0, 255)
-				}
-			case 7: // 目黒
-				p.Fill(0, 0, 0, 255)
-			case 8: // 鼻
-				p.Fill(50, 50, 50, 255)
-			case 9: // 炎コア
-				if frameCount%8 < 4 {
-					p.Fill(255, 255, 150, 255)
-				} else {
-					p.Fill(255, 220, 100, 255)
-				}
-			}
+// モンスターのドット絵の大きさ（左右対称なので左半分を作って反転する）
+const (
+	spriteWidth  = 16
+	spriteHeight = 18
+	spriteHalf   = spriteWidth / 2
+)
 
-			p.NoStroke()
-			p.Rect(px, py, ps, ps)
-		}
-	}
+// ドット絵のカラーマッピング
+const (
+	pixEmpty    = iota // 透明
+	pixBody            // メインカラー
+	pixOutline         // ダークアウトライン
+	pixBelly           // 腹（明るい色）
+	pixEyeWhite        // 目白
+	pixPupil           // 目黒
+	pixAccent          // タイプの色（炎・ヒレ・葉・稲妻・宝石）
+	pixPattern         // 模様・翼
+)
 
-	// 炎のアニメーション
-	for i := 0; i < 5; i++ {
-		flameOffset := math.Sin(float64(frameCount+i*15)*0.15) * ps * 0.5
-		flameHeight := math.Sin(float64(frameCount+i*20)*0.2) * ps * 0.3
-		fx := x + float64(i-2)*ps*0.3 + flameOffset
-		fy := y + 4*ps - float64(i)*ps*0.5 + flameHeight
-
-		alpha := uint8(200 - i*30)
-		if frameCount%15 < 7 {
-			p.Fill(255, uint8(200-i*30), 0, alpha)
-		} else {
-			p.Fill(255, uint8(150-i*20), 50, alpha)
-		}
-		p.NoStroke()
-		p.Ellipse(fx, fy, ps*0.8, ps*1.2)
-	}
+// bodyPlan は種族のドット絵の種類ごとの体のつくりです
+type bodyPlan struct {
+	headW, headH [2]int // 頭の半径（横・縦）の範囲。headH が 0 なら頭と胴が一体
+	bodyW, bodyH [2]int // 胴の半径（横・縦）の範囲
+	legs         bool
+	tentacles    bool
+	ears         []string // 頭の上のパーツの候補
+	tails        []string // 胴の横のパーツの候補
+	wingChance   float64
 }
 
-func drawWaterMonster(x, y float64, monster Monster) {
-	scale := 1.2 * monster.sizeValue // サイズを適用
+var bodyPlans = map[string]bodyPlan{
+	"dragon":  {headW: [2]int{3, 4}, headH: [2]int{2, 3}, bodyW: [2]int{3, 4}, bodyH: [2]int{2, 2}, legs: true, ears: []string{"horn", "spike", "none"}, tails: []string{"flame", "spike"}, wingChance: 0.5},
+	"fish":    {bodyW: [2]int{4, 5}, bodyH: [2]int{3, 4}, ears: []string{"fin", "spike", "none"}, tails: []string{"fin"}},
+	"plant":   {headW: [2]int{3, 4}, headH: [2]int{2, 3}, bodyW: [2]int{2, 3}, bodyH: [2]int{2, 2}, legs: true, ears: []string{"leaf", "sprout"}, tails: []string{"leaf", "none"}, wingChance: 0.2},
+	"mouse":   {headW: [2]int{3, 4}, headH: [2]int{2, 3}, bodyW: [2]int{2, 3}, bodyH: [2]int{2, 2}, legs: true, ears: []string{"long", "round"}, tails: []string{"bolt", "none"}},
+	"floater": {headW: [2]int{4, 5}, headH: [2]int{3, 3}, bodyW: [2]int{2, 3}, bodyH: [2]int{1, 1}, tentacles: true, ears: []string{"crown", "antenna", "none"}, tails: []string{"none"}, wingChance: 0.3},
+}
 
-	ps := float64(pixelSize) * scale
-	swim := math.Sin(float64(frameCount)*0.15) * ps * 0.3
+// spriteCanvas はドット絵の左半分です。hx は中央からの列（0 が中央寄り）
+type spriteCanvas [spriteHeight][spriteHalf]int
 
-	// ゼニガメ風ピクセルアート
-	turtlePixels := [][]int{
-		{0, 0, 0, 0, 5, 5, 5, 5, 0, 0, 0, 0},
-		{0, 0, 0, 5, 6, 6, 6, 6, 5, 0, 0, 0},
-		{0, 0, 5, 6, 6, 6, 6, 6, 6, 5, 0, 0},
-		{0, 5, 6, 6, 6, 6, 6, 6, 6, 6, 5, 0},
-		{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 0},
-		{2, 1, 1, 3, 1, 1, 1, 1, 3, 1, 1, 2},
-		{2, 1, 1, 7, 1, 1, 1, 1, 7, 1, 1, 2},
-		{2, 1, 1, 1, 1, 8, 8, 1, 1, 1, 1, 2},
-		{2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2},
-		{0, 2, 4, 1, 1, 1, 1, 1, 1, 4, 2, 0},
-		{0, 0, 4, 4, 0, 2, 2, 0, 4, 4, 0, 0},
-		{0, 0, 0, 0, 0, 9, 9, 0, 0, 0, 0, 0},
+func (s *spriteCanvas) set(hx, row, v int) {
+	if hx >= 0 && hx < spriteHalf && row >= 0 && row < spriteHeight {
+		s[row][hx] = v
 	}
+}
 
-	for row, pixels := range turtlePixels {
-		for col, pixel := range pixels {
-			if pixel == 0 {
-				continue
+// ellipse は中央を軸に、半径 rw×rh の楕円を v で塗ります
+func (s *spriteCanvas) ellipse(cy float64, rw, rh, v int) {
+	for row := 0; row < spriteHeight; row++ {
+		for hx := 0; hx < spriteHalf; hx++ {
+			dx := (float64(hx) + 0.5) / (float64(rw) + 0.5)
+			dy := (float64(row) + 0.5 - cy) / (float64(rh) + 0.5)
+			if dx*dx+dy*dy <= 1 {
+				s[row][hx] = v
 			}
+		}
+	}
+}
 
-			px := x + float64(col-6)*ps
-			py := y + float64(row-6)*ps + swim
-
-			switch pixel {
-			case 1: // Main body
-				p.Fill(monster.color.r, monster.color.g, monster.color.b, 255)
-			case 2: // Dark outline
-				p.Fill(monster.color.r*3/4, monster.color.g*3/4, monster.color.b*3/4, 255)
-			case 3: // Eyes white
-				p.Fill(255, 255, 255, 255)
-			case 4: // Fins/hands
-				p.Fill(monster.color.r-30, monster.color.g-30, monster.color.b+30, 200)
-			case 5: // Shell edge
-				p.Fill(100, 80, 60, 255)
-			case 6: // Shell
-				if (row+col)%2 == 0 {
-					p.Fill(120, 100, 80, 255)
-				} else {
-					p.Fill(140, 120, 100, 255)
-				}
-			case 7: // Eye pupil
-				p.Fill(0, 0, 0, 255)
-			case 8: // Nose
-				p.Fill(50, 50, 100, 255)
-			case 9: // Tail
-				waveOffset := math.Sin(float64(frameCount)*0.2+float64(col)*0.5) * ps * 0.2
-				py += waveOffset
-				p.Fill(monster.color.r*4/5, monster.color.g*4/5, monster.color.b*4/5, 255)
+// generateMonsterSprite は seed と体のつくりから左右対称のドット絵を作ります
+// 同じ seed と plan からはいつも同じドット絵になります
+func generateMonsterSprite(seed int64, plan string) [][]int {
+	bp, ok := bodyPlans[plan]
+	if !ok {
+		bp = bodyPlans["dragon"]
+	}
+	rng := rand.New(rand.NewSource(seed))
+	between := func(r [2]int) int { return r[0] + rng.Intn(r[1]-r[0]+1) }
+	pick := func(parts []string) string { return parts[rng.Intn(len(parts))] }
+
+	var s spriteCanvas
+	bodyW, bodyH := between(bp.bodyW), between(bp.bodyH)
+	headW, headH := 0, 0
+	if bp.headH[1] > 0 {
+		headW, headH = between(bp.headW), between(bp.headH)
+	}
+
+	// 上から耳・頭・胴・足の順に並べる
+	headTop := 3
+	headCY := float64(headTop + headH)
+	bodyCY := headCY + float64(headH+bodyH) - 1 // 頭と胴を少し重ねる
+	if headH == 0 {
+		bodyCY = float64(headTop + bodyH)
+	}
+	bodyTop := int(bodyCY) - bodyH
+	bodyBottom := int(bodyCY) + bodyH
+	// 足やしっぽの分を残して下にはみ出さないようにする
+	if over := bodyBottom + 3 - spriteHeight; over > 0 {
+		headCY -= float64(over)
+		bodyCY -= float64(over)
+		bodyTop -= over
+		bodyBottom -= over
+		headTop -= over
+	}
+
+	// 翼（胴の後ろ）
+	if rng.Float64() < bp.wingChance {
+		for i := 0; i < 3; i++ {
+			for row := bodyTop - 2 + i; row <= bodyTop+1; row++ {
+				s.set(bodyW+i, row, pixPattern)
 			}
-
-			p.NoStroke()
-			p.Rect(px, py, ps, ps)
 		}
 	}
 
-	// 波紋エフェクト
-	if frameCount%30 < 15 {
+	// しっぽ・ヒレ（胴の横）
+	switch pick(bp.tails) {
+	case "flame":
+		for row := bodyBottom - 2; row <= bodyBottom; row++ {
+			s.set(bodyW+1, row, pixAccent)
+		}
+		s.set(bodyW+2, bodyBottom-1, pixAccent)
+	case "spike":
+		for row := bodyTop; row <= bodyBottom; row += 2 {
+			s.set(bodyW+1, row, pixAccent)
+		}
+	case "fin":
+		mid := int(bodyCY)
 		for i := 0; i < 3; i++ {
-			ringRadius := float64(i+1)*15 + float64(frameCount%30)
-			alpha := uint8(100 - i*30 - frameCount%30*2)
-			p.Stroke(100, 150, 255, alpha)
-			p.StrokeWeight(2)
-			p.NoFill()
-			p.Ellipse(x, y+ps*2, ringRadius, ringRadius/2)
+			for row := mid - i; row <= mid+i; row++ {
+				s.set(bodyW+1+i, row, pixAccent)
+			}
+		}
+	case "leaf":
+		s.set(bodyW+1, bodyTop+1, pixAccent)
+		s.set(bodyW+2, bodyTop, pixAccent)
+		s.set(bodyW+2, bodyTop+1, pixAccent)
+	case "bolt":
+		for i, row := 0, bodyTop-1; row <= bodyBottom; i, row = i+1, row+1 {
+			s.set(bodyW+1+i%2, row, pixAccent)
 		}
-		p.NoStroke()
 	}
 
-	// Bubbles
-	for i := 0; i < 2; i++ {
-		bubbleY := y - float64(4+i*2)*ps - float64(frameCount%40)*0.5
-		if bubbleY > y-8*ps {
-			p.Fill(200, 220, 255, 150)
-			p.Rect(x+float64(i-1)*ps*2, bubbleY, ps*0.6, ps*0.6)
+	// 足と触手（胴の下）
+	if bp.legs {
+		for row := bodyBottom; row <= bodyBottom+2; row++ {
+			for hx := max(1, bodyW-2); hx < bodyW; hx++ {
+				s.set(hx, row, pixBody)
+			}
+		}
+	}
+	if bp.tentacles {
+		for hx := 0; hx <= bodyW; hx += 2 {
+			for row := bodyBottom; row <= bodyBottom+3; row++ {
+				s.set(hx+(row-bodyBottom)%2, row, pixBody)
+			}
 		}
 	}
-}
-
-func drawGrassMonster(x, y float64, monster Monster) {
-	scale := 1.2 * monster.sizeValue // サイズを適用
-
-	ps := float64(pixelSize) * scale
 
-	// フシギダネ風ピクセルアート
-	bulbasaurPixels := [][]int{
-		{0, 0, 0, 5, 5, 5, 5, 5, 5, 0, 0, 0},
-		{0, 0, 5, 6, 5, 5, 5, 5, 6, 5, 0, 0},
-		{0, 5, 6, 6, 5, 5, 5, 5, 6, 6, 5, 0},
-		{0, 0, 2, 2, 2, 2, 2, 2, 2, 2, 0, 0},
-		{0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 2, 0},
-		{2, 1, 1, 3, 1, 1, 1, 1, 3, 1, 1, 2},
-		{2, 1, 1, 7, 1, 8, 8, 1, 7, 1, 1, 2},
-		{2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2},
-		{2, 9, 1, 1, 1, 1, 1, 1, 1, 1, 9, 2},
-		{0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 2, 0},
-		{0, 0, 2, 4, 4, 0, 0, 4, 4, 2, 0, 0},
-		{0, 0, 4, 4, 4, 0, 0, 4, 4, 4, 0, 0},
+	// 胴と頭
+	s.ellipse(bodyCY, bodyW, bodyH, pixBody)
+	if headH > 0 {
+		s.ellipse(headCY, headW, headH, pixBody)
 	}
 
-	for row, pixels := range bulbasaurPixels {
-		for col, pixel := range pixels {
-			if pixel == 0 {
+	// 模様
+	pattern := rng.Intn(3)
+	for row := bodyTop; row <= bodyBottom; row++ {
+		for hx := 0; hx < spriteHalf; hx++ {
+			if s[row][hx] != pixBody {
 				continue
 			}
-
-			px := x + float64(col-6)*ps
-			py := y + float64(row-6)*ps
-
-			switch pixel {
-			case 1: // Main body
-				p.Fill(monster.color.r, monster.color.g, monster.color.b, 255)
-			case 2: // Dark outline
-				p.Fill(monster.color.r*3/4, monster.color.g*3/4, monster.color.b*3/4, 255)
-			case 3: // Eyes white
-				p.Fill(255, 255, 255, 255)
-			case 4: // Roots/feet
-				p.Fill(monster.color.r*4/5, monster.color.g*4/5, monster.color.b*4/5, 255)
-			case 5: // Bulb leaves
-				p.Fill(50, 150, 50, 255)
-			case 6: // Bulb flower
-				if frameCount%60 < 30 {
-					p.Fill(255, 150, 200, 255)
-				} else {
-					p.Fill(255, 200, 150, 255)
+			switch pattern {
+			case 0: // 腹
+				dx := (float64(hx) + 0.5) / float64(max(1, bodyW-1))
+				dy := (float64(row) + 0.5 - bodyCY) / float64(bodyH)
+				if dx*dx+dy*dy <= 0.8 {
+					s[row][hx] = pixBelly
+				}
+			case 1: // しま模様
+				if row%2 == 0 && hx >= bodyW-2 {
+					s[row][hx] = pixPattern
+				}
+			case 2: // 水玉
+				if rng.Float64() < 0.15 {
+					s[row][hx] = pixPattern
 				}
-			case 7: // Eye pupil
-				p.Fill(0, 0, 0, 255)
-			case 8: // Nose
-				p.Fill(50, 100, 50, 255)
-			case 9: // Spots
-				p.Fill(monster.color.r/2, monster.color.g, monster.color.b/2, 255)
 			}
-
-			p.NoStroke()
-			p.Rect(px, py, ps, ps)
 		}
 	}
 
-	// 花びらエフェクト
-	for i := 0; i < 3; i++ {
-		petalOffset := math.Sin(float64(frameCount+i*40)*0.1) * ps * 2
-		petalY := y - float64(5+i)*ps + petalOffset
-		petalX := x + float64(i-1)*ps*3
-
-		if frameCount%60 < 30 {
-			p.Fill(255, 150, 200, 150)
-		} else {
-			p.Fill(255, 200, 150, 150)
+	// 耳・角など（頭の上）
+	top := headTop
+	earW := headW
+	if headH == 0 {
+		top = bodyTop
+		earW = bodyW
+	}
+	switch pick(bp.ears) {
+	case "horn":
+		s.set(earW-1, top-1, pixAccent)
+		s.set(earW-1, top-2, pixAccent)
+		s.set(earW, top-3, pixAccent)
+	case "spike":
+		for row := top - 3; row < top; row++ {
+			s.set(0, row, pixAccent)
+		}
+	case "fin":
+		for row := top - 2; row < top; row++ {
+			s.set(0, row, pixAccent)
+			s.set(1, row+1, pixAccent)
+		}
+	case "leaf":
+		s.set(1, top-1, pixAccent)
+		s.set(2, top-2, pixAccent)
+		s.set(3, top-2, pixAccent)
+		s.set(3, top-3, pixAccent)
+	case "sprout":
+		s.set(0, top-1, pixAccent)
+		s.set(0, top-2, pixAccent)
+		s.set(1, top-3, pixAccent)
+		s.set(2, top-3, pixAccent)
+	case "long":
+		for row := top - 3; row < top+1; row++ {
+			s.set(earW-2, row, pixBody)
+			s.set(earW-1, row, pixBody)
+		}
+		s.set(earW-2, top-3, pixAccent)
+		s.set(earW-1, top-3, pixAccent)
+	case "round":
+		s.set(earW-1, top-1, pixBody)
+		s.set(earW-2, top-1, pixBody)
+		s.set(earW-1, top-2, pixBody)
+		s.set(earW-2, top-2, pixBelly)
+	case "crown":
+		for hx := 0; hx < earW; hx += 2 {
+			s.set(hx, top-1, pixAccent)
+		}
+	case "antenna":
+		s.set(2, top-1, pixOutline)
+		s.set(2, top-2, pixOutline)
+		s.set(3, top-3, pixAccent)
+	}
+
+	// 目と口
+	eyeRow := int(headCY)
+	eyeX := max(1, headW-2)
+	if headH == 0 {
+		eyeRow = bodyTop + 1 + rng.Intn(2)
+		eyeX = max(1, bodyW-2)
+	}
+	s.set(eyeX, eyeRow, pixPupil)
+	s.set(eyeX, eyeRow-1, pixEyeWhite)
+	if rng.Intn(2) == 0 {
+		s.set(eyeX-1, eyeRow, pixEyeWhite) // 大きな目
+	}
+	s.set(0, eyeRow+2, pixOutline)
+
+	// 左半分を反転して全体にし、まわりをアウトラインで囲む
+	pixels := make([][]int, spriteHeight)
+	for row := range pixels {
+		pixels[row] = make([]int, spriteWidth)
+		for hx := 0; hx < spriteHalf; hx++ {
+			pixels[row][spriteHalf-1-hx] = s[row][hx]
+			pixels[row][spriteHalf+hx] = s[row][hx]
 		}
-		p.NoStroke()
-		p.Ellipse(petalX, petalY, ps, ps*1.5)
-	}
-}
-
-func drawElectricMonster(x, y float64, monster Monster) {
-	scale := 1.0 * monster.sizeValue // サイズを適用
-
-	ps := float64(pixelSize) * scale
-	staticOffset := math.Sin(float64(frameCount)*0.3) * ps * 0.2
-
-	// Draw pixel art electric mouse
-	mousePixels := [][]int{
-		{0, 2, 0, 0, 0, 0, 0, 2, 0},
-		{2, 2, 0, 0, 0, 0, 0, 2, 2},
-		{0, 2, 1, 1, 1, 1, 1, 2, 0},
-		{2, 1, 3, 1, 1, 1, 3, 1, 2},
-		{2, 5, 1, 1, 1, 1, 1, 5, 2},
-		{2, 1, 1, 1, 4, 1, 1, 1, 2},
-		{0, 2, 1, 1, 1, 1, 1, 2, 0},
-		{0, 0, 2, 1, 1, 1, 2, 0, 0},
-		{0, 0, 2, 2, 0, 2, 2, 0, 0},
 	}
-
-	for row, pixels := range mousePixels {
-		for col, pixel := range pixels {
-			if pixel == 0 {
+	outlined := make([][]int, spriteHeight)
+	for row := range pixels {
+		outlined[row] = append([]int(nil), pixels[row]...)
+		for col := range pixels[row] {
+			if pixels[row][col] != pixEmpty {
 				continue
 			}
-
-			px := x + float64(col-4)*ps
-			py := y + float64(row-4)*ps + staticOffset
-
-			switch pixel {
-			case 1: // Main body
-				p.Fill(monster.color.r, monster.color.g, monster.color.b, 255)
-			case 2: // Dark outline/ears
-				p.Fill(0, 0, 0, 255)
-			case 3: // Eyes
-				p.Fill(255, 255, 255, 255)
-			case 4: // Lightning bolt
-				p.Fill(255, 255, 150, 255)
-			case 5: // Cheeks
-				p.Fill(255, 100, 100, 255)
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				r, c := row+d[0], col+d[1]
+				if r >= 0 && r < spriteHeight && c >= 0 && c < spriteWidth && pixels[r][c] != pixEmpty && pixels[r][c] != pixOutline {
+					outlined[row][col] = pixOutline
+					break
+				}
 			}
-
-			p.NoStroke()
-			p.Rect(px, py, ps, ps)
 		}
 	}
 
-	// Eye pupils
-	p.Fill(0, 0, 0, 255)
-	p.Rect(x-2*ps, y-ps+staticOffset, ps*0.5, ps*0.5)
-	p.Rect(x+1.5*ps, y-ps+staticOffset, ps*0.5, ps*0.5)
-
-	// Electric sparks
-	if frameCount%10 < 5 {
-		for i := 0; i < 3; i++ {
-			angle := float64(i) * 2 * math.Pi / 3
-			sparkX := x + math.Cos(angle)*5*ps
-			sparkY := y + math.Sin(angle)*5*ps
+	// 足元がそろうように一番下まで下げる
+	bottom := spriteHeight - 1
+	for bottom > 0 && !slices.ContainsFunc(outlined[bottom], func(v int) bool { return v != pixEmpty }) {
+		bottom--
+	}
+	return append(outlined[bottom+1:], outlined[:bottom+1]...)
+}
 
-			p.Fill(255, 255, 0, 200)
-			p.Rect(sparkX, sparkY, ps*0.4, ps*0.4)
+// accentColor はタイプごとのアクセントの色です（炎や稲妻はちらつく）
+func accentColor(monsterType int) (uint8, uint8, uint8) {
+	switch monsterType {
+	case typeFire:
+		if frameCount%10 < 5 {
+			return 255, 100, 0
 		}
+		return 255, 200, 0
+	case typeWater:
+		return 150, 230, 255
+	case typeGrass:
+		return 40, 160, 60
+	case typeElectric:
+		if frameCount%6 < 3 {
+			return 255, 230, 0
+		}
+		return 255, 255, 200
+	default:
+		return 255, 100, 200
 	}
 }
 
-func drawPsychicMonster(x, y float64, monster Monster) {
-	scale := 1.0 * monster.sizeValue // サイズを適用
-
-	ps := float64(pixelSize) * scale
-	float := math.Sin(float64(frameCount)*0.1) * ps * 0.5
+// drawSprite は生成したドット絵をタイプの色で描画します
+func drawSprite(x, y float64, monster Monster) {
+	ps := float64(pixelSize) * monster.sizeValue // サイズを適用
 
-	// Draw pixel art psychic creature
-	psychicPixels := [][]int{
-		{0, 0, 5, 5, 5, 5, 5, 0, 0},
-		{0, 0, 2, 2, 2, 2, 2, 0, 0},
-		{0, 2, 1, 1, 1, 1, 1, 2, 0},
-		{2, 1, 3, 1, 4, 1, 3, 1, 2},
-		{2, 1, 1, 1, 1, 1, 1, 1, 2},
-		{2, 1, 1, 1, 1, 1, 1, 1, 2},
-		{0, 2, 1, 1, 1, 1, 1, 2, 0},
-		{0, 0, 2, 6, 6, 6, 2, 0, 0},
-		{0, 0, 6, 0, 0, 0, 6, 0, 0},
+	// 体のつくりに合わせて少し動かす
+	switch monster.sprite {
+	case "fish":
+		x += math.Sin(float64(frameCount)*0.15) * ps * 0.3
+	case "floater":
+		y += math.Sin(float64(frameCount)*0.1) * ps * 0.5
 	}
 
-	// Psychic aura
-	for i := 2; i > 0; i-- {
-		alpha := uint8(30 * (3 - i))
-		p.Fill(200, 150, 255, alpha)
-		p.NoStroke()
-		p.Rect(x-float64(i+3)*ps, y-float64(i+3)*ps+float, float64(2*i+7)*ps, float64(2*i+7)*ps)
-	}
-
-	for row, pixels := range psychicPixels {
+	c := monster.color
+	for row, pixels := range monster.pixels {
 		for col, pixel := range pixels {
-			if pixel == 0 {
-				continue
-			}
-
-			px := x + float64(col-4)*ps
-			py := y + float64(row-4)*ps + float
-
 			switch pixel {
-			case 1: // Main body
-				p.Fill(monster.color.r, monster.color.g, monster.color.b, 255)
-			case 2: // Dark outline
-				p.Fill(monster.color.r/2, monster.color.g/2, monster.color.b/2, 255)
-			case 3: // Eyes
+			case pixEmpty:
+				continue
+			case pixBody:
+				p.Fill(c.r, c.g, c.b, 255)
+			case pixOutline:
+				p.Fill(c.r/2, c.g/2, c.b/2, 255)
+			case pixBelly:
+				p.Fill(uint8((int(c.r)+510)/3), uint8((int(c.g)+510)/3), uint8((int(c.b)+510)/3), 255)
+			case pixEyeWhite:
 				p.Fill(255, 255, 255, 255)
-			case 4: // Gem
-				p.Fill(255, 100, 200, 255)
-			case 5: // Crown/horns
-				p.Fill(255, 200, 100, 255)
-			case 6: // Tentacles
-				p.Fill(monster.color.r-30, monster.color.g-30, monster.color.b-30, 200)
+			case pixPupil:
+				p.Fill(0, 0, 0, 255)
+			case pixAccent:
+				r, g, b := accentColor(monster.monsterType)
+				p.Fill(r, g, b, 255)
+			case pixPattern:
+				p.Fill(c.r*3/4, c.g*3/4, c.b*3/4, 255)
 			}
-
 			p.NoStroke()
-			p.Rect(px, py, ps, ps)
+			p.Rect(x+float64(col-spriteHalf)*ps, y+float64(row-spriteHeight/2)*ps, ps, ps)
 		}
 	}
-
-	// Eye pupils with glow
-	eyeGlow := math.Sin(float64(frameCount)*0.2)*127 + 128
-	p.Fill(150, 100, 200, uint8(eyeGlow))
-	p.Rect(x-2*ps, y-ps+float, ps*0.7, ps*0.7)
-	p.Rect(x+1.3*ps, y-ps+float, ps*0.7, ps*0.7)
-
-	// Orbiting particles
-	for i := 0; i < 4; i++ {
-		angle := float64(i)*math.Pi/2 + float64(frameCount)*0.05
-		particleX := x + math.Cos(angle)*6*ps
-		particleY := y + math.Sin(angle)*6*ps
-
-		p.Fill(255, 200, 255, 150)
-		p.Rect(particleX, particleY, ps*0.5, ps*0.5)
-	}
 }
 
 func updateAnimations() {
 	// モンスターの待機アニメーション更新
 	wildMonster.animOffset = math.Sin(float64(frameCount)*0.3) * 3 // 高速化
 
-	// モンスターボールのアニメーション更新
-	if pokeball.state == "thrown" {
-		// 軌跡を追加
-		pokeball.trailX = append(pokeball.trailX, pokeball.x)
-		pokeball.trailY = append(pokeball.trailY, pokeball.y)
-		if len(pokeball.trailX) > 10 {
-			pokeball.trailX = pokeball.trailX[1:]
-			pokeball.trailY = pokeball.trailY[1:]
-		}
-
-		// モンスターに向かって移動（大幅に高速化）
-		pokeball.x += pokeball.vx * 3
-		pokeball.y += pokeball.vy * 3
-		pokeball.vy += 0.8 // 重力
-
-		// モンスターにヒットしたかチェック
-		dist := math.Sqrt(math.Pow(pokeball.x-wildMonster.x, 2) + math.Pow(pokeball.y-wildMonster.y, 2))
-		if dist < 30 {
-			pokeball.state = "shaking"
-			pokeball.shakeCount = 0
-			pokeball.shakeTimer = 0
-			captureState = "shaking"
-
-			// モンスターを隠す（ボールに吸い込まれる演出）
-			// リッチな吸い込みエフェクト
-			for wave := 0; wave < 3; wave++ {
-				for i := 0; i < 30; i++ {
-					angle := float64(i) * math.Pi * 2 / 30
-					radius := float64(wave+1) * 20
-
-					particles = append(particles, Particle{
-						x:    wildMonster.x + math.Cos(angle)*radius,
-						y:    wildMonster.y + math.Sin(angle)*radius,
-						vx:   -math.Cos(angle) * 6,
-						vy:   -math.Sin(angle) * 6,
-						life: 1.0 + float64(wave)*0.2,
-						color: struct{ r, g, b, a uint8 }{
-							r: uint8(255 - wave*50),
-							g: uint8(100 + wave*30),
-							b: uint8(100 + wave*50),
-							a: uint8(250 - wave*30),
-						},
-						size:         rand.Float64()*4 + 3,
-						particleType: "capture",
-					})
-				}
-			}
+	// HPバーと攻撃を受けた揺れを更新
+	updateBattle()
 
-			// スピードラインエフェクト
-			for i := 0; i < 15; i++ {
-				angle := rand.Float64() * math.Pi * 2
-				speed := rand.Float64()*10 + 5
+	// テキストアニメーション更新
+	if textAnimation.text != "" {
+		textAnimation.progress += 0.08 // 大幅に高速化
+
+		if textAnimation.fadeOut && textAnimation.progress > 1.0 {
+			textAnimation.text = ""
+		}
+	}
+}
+
+// updatePokeballFlight は飛んでいるボールを動かし、モンスターに当たったら揺れる状態へ移ります
+func updatePokeballFlight() {
+	// 軌跡を追加
+	pokeball.trailX = append(pokeball.trailX, pokeball.x)
+	pokeball.trailY = append(pokeball.trailY, pokeball.y)
+	if len(pokeball.trailX) > 10 {
+		pokeball.trailX = pokeball.trailX[1:]
+		pokeball.trailY = pokeball.trailY[1:]
+	}
+
+	// モンスターに向かって移動（大幅に高速化）
+	pokeball.x += pokeball.vx * 3
+	pokeball.y += pokeball.vy * 3
+	pokeball.vy += 0.8 // 重力
+
+	// モンスターにヒットしたかチェック
+	dist := math.Sqrt(math.Pow(pokeball.x-wildMonster.x, 2) + math.Pow(pokeball.y-wildMonster.y, 2))
+	if dist < 30 {
+		// モンスターを隠す（ボールに吸い込まれる演出）
+		// リッチな吸い込みエフェクト
+		for wave := 0; wave < 3; wave++ {
+			for i := 0; i < 30; i++ {
+				angle := float64(i) * math.Pi * 2 / 30
+				radius := float64(wave+1) * 20
 
 				particles = append(particles, Particle{
-					x:    wildMonster.x,
-					y:    wildMonster.y,
-					vx:   math.Cos(angle) * speed,
-					vy:   math.Sin(angle) * speed,
-					life: 0.5,
+					x:    wildMonster.x + math.Cos(angle)*radius,
+					y:    wildMonster.y + math.Sin(angle)*radius,
+					vx:   -math.Cos(angle) * 6,
+					vy:   -math.Sin(angle) * 6,
+					life: 1.0 + float64(wave)*0.2,
 					color: struct{ r, g, b, a uint8 }{
-						r: 255,
-						g: 255,
-						b: 255,
-						a: 200,
+						r: uint8(255 - wave*50),
+						g: uint8(100 + wave*30),
+						b: uint8(100 + wave*50),
+						a: uint8(250 - wave*30),
 					},
-					size:         1,
-					particleType: "line",
+					size:         rand.Float64()*4 + 3,
+					particleType: "capture",
 				})
 			}
 		}
-	} else if pokeball.state == "shaking" {
-		pokeball.shakeTimer++
-
-		// 3回揺れる（さらに高速）
-		if pokeball.shakeTimer%5 == 0 { // 10から5に変更で2倍速
-			pokeball.shakeCount++
 
-			if pokeball.shakeCount >= 3 {
-				// 捕獲成功判定（ボールタイプによって補正）
-				catchBonus := 1.0
-				switch pokeball.ballType {
-				case 1: // スーパーボール
-					catchBonus = 1.5
-				case 2: // ハイパーボール
-					catchBonus = 2.0
-				case 3: // マスターボール
-					catchBonus = 100.0 // 必ず捕まえる
-				}
+		// スピードラインエフェクト
+		for i := 0; i < 15; i++ {
+			angle := rand.Float64() * math.Pi * 2
+			speed := rand.Float64()*10 + 5
+
+			particles = append(particles, Particle{
+				x:    wildMonster.x,
+				y:    wildMonster.y,
+				vx:   math.Cos(angle) * speed,
+				vy:   math.Sin(angle) * speed,
+				life: 0.5,
+				color: struct{ r, g, b, a uint8 }{
+					r: 255,
+					g: 255,
+					b: 255,
+					a: 200,
+				},
+				size:         1,
+				particleType: "line",
+			})
+		}
+		goTo(gamestate.Shaking)
+	}
+}
 
-				if rand.Float64() < wildMonster.catchRate*catchBonus {
-					// 成功！画面全体で祝福！
-					captureState = "success"
-					pokeball.state = "captured"
-
-					// ボールをモンスターの横に配置
-					pokeball.x = wildMonster.x - 50
-					pokeball.y = wildMonster.y + 20
-
-					// レア度に応じたエフェクト
-					particleCount := 80 + wildMonster.rarity*50
-
-					// リング状のエフェクト（レア度に応じて）
-					for ring := 0; ring < wildMonster.rarity+2; ring++ {
-						for i := 0; i < 36; i++ {
-							angle := float64(i) * math.Pi * 2 / 36
-							radius := float64(ring+1) * 25
-							particles = append(particles, Particle{
-								x:    pokeball.x + math.Cos(angle)*radius,
-								y:    pokeball.y + math.Sin(angle)*radius,
-								vx:   math.Cos(angle) * 4,
-								vy:   math.Sin(angle) * 4,
-								life: 2.0 + float64(ring)*0.2,
-								color: struct{ r, g, b, a uint8 }{
-									r: uint8(255),
-									g: uint8(215 - ring*20),
-									b: uint8(0 + ring*40),
-									a: uint8(255 - ring*20),
-								},
-								size:         float64(8-ring) + rand.Float64()*4,
-								particleType: "star",
-							})
-						}
-					}
-
-					// 爆発エフェクト
-					for i := 0; i < 50; i++ {
-						angle := rand.Float64() * math.Pi * 2
-						speed := rand.Float64()*15 + 5
-
-						particles = append(particles, Particle{
-							x:    pokeball.x,
-							y:    pokeball.y,
-							vx:   math.Cos(angle) * speed,
-							vy:   math.Sin(angle) * speed,
-							life: 1.5,
-							color: struct{ r, g, b, a uint8 }{
-								r: uint8(rand.Intn(56) + 200),
-								g: uint8(rand.Intn(56) + 200),
-								b: uint8(rand.Intn(100) + 155),
-								a: 255,
-							},
-							size:         rand.Float64()*6 + 2,
-							particleType: "circle",
-						})
-					}
-
-					// 画面全体に大量の祝福パーティクル
-					for i := 0; i < particleCount; i++ {
-						angle := rand.Float64() * math.Pi * 2
-						speed := rand.Float64()*8 + 2
-
-						// 画面の色々な場所から
-						startX := rand.Float64() * 400
-						startY := 400.0 // 下から打ち上げ
-
-						particles = append(particles, Particle{
-							x:    startX,
-							y:    startY,
-							vx:   math.Cos(angle) * speed * 0.3,
-							vy:   -speed - rand.Float64()*5, // 上に打ち上げ
-							life: 2.0,
-							color: struct{ r, g, b, a uint8 }{
-								r: uint8(rand.Intn(100) + 155),
-								g: uint8(rand.Intn(100) + 155),
-								b: uint8(rand.Intn(100) + 155),
-								a: 255,
-							},
-							size:         rand.Float64()*8 + 4,
-							particleType: "star",
-						})
-					}
-				} else {
-					// 失敗時の処理
-					// ボールから逃げ出すエフェクト
-					for i := 0; i < 20; i++ {
-						angle := rand.Float64() * math.Pi * 2
-						speed := rand.Float64()*5 + 3
-
-						particles = append(particles, Particle{
-							x:    pokeball.x,
-							y:    pokeball.y,
-							vx:   math.Cos(angle) * speed,
-							vy:   math.Sin(angle) * speed,
-							life: 0.8,
-							color: struct{ r, g, b, a uint8 }{
-								r: 255,
-								g: 100,
-								b: 100,
-								a: 200,
-							},
-							size:         rand.Float64()*3 + 2,
-							particleType: "escape",
-						})
-					}
-
-					if captureAttempts >= maxAttempts {
-						// 3回失敗したらゲームオーバー（逃げられた）
-						captureState = "gameover"
-						pokeball.state = "idle"
-						// ここで停止（自動で次のボールを投げない）
-					} else {
-						// まだ試行回数が残っている場合は自動で次のボールを投げる
-						captureState = "failed"
-						pokeball.state = "idle"
-
-						// 自動で次のボールを投げる
-						go func() {
-							time.Sleep(800 * time.Millisecond) // 少し間を置く
-							if captureState == "failed" && captureAttempts < maxAttempts {
-								// 自動で次のボールを投げる
-								throwPokeball()
-							}
-						}()
-					}
-				}
+// resolveCapture はボールが揺れ終わったときに捕獲できたかを決め、次の状態を返します
+func resolveCapture() gamestate.State {
+	// 捕獲成功判定（ボールタイプ・HP・レア度・サイズによって補正）
+	chance := capture.Probability(wildMonster.catchRate, capture.Ball(pokeball.ballType), wildMonster.hp, wildMonster.maxHP, wildMonster.rarity, wildMonster.sizeValue)
+	if rand.Float64() < chance {
+		// 成功！画面全体で祝福！
+		recordCapture(wildMonster)
+
+		// ボールをモンスターの横に配置
+		pokeball.x = wildMonster.x - 50
+		pokeball.y = wildMonster.y + 20
+
+		// レア度に応じたエフェクト
+		particleCount := 80 + wildMonster.rarity*50
+
+		// リング状のエフェクト（レア度に応じて）
+		for ring := 0; ring < wildMonster.rarity+2; ring++ {
+			for i := 0; i < 36; i++ {
+				angle := float64(i) * math.Pi * 2 / 36
+				radius := float64(ring+1) * 25
+				particles = append(particles, Particle{
+					x:    pokeball.x + math.Cos(angle)*radius,
+					y:    pokeball.y + math.Sin(angle)*radius,
+					vx:   math.Cos(angle) * 4,
+					vy:   math.Sin(angle) * 4,
+					life: 2.0 + float64(ring)*0.2,
+					color: struct{ r, g, b, a uint8 }{
+						r: uint8(255),
+						g: uint8(215 - ring*20),
+						b: uint8(0 + ring*40),
+						a: uint8(255 - ring*20),
+					},
+					size:         float64(8-ring) + rand.Float64()*4,
+					particleType: "star",
+				})
 			}
 		}
-	}
 
-	// テキストアニメーション更新
-	if textAnimation.text != "" {
-		textAnimation.progress += 0.08 // 大幅に高速化
+		// 爆発エフェクト
+		for i := 0; i < 50; i++ {
+			angle := rand.Float64() * math.Pi * 2
+			speed := rand.Float64()*15 + 5
+
+			particles = append(particles, Particle{
+				x:    pokeball.x,
+				y:    pokeball.y,
+				vx:   math.Cos(angle) * speed,
+				vy:   math.Sin(angle) * speed,
+				life: 1.5,
+				color: struct{ r, g, b, a uint8 }{
+					r: uint8(rand.Intn(56) + 200),
+					g: uint8(rand.Intn(56) + 200),
+					b: uint8(rand.Intn(100) + 155),
+					a: 255,
+				},
+				size:         rand.Float64()*6 + 2,
+				particleType: "circle",
+			})
+		}
 
-		if textAnimation.fadeOut && textAnimation.progress > 1.0 {
-			textAnimation.text = ""
+		// 画面全体に大量の祝福パーティクル
+		for i := 0; i < particleCount; i++ {
+			angle := rand.Float64() * math.Pi * 2
+			speed := rand.Float64()*8 + 2
+
+			// 画面の色々な場所から
+			startX := rand.Float64() * 400
+			startY := 400.0 // 下から打ち上げ
+
+			particles = append(particles, Particle{
+				x:    startX,
+				y:    startY,
+				vx:   math.Cos(angle) * speed * 0.3,
+				vy:   -speed - rand.Float64()*5, // 上に打ち上げ
+				life: 2.0,
+				color: struct{ r, g, b, a uint8 }{
+					r: uint8(rand.Intn(100) + 155),
+					g: uint8(rand.Intn(100) + 155),
+					b: uint8(rand.Intn(100) + 155),
+					a: 255,
+				},
+				size:         rand.Float64()*8 + 4,
+				particleType: "star",
+			})
 		}
-	}
+		return gamestate.Success
+	}
+
+	// 失敗時の処理
+	// ボールから逃げ出すエフェクト
+	for i := 0; i < 20; i++ {
+		angle := rand.Float64() * math.Pi * 2
+		speed := rand.Float64()*5 + 3
+
+		particles = append(particles, Particle{
+			x:    pokeball.x,
+			y:    pokeball.y,
+			vx:   math.Cos(angle) * speed,
+			vy:   math.Sin(angle) * speed,
+			life: 0.8,
+			color: struct{ r, g, b, a uint8 }{
+				r: 255,
+				g: 100,
+				b: 100,
+				a: 200,
+			},
+			size:         rand.Float64()*3 + 2,
+			particleType: "escape",
+		})
+	}
+
+	if captureAttempts >= maxAttempts {
+		// 3回失敗したらゲームオーバー（逃げられた）
+		return gamestate.GameOver
+	}
+	// まだ試行回数が残っている場合は、たたかうか もう一度投げるかを選ぶ
+	return gamestate.Failed
 }
 
 func updateParticles() {
@@ -1566,7 +1946,8 @@ func drawAccessories(x, y float64, monster Monster) {
 	}
 }
 
-func drawCaptureUI() {
+// drawCaptureUI はテキストボックスを描画し、その中に text で今の状態の表示を出します
+func drawCaptureUI(text func()) {
 	// テキストボックスの背景を描画
 	p.Fill(255, 255, 255, 255)
 	p.NoStroke()
@@ -1578,55 +1959,7 @@ func drawCaptureUI() {
 	p.Fill(255, 255, 255, 255)
 	p.TextSize(14)
 
-	if captureState == "encounter" {
-		shinyMark := ""
-		if wildMonster.isShiny {
-			shinyMark = "✨"
-		}
-		p.Text(fmt.Sprintf("やせいの %s%s があらわれた！", shinyMark, wildMonster.name), 30, 330)
-		p.TextSize(10)
-		p.Text("クリックで ボールを なげる！（ランダム）", 30, 350)
-	} else if captureState == "failed" {
-		p.Text(fmt.Sprintf("%s は ボールから でてしまった！", wildMonster.name), 30, 330)
-		p.TextSize(12)
-		p.Text(fmt.Sprintf("のこり %d かい", maxAttempts-captureAttempts), 30, 350)
-		p.TextSize(10)
-		p.Text("クリックで もういちど ボールを なげる！", 30, 370)
-	} else if captureState == "throwing" {
-		ballNames := []string{"モンスター", "スーパー", "ハイパー", "マスター"}
-		p.Text(fmt.Sprintf("いけっ！ %sボール！", ballNames[pokeball.ballType]), 30, 340)
-	} else if captureState == "shaking" {
-		shakeText := ""
-		for i := 0; i < pokeball.shakeCount; i++ {
-			shakeText += "・"
-		}
-		p.Text(shakeText, 30, 340)
-	} else if captureState == "success" {
-		// 成功時の表示（捕獲後に詳細情報を表示）
-		shinyText := ""
-		if wildMonster.isShiny {
-			shinyText = "✨色違い✨ "
-		}
-
-		// 星でレア度を表示（色付き）
-		stars := ""
-		for i := 0; i < wildMonster.rarity; i++ {
-			stars += "⭐"
-		}
-
-		p.Text(fmt.Sprintf("%s%s を つかまえた！", shinyText, wildMonster.name), 30, 330)
-		p.Text(fmt.Sprintf("Lv.%d サイズ:%s %s", wildMonster.level, wildMonster.size, stars), 30, 350)
-		if wildMonster.accessory != "none" {
-			p.TextSize(123)
-			p.Text(fmt.Sprintf("アクセサリー: %s", wildMonster.accessory), 30, 350)
-		}
-	} else if captureState == "gameover" {
-		// ゲームオーバー時の表示
-		p.Text(fmt.Sprintf("%s は にげだした！", wildMonster.name), 30, 330)
-		p.TextSize(12)
-		p.Text("つかまえられなかった...", 30, 350)
-		p.Text("クリックで もういちど", 30, 370)
-	}
+	text()
 
 	// 残り試行回数を描画
 	p.Fill(255, 255, 255, 255)
@@ -1634,28 +1967,97 @@ func drawCaptureUI() {
 	p.Text(fmt.Sprintf("のこり: %d/%d", maxAttempts-captureAttempts, maxAttempts), 300, 320)
 }
 
+func drawEncounterText() {
+	shinyMark := ""
+	if wildMonster.isShiny {
+		shinyMark = "✨"
+	}
+	p.Text(fmt.Sprintf("やせいの %s%s があらわれた！", shinyMark, wildMonster.name), 30, 330)
+	p.TextSize(10)
+	p.Text("よわらせると つかまえやすい！", 30, 350)
+	drawBattleMenu()
+}
+
+func drawFailedText() {
+	p.Text(fmt.Sprintf("%s は ボールから でてしまった！", wildMonster.name), 30, 330)
+	p.TextSize(12)
+	p.Text(fmt.Sprintf("のこり %d かい", maxAttempts-captureAttempts), 30, 350)
+	drawBattleMenu()
+}
+
+func drawTurnText() {
+	p.Text(battle.message, 30, 330)
+	p.TextSize(12)
+	p.Text(battle.detail, 30, 350)
+}
+
+func drawFaintedText() {
+	p.Text(battle.message, 30, 330)
+	p.TextSize(12)
+	p.Text("クリックで つぎへ", 30, 370)
+}
+
+func drawThrowingText() {
+	p.Text(fmt.Sprintf("いけっ！ %sボール！", ballNames[pokeball.ballType]), 30, 340)
+}
+
+func drawShakingText() {
+	p.Text(strings.Repeat("・", pokeball.shakeCount), 30, 340)
+}
+
+func drawSuccessText() {
+	// 成功時の表示（捕獲後に詳細情報を表示）
+	shinyText := ""
+	if wildMonster.isShiny {
+		shinyText = "✨色違い✨ "
+	}
+
+	// 星でレア度を表示（色付き）
+	stars := strings.Repeat("⭐", wildMonster.rarity)
+
+	p.Text(fmt.Sprintf("%s%s を つかまえた！", shinyText, wildMonster.name), 30, 330)
+	p.Text(fmt.Sprintf("Lv.%d サイズ:%s %s", wildMonster.level, wildMonster.size, stars), 30, 350)
+	if wildMonster.accessory != "none" {
+		p.TextSize(123)
+		p.Text(fmt.Sprintf("アクセサリー: %s", wildMonster.accessory), 30, 350)
+	}
+	p.TextSize(10)
+	p.Text("クリックで つぎへ", 30, 380)
+}
+
+func drawGameOverText() {
+	// ゲームオーバー時の表示
+	p.Text(fmt.Sprintf("%s は にげだした！", wildMonster.name), 30, 330)
+	p.TextSize(12)
+	p.Text("つかまえられなかった...", 30, 350)
+	p.Text("クリックで もういちど", 30, 370)
+}
+
 func throwPokeball() {
-	if captureState != "encounter" && captureState != "failed" {
+	if !scene.Can(gamestate.Throwing) {
 		return
 	}
 
-	captureState = "throwing"
-	captureAttempts++
-
-	// ランダムにボールタイプを選択（運試し）
-	ballRoll := rand.Float64()
-	if ballRoll < 0.05 {
-		pokeball.ballType = 3 // マスターボール (5%)
-	} else if ballRoll < 0.20 {
-		pokeball.ballType = 2 // ハイパーボール (15%)
-	} else if ballRoll < 0.50 {
-		pokeball.ballType = 1 // スーパーボール (30%)
-	} else {
-		pokeball.ballType = 0 // モンスターボール (50%)
+	// 選んだボールがなければ、持っているボールのうち一番弱いものを使う
+	if inventory[selectedBall] == 0 {
+		ball, ok := firstAvailableBall()
+		if !ok {
+			return
+		}
+		selectedBall = ball
 	}
 
-	// 投げる軌道を計算
+	captureAttempts++
+	inventory[selectedBall]--
+	pokeball.ballType = selectedBall
+	saveGame()
+	goTo(gamestate.Throwing)
+}
+
+// launchPokeball はボールを投げる軌道を計算します
+func launchPokeball() {
 	pokeball.state = "thrown"
+	sound.Play("throw")
 	pokeball.x = 200
 	pokeball.y = 350
 
@@ -1820,3 +2222,1176 @@ func drawWeatherEffects() {
 		p.Rect(0, 0, 400, 400)
 	}
 }
+
+// ─────────────────────────────
+// バトル
+
+// タイプ
+const (
+	typeFire = iota
+	typeWater
+	typeGrass
+	typeElectric
+	typePsychic
+	typeNormal
+)
+
+// Move はモンスターのわざです
+type Move struct {
+	name     string
+	power    int
+	accuracy float64 // 0.0 から 1.0
+	moveType int
+}
+
+// movesByType はタイプごとに覚えるわざです
+var movesByType = [][]Move{
+	typeFire:     {{"ひのこ", 40, 1.0, typeFire}, {"かえんほうしゃ", 90, 0.85, typeFire}, {"たいあたり", 40, 0.95, typeNormal}},
+	typeWater:    {{"みずでっぽう", 40, 1.0, typeWater}, {"ハイドロポンプ", 110, 0.7, typeWater}, {"たいあたり", 40, 0.95, typeNormal}},
+	typeGrass:    {{"はっぱカッター", 55, 0.95, typeGrass}, {"ソーラービーム", 120, 0.6, typeGrass}, {"たいあたり", 40, 0.95, typeNormal}},
+	typeElectric: {{"でんきショック", 40, 1.0, typeElectric}, {"10まんボルト", 90, 0.85, typeElectric}, {"でんこうせっか", 40, 1.0, typeNormal}},
+	typePsychic:  {{"ねんりき", 50, 1.0, typePsychic}, {"サイコキネシス", 90, 0.8, typePsychic}, {"たいあたり", 40, 0.95, typeNormal}},
+}
+
+// typeChart[わざのタイプ][受けるモンスターのタイプ] はダメージの倍率です
+var typeChart = [6][5]float64{
+	//             ほのお みず くさ でんき エスパー
+	typeFire:     {0.5, 0.5, 2, 1, 1},
+	typeWater:    {2, 0.5, 0.5, 1, 1},
+	typeGrass:    {0.5, 2, 0.5, 1, 1},
+	typeElectric: {1, 2, 0.5, 0.5, 1},
+	typePsychic:  {1, 1, 1, 1, 0.5},
+	typeNormal:   {1, 1, 1, 1, 1},
+}
+
+// BattleTurn は進行中の1ターン（こちらの攻撃と相手の反撃）です
+type BattleTurn struct {
+	moveIndex int
+	message   string // テキストボックスの1行目
+	detail    string // テキストボックスの2行目
+}
+
+const (
+	menuX          = 280.0 // メニューの左端
+	menuY          = 345.0 // メニューの「たたかう」の行
+	turnStepFrames = 50    // ターン内で次の攻撃に進むまでのフレーム数
+	hpDrainSpeed   = 1.0   // HPバーが1フレームに減る量
+)
+
+// newPlayerMonster は手持ちのモンスターを作ります
+func newPlayerMonster() Monster {
+	m := newMonster(rand.Intn(len(speciesList)))
+	m.x = 80
+	m.y = 245
+	m.level = 40
+	m.size = "S"
+	m.sizeValue = 0.8
+	return m
+}
+
+// typeEffectiveness はわざのタイプと受けるモンスターのタイプからダメージの倍率を返します
+func typeEffectiveness(moveType, defenderType int) float64 {
+	return typeChart[moveType][defenderType]
+}
+
+// calcDamage はダメージを計算します（roll は 0.0 から 1.0 の乱数で、ダメージを 85%～100% にばらつかせる）
+func calcDamage(attacker, defender Monster, move Move, roll float64) int {
+	base := (2*float64(attacker.level)/5+2)*float64(move.power)/50 + 2
+	// タイプ一致ボーナス
+	if move.moveType == attacker.monsterType {
+		base *= 1.5
+	}
+	base *= typeEffectiveness(move.moveType, defender.monsterType)
+	base *= 0.85 + 0.15*roll
+	return max(1, int(base))
+}
+
+// startTurn はこちらのわざでターンを始めます
+func startTurn(moveIndex int) {
+	battle = BattleTurn{moveIndex: moveIndex}
+	goTo(gamestate.Attacking)
+}
+
+// attack は attacker のわざを defender に当て、テキストとエフェクトを用意します
+func attack(attacker, defender *Monster, move Move) {
+	battle.message = fmt.Sprintf("%s の %s！", attacker.name, move.name)
+	battle.detail = ""
+	if rand.Float64() >= move.accuracy {
+		battle.detail = "しかし こうげきは はずれた！"
+		return
+	}
+
+	damage := calcDamage(*attacker, *defender, move, rand.Float64())
+	defender.hp = max(0, defender.hp-damage)
+	defender.hitTimer = 20
+
+	effectiveness := typeEffectiveness(move.moveType, defender.monsterType)
+	if effectiveness > 1 {
+		battle.detail = "こうかは ばつぐんだ！"
+		textAnimation = TextAnimation{text: "ばつぐん！", x: defender.x - 40, y: defender.y - 50, fadeOut: true, color: color{255, 220, 50}}
+	} else if effectiveness < 1 {
+		battle.detail = "こうかは いまひとつの ようだ"
+	}
+
+	// 当たったところに火花
+	for i := 0; i < 12; i++ {
+		angle := rand.Float64() * math.Pi * 2
+		speed := rand.Float64()*4 + 2
+		c := typeColors[min(move.moveType, len(typeColors)-1)]
+		if move.moveType == typeNormal {
+			c = struct{ r, g, b uint8 }{255, 255, 255}
+		}
+		particles = append(particles, Particle{
+			x:            defender.x,
+			y:            defender.y,
+			vx:           math.Cos(angle) * speed,
+			vy:           math.Sin(angle) * speed,
+			life:         0.6,
+			color:        struct{ r, g, b, a uint8 }{c.r, c.g, c.b, 230},
+			size:         rand.Float64()*4 + 2,
+			particleType: "circle",
+		})
+	}
+}
+
+// updateBattle はHPバーを実際のHPに近づけます
+func updateBattle() {
+	for _, m := range []*Monster{&wildMonster, &playerMonster} {
+		if m.shownHP > float64(m.hp) {
+			m.shownHP = math.Max(float64(m.hp), m.shownHP-hpDrainSpeed)
+		}
+		if m.hitTimer > 0 {
+			m.hitTimer--
+		}
+	}
+}
+
+// updateTurn はターンを進め、こちらの攻撃の後に相手が反撃します
+func updateTurn(frames int) {
+	if frames != turnStepFrames {
+		return
+	}
+	if wildMonster.hp == 0 {
+		battle.message = fmt.Sprintf("やせいの %s は たおれた！", wildMonster.name)
+		goTo(gamestate.Fainted)
+		return
+	}
+	// 相手の反撃
+	moves := wildMonster.moves
+	attack(&wildMonster, &playerMonster, moves[rand.Intn(len(moves))])
+}
+
+// endTurn は反撃の後にターンを終え、次の状態を返します
+func endTurn() gamestate.State {
+	if playerMonster.hp == 0 {
+		battle.message = fmt.Sprintf("%s は たおれてしまった！", playerMonster.name)
+		return gamestate.Fainted
+	}
+	return gamestate.Encounter
+}
+
+// ─────────────────────────────
+// ボール
+
+// ballNames はボールタイプ（capture.Ball の番号）ごとの名前です
+var ballNames = []string{"モンスター", "スーパー", "ハイパー", "マスター"}
+
+const restockBalls = 5 // ボールを使い切ったときに補充するモンスターボールの数
+
+// firstAvailableBall は持っているボールのうち一番弱いものを返します
+func firstAvailableBall() (int, bool) {
+	for ball, count := range inventory {
+		if count > 0 {
+			return ball, true
+		}
+	}
+	return 0, false
+}
+
+// restockInventory はボールを使い切っていたらモンスターボールを補充します
+func restockInventory() {
+	if _, ok := firstAvailableBall(); !ok {
+		inventory[capture.MonsterBall] = restockBalls
+	}
+	if inventory[selectedBall] == 0 {
+		selectedBall, _ = firstAvailableBall()
+	}
+}
+
+// ─────────────────────────────
+// メニュー
+
+const (
+	listTop       = 330.0 // 一覧の1行目のベースライン
+	listRowHeight = 16.0  // 一覧の行の高さ
+)
+
+// listRowAt はわざやボールの一覧で (x, y) にある行を返します（なければ -1）
+func listRowAt(x, y float64) int {
+	if x >= menuX || y < listTop-12 {
+		return -1
+	}
+	return int((y - (listTop - 12)) / listRowHeight)
+}
+
+// menuRowAt は「たたかう」「ボール」「にげる」のメニューで (x, y) にある行を返します（なければ -1）
+func menuRowAt(x, y float64) int {
+	if x < menuX || y < 300 {
+		return -1
+	}
+	if y < menuY+8 {
+		return 0
+	}
+	if y < menuY+28 {
+		return 1
+	}
+	return 2
+}
+
+// isBackButton は (x, y) が「もどる」の上かを返します
+func isBackButton(x, y float64) bool {
+	return x >= menuX && y >= menuY+8
+}
+
+// updateMenuCursor はマウスの位置に合わせてメニューのカーソル（selectedAction）を動かします
+func updateMenuCursor() {
+	mx, my := p.MouseX(), p.MouseY()
+	switch scene.State() {
+	case gamestate.Encounter, gamestate.Failed:
+		selectedAction = menuRowAt(mx, my)
+	case gamestate.Moves, gamestate.Bag:
+		selectedAction = listRowAt(mx, my)
+		if isBackButton(mx, my) {
+			selectedAction = -2 // 「もどる」
+		}
+	default:
+		selectedAction = -1
+	}
+}
+
+// cursor はカーソルが行 row に合っていれば "▶" を返します
+func cursor(row int) string {
+	if selectedAction == row {
+		return "▶ "
+	}
+	return "  "
+}
+
+// drawBattleMenu は「たたかう」「ボール」のメニューを描画します
+func drawBattleMenu() {
+	p.TextSize(12)
+	p.Fill(255, 255, 255, 255)
+	p.Text(cursor(0)+"たたかう", menuX, menuY)
+	p.Text(cursor(1)+"ボール", menuX, menuY+20)
+	p.Text(cursor(2)+"にげる", menuX, menuY+40)
+}
+
+// drawMoveMenu はわざの一覧を描画します
+func drawMoveMenu() {
+	p.TextSize(12)
+	for i, move := range playerMonster.moves {
+		y := listTop + float64(i)*listRowHeight
+		p.Fill(255, 255, 255, 255)
+		p.Text(fmt.Sprintf("%s%s  いりょく%d  めいちゅう%d%%", cursor(i), move.name, move.power, int(move.accuracy*100)), 30, y)
+		// 相手への相性を色で表示
+		switch e := typeEffectiveness(move.moveType, wildMonster.monsterType); {
+		case e > 1:
+			p.Fill(255, 220, 50, 255)
+			p.Text("◎", 250, y)
+		case e < 1:
+			p.Fill(150, 150, 150, 255)
+			p.Text("△", 250, y)
+		}
+	}
+	p.Fill(255, 255, 255, 255)
+	p.Text(cursor(-2)+"もどる", menuX, menuY+20)
+}
+
+// drawBagMenu はボールの一覧を所持数と捕獲率の目安とともに描画します
+func drawBagMenu() {
+	p.TextSize(12)
+	for ball, count := range inventory {
+		y := listTop + float64(ball)*listRowHeight
+		if count > 0 {
+			p.Fill(255, 255, 255, 255)
+		} else {
+			p.Fill(120, 120, 150, 255)
+		}
+		chance := capture.Probability(wildMonster.catchRate, capture.Ball(ball), wildMonster.hp, wildMonster.maxHP, wildMonster.rarity, wildMonster.sizeValue)
+		p.Text(fmt.Sprintf("%s%sボール ×%d", cursor(ball), ballNames[ball], count), 30, y)
+		p.Text(fmt.Sprintf("%d%%", int(chance*100)), 220, y)
+		drawMiniPokeball(20, y-4)
+	}
+	p.Fill(255, 255, 255, 255)
+	p.Text(cursor(-2)+"もどる", menuX, menuY+20)
+}
+
+// ─────────────────────────────
+// ずかん（localStorage に保存）
+
+const (
+	saveKey     = "retro_game_save"
+	saveVersion = 2
+)
+
+// maxLevel はセーブデータに残せるレベルの上限です
+const maxLevel = 100
+
+// v1SpeciesIDs はバージョン1のセーブデータの種族番号と種族 ID の対応です
+var v1SpeciesIDs = []string{"hitokage", "zenigame", "fushigidane", "pikachu", "mewtwo"}
+
+// CaughtMonster は捕まえたモンスターの記録です
+type CaughtMonster struct {
+	Species       string  `json:"speciesId"`         // species.json の id
+	LegacySpecies int     `json:"species,omitempty"` // バージョン1の種族番号（読み込み時だけ使う）
+	Name          string  `json:"name"`
+	Level         int     `json:"level"`
+	Size          string  `json:"size"`
+	SizeValue     float64 `json:"sizeValue"`
+	Rarity        int     `json:"rarity"`
+	Shiny         bool    `json:"shiny"`
+	Accessory     string  `json:"accessory"`
+}
+
+// SaveData は localStorage に保存する内容です
+// 形式を変えるときは saveVersion を上げ、migrateSave で古い形式から変換します
+type SaveData struct {
+	Version     int                `json:"version"`
+	Caught      []CaughtMonster    `json:"caught"`
+	Inventory   [capture.Balls]int `json:"inventory"`
+	TradedCodes []string           `json:"tradedCodes,omitempty"` // おくったり うけとったりした こうかんコード
+}
+
+var (
+	dex         []CaughtMonster // 捕まえたモンスター（古い順）
+	tradedCodes []string        // 一度使ったこうかんコード（同じコードで何匹も受け取れないように）
+)
+
+// localStorage は使えれば window.localStorage を返します
+func localStorage() (js.Value, bool) {
+	storage := js.Global().Get("localStorage")
+	return storage, storage.Truthy()
+}
+
+// loadGame は保存されたずかんとボールを読み込みます
+func loadGame() {
+	// プライベートブラウズなどで localStorage が使えないときは例外になるので、保存なしで続ける
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("セーブデータを読み込めませんでした:", r)
+		}
+	}()
+	storage, ok := localStorage()
+	if !ok {
+		return
+	}
+	item := storage.Call("getItem", saveKey)
+	if item.IsNull() {
+		return
+	}
+	data, err := decodeSave([]byte(item.String()))
+	if err != nil {
+		fmt.Println("セーブデータを読み込めませんでした:", err)
+		return
+	}
+	dex = data.Caught
+	inventory = data.Inventory
+	tradedCodes = data.TradedCodes
+}
+
+// saveGame はずかんとボールを保存します
+func saveGame() {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("セーブできませんでした:", r)
+		}
+	}()
+	storage, ok := localStorage()
+	if !ok {
+		return
+	}
+	b, err := json.Marshal(SaveData{Version: saveVersion, Caught: dex, Inventory: inventory, TradedCodes: tradedCodes})
+	if err != nil {
+		fmt.Println("セーブできませんでした:", err)
+		return
+	}
+	storage.Call("setItem", saveKey, string(b))
+}
+
+// decodeSave はセーブデータを読み取り、古い形式なら今の形式に変換します
+func decodeSave(b []byte) (SaveData, error) {
+	var data SaveData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return SaveData{}, err
+	}
+	if data.Version > saveVersion {
+		return SaveData{}, fmt.Errorf("新しいバージョン(%d)のセーブデータです", data.Version)
+	}
+	return migrateSave(data), nil
+}
+
+// migrateSave は古い形式のセーブデータを今の形式に変換します
+func migrateSave(data SaveData) SaveData {
+	if data.Version < 2 {
+		// バージョン1は種族を番号で持っていたので ID に置き換える
+		for i, m := range data.Caught {
+			if m.LegacySpecies >= 0 && m.LegacySpecies < len(v1SpeciesIDs) {
+				data.Caught[i].Species = v1SpeciesIDs[m.LegacySpecies]
+			}
+			data.Caught[i].LegacySpecies = 0
+		}
+	}
+	// 壊れたり手で書き換えられたりした記録で描画が止まらないように、
+	// 知らない種族・サイズ・アクセサリーの記録は捨て、数値は範囲に収める
+	caught := data.Caught[:0]
+	for _, m := range data.Caught {
+		species, ok := speciesIndex(m.Species)
+		if !ok || !slices.Contains(sizeNames, m.Size) || !slices.Contains(accessoryNames, m.Accessory) {
+			fmt.Printf("セーブデータの %q の記録を読み飛ばしました\n", m.Name)
+			continue
+		}
+		if m.Name == "" {
+			m.Name = speciesList[species].Name
+		}
+		m.Rarity = min(max(m.Rarity, 1), 5)
+		m.Level = min(max(m.Level, 1), maxLevel)
+		m.SizeValue = math.Min(math.Max(m.SizeValue, 0.5), 1.5)
+		caught = append(caught, m)
+	}
+	data.Caught = caught
+	for ball, count := range data.Inventory {
+		data.Inventory[ball] = max(count, 0)
+	}
+	data.Version = saveVersion
+	return data
+}
+
+// recordCapture は捕まえたモンスターをずかんに登録して保存します
+func recordCapture(m Monster) {
+	dex = append(dex, CaughtMonster{
+		Species:   speciesList[m.species].ID,
+		Name:      m.name,
+		Level:     m.level,
+		Size:      m.size,
+		SizeValue: m.sizeValue,
+		Rarity:    m.rarity,
+		Shiny:     m.isShiny,
+		Accessory: m.accessory,
+	})
+	saveGame()
+}
+
+// Completion はずかんの達成率です
+type Completion struct {
+	byType   []float64  // 種類ごとに、5段階のレア度のうち捕まえた割合
+	byRarity [5]float64 // レア度ごとに、全種類のうち捕まえた割合
+	total    float64    // 種類×レア度の組み合わせのうち捕まえた割合
+	caught   [][5]bool  // [種類][レア度-1] を捕まえたか
+}
+
+// dexCompletion は捕まえたモンスターの一覧からずかんの達成率を求めます
+// species.json にない種族の記録は数えません
+func dexCompletion(caught []CaughtMonster, species []Species) Completion {
+	speciesCount := len(species)
+	c := Completion{
+		byType: make([]float64, speciesCount),
+		caught: make([][5]bool, speciesCount),
+	}
+	index := make(map[string]int, speciesCount)
+	for i, sp := range species {
+		index[sp.ID] = i
+	}
+	for _, m := range caught {
+		if i, ok := index[m.Species]; ok && m.Rarity >= 1 && m.Rarity <= 5 {
+			c.caught[i][m.Rarity-1] = true
+		}
+	}
+	count := 0
+	for species := range c.caught {
+		for rarity, ok := range c.caught[species] {
+			if ok {
+				c.byType[species] += 1.0 / 5
+				c.byRarity[rarity] += 1.0 / float64(speciesCount)
+				count++
+			}
+		}
+	}
+	c.total = float64(count) / float64(speciesCount*5)
+	return c
+}
+
+// isCollectionButton は (x, y) が「ずかん」ボタンの上かを返します
+func isCollectionButton(x, y float64) bool {
+	return x >= 330 && x <= 390 && y >= 10 && y <= 30
+}
+
+// drawCollectionButton は右上に「ずかん」ボタンを描画します
+func drawCollectionButton() {
+	p.NoStroke()
+	p.Fill(255, 255, 255, 255)
+	p.Rect(330, 10, 60, 20)
+	p.Fill(0, 0, 100, 255)
+	p.Rect(332, 12, 56, 16)
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(11)
+	p.Text("ずかん", 343, 24)
+}
+
+// clickCollection はずかん画面のクリックを処理します
+// 行をクリックするとそのモンスターをこうかんコードにしておくり、何もないところをクリックすると
+// 一覧からはまとめの画面に、まとめの画面からは開く前の画面に戻ります
+func clickCollection(x, y float64) {
+	switch {
+	case isImportButton(x, y):
+		promptImport()
+	case !collectionListing && isDexListButton(x, y):
+		collectionListing, dexPage = true, 0
+	case collectionListing && isDexPageButton(x, y, -1):
+		dexPage = max(dexPage-1, 0)
+	case collectionListing && isDexPageButton(x, y, 1):
+		dexPage = min(dexPage+1, dexPages()-1)
+	default:
+		if i := dexRowAt(x, y); i >= 0 {
+			promptExport(i)
+		} else if collectionListing {
+			collectionListing = false
+		} else {
+			goTo(scene.Previous())
+		}
+	}
+}
+
+// drawCollection はずかん画面を描画します
+func drawCollection() {
+	p.Background(20, 20, 50)
+	p.NoStroke()
+	if collectionListing {
+		drawDexList()
+	} else {
+		drawCollectionSummary()
+	}
+
+	drawImportButton()
+	if tradeMessageTimer > 0 {
+		tradeMessageTimer--
+		p.Fill(255, 215, 0, 255)
+		p.TextSize(11)
+		p.Text(tradeMessage, 20, 390)
+	}
+
+	p.Fill(180, 180, 220, 255)
+	p.TextSize(10)
+	p.Text("クリックで もどる", 300, 390)
+}
+
+// drawCollectionSummary はずかんの達成率と、さいきん捕まえたモンスターを描画します
+func drawCollectionSummary() {
+	completion := dexCompletion(dex, speciesList)
+
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(18)
+	p.Text("ずかん", 20, 35)
+	p.TextSize(12)
+	p.Text(fmt.Sprintf("たっせいりつ %d%%  つかまえた かず %d", int(completion.total*100), len(dex)), 120, 33)
+
+	// 種類ごと: 捕まえたレア度を星で、達成率をバーで表示
+	for species, sp := range speciesList {
+		y := 62 + float64(species)*26
+		c := sp.Color
+		p.Fill(c[0], c[1], c[2], 255)
+		p.Rect(20, y-12, 12, 12)
+		p.Fill(255, 255, 255, 255)
+		p.TextSize(12)
+		p.Text(sp.Name, 40, y)
+		for rarity := 0; rarity < 5; rarity++ {
+			if completion.caught[species][rarity] {
+				p.Fill(255, 215, 0, 255)
+			} else {
+				p.Fill(80, 80, 110, 255)
+			}
+			drawStar(150+float64(rarity)*18, y-5, 6)
+		}
+		p.Fill(60, 60, 90, 255)
+		p.Rect(250, y-10, 100, 8)
+		p.Fill(100, 255, 100, 255)
+		p.Rect(250, y-10, 100*completion.byType[species], 8)
+		p.Fill(255, 255, 255, 255)
+		p.TextSize(10)
+		p.Text(fmt.Sprintf("%d%%", int(completion.byType[species]*100)), 355, y-2)
+	}
+
+	// レア度ごとの達成率
+	p.TextSize(10)
+	for rarity := 0; rarity < 5; rarity++ {
+		x := 20 + float64(rarity)*75
+		p.Fill(255, 215, 0, 255)
+		p.Text(strings.Repeat("★", rarity+1), x, 285)
+		p.Fill(255, 255, 255, 255)
+		p.Text(fmt.Sprintf("%d%%", int(completion.byRarity[rarity]*100)), x, 300)
+	}
+
+	// さいきん捕まえたモンスター（クリックでこうかんコードを出す）
+	p.Text("さいきん つかまえた モンスター（クリックで こうかんコード）", 20, 325)
+	for i := 0; i < recentRows && i < len(dex); i++ {
+		drawDexRow(dex[len(dex)-1-i], 30, recentTop+float64(i)*dexRowHeight)
+	}
+	drawButton(dexListButtonX, dexButtonY, 100, 18, "ぜんぶ みる")
+}
+
+// drawDexList は捕まえたモンスターをすべて、新しい順にページに分けて描画します
+func drawDexList() {
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(18)
+	p.Text("つかまえた モンスター", 20, 35)
+	p.TextSize(12)
+	p.Text(fmt.Sprintf("%d ひき（クリックで こうかんコード）", len(dex)), 220, 33)
+
+	p.TextSize(10)
+	first := dexPage * dexListRows
+	for i := first; i < first+dexListRows && i < len(dex); i++ {
+		p.Fill(255, 255, 255, 255)
+		p.Text(fmt.Sprintf("%3d", len(dex)-i), 20, dexListTop+float64(i-first)*dexRowHeight)
+		drawDexRow(dex[len(dex)-1-i], 50, dexListTop+float64(i-first)*dexRowHeight)
+	}
+	if len(dex) == 0 {
+		p.Fill(180, 180, 220, 255)
+		p.Text("まだ なにも つかまえて いません", 30, dexListTop)
+	}
+
+	drawButton(dexPageButtonX, dexButtonY, dexPageButtonW, 18, "◀")
+	drawButton(dexPageButtonX+dexPageButtonW+10, dexButtonY, dexPageButtonW, 18, "▶")
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(10)
+	p.Text(fmt.Sprintf("%d / %d", dexPage+1, dexPages()), dexPageButtonX+2*dexPageButtonW+20, dexButtonY+13)
+}
+
+// drawDexRow はずかんの記録を1行で描画します
+func drawDexRow(m CaughtMonster, x, y float64) {
+	shiny := ""
+	if m.Shiny {
+		shiny = "✨"
+	}
+	accessory := ""
+	if m.Accessory != "none" {
+		accessory = " " + m.Accessory
+	}
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(10)
+	p.Text(fmt.Sprintf("%s%s Lv.%d %s %s%s", shiny, m.Name, m.Level, m.Size, strings.Repeat("★", m.Rarity), accessory), x, y)
+}
+
+// drawButton は白い枠のボタンを描画します
+func drawButton(x, y, w, h float64, label string) {
+	p.Fill(255, 255, 255, 255)
+	p.Rect(x, y, w, h)
+	p.Fill(0, 0, 100, 255)
+	p.Rect(x+2, y+2, w-4, h-4)
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(10)
+	p.Text(label, x+10, y+13)
+}
+
+// dexPages は一覧のページ数です（空でも1ページ）
+func dexPages() int {
+	return max((len(dex)+dexListRows-1)/dexListRows, 1)
+}
+
+// isDexListButton は (x, y) が「ぜんぶ みる」ボタンの上かを返します
+func isDexListButton(x, y float64) bool {
+	return x >= dexListButtonX && x <= dexListButtonX+100 && y >= dexButtonY && y <= dexButtonY+18
+}
+
+// isDexPageButton は (x, y) が一覧のページ送りのボタン（dir が -1 なら前、1 なら次）の上かを返します
+func isDexPageButton(x, y float64, dir int) bool {
+	left := dexPageButtonX
+	if dir > 0 {
+		left += dexPageButtonW + 10
+	}
+	return x >= left && x <= left+dexPageButtonW && y >= dexButtonY && y <= dexButtonY+18
+}
+
+// ─────────────────────────────
+// こうかんコード
+
+const (
+	recentTop       = 342.0 // さいきん捕まえたモンスターの1行目のベースライン
+	recentRows      = 3     // さいきん捕まえたモンスターの行数
+	dexListTop      = 62.0  // 一覧の1行目のベースライン
+	dexListRows     = 18    // 一覧の1ページの行数
+	dexRowHeight    = 15.0  // ずかんの1行の高さ
+	dexListButtonX  = 290.0 // 「ぜんぶ みる」ボタンの左端
+	dexButtonY      = 356.0 // 「ぜんぶ みる」とページ送りのボタンの上端
+	dexPageButtonX  = 20.0  // ページ送りのボタンの左端
+	dexPageButtonW  = 30.0  // ページ送りのボタンの幅
+	tradeMessageFor = 180   // こうかんのメッセージを出しておくフレーム数
+)
+
+var (
+	tradeMessage      string
+	tradeMessageTimer int
+	collectionListing bool // ずかんで捕まえたモンスターの一覧を見ているか
+	dexPage           int  // 一覧のページ（0 から）
+)
+
+// tradeCode は捕まえたモンスターのこうかんコードを作ります
+func tradeCode(m CaughtMonster) (string, error) {
+	species, ok := speciesIndex(m.Species)
+	if !ok {
+		return "", fmt.Errorf("種族 %q は こうかんできません", m.Species)
+	}
+	return trade.Encode(trade.Monster{
+		Species:   species,
+		Level:     m.Level,
+		Rarity:    m.Rarity,
+		Size:      slices.Index(sizeNames, m.Size),
+		SizeValue: m.SizeValue,
+		Shiny:     m.Shiny,
+		Accessory: slices.Index(accessoryNames, m.Accessory),
+	})
+}
+
+// fromTradeCode はこうかんコードを読み取り、ずかんの記録にします
+func fromTradeCode(code string) (CaughtMonster, error) {
+	t, err := trade.Decode(code)
+	if err != nil {
+		return CaughtMonster{}, err
+	}
+	if t.Species >= len(speciesList) || t.Size >= len(sizeNames) || t.Accessory >= len(accessoryNames) {
+		return CaughtMonster{}, fmt.Errorf("このゲームに いない モンスターです")
+	}
+	sp := speciesList[t.Species]
+	return CaughtMonster{
+		Species:   sp.ID,
+		Name:      sp.Name,
+		Level:     t.Level,
+		Size:      sizeNames[t.Size],
+		SizeValue: t.SizeValue,
+		Rarity:    t.Rarity,
+		Shiny:     t.Shiny,
+		Accessory: accessoryNames[t.Accessory],
+	}, nil
+}
+
+// errAlreadyTraded は、一度おくったり うけとったりしたコードを うけとろうとしたときのエラーです
+var errAlreadyTraded = errors.New("この コードは もう つかわれています")
+
+// sendMonster は dex の i 番目のモンスターをずかんから外し、こうかんコードを返します
+// おくったモンスターのコードは使ったことにするので、自分で受け取り直して増やすこともできません
+func sendMonster(i int) (string, error) {
+	code, err := tradeCode(dex[i])
+	if err != nil {
+		return "", err
+	}
+	dex = slices.Delete(dex, i, i+1)
+	tradedCodes = append(tradedCodes, code)
+	return code, nil
+}
+
+// receiveMonster はこうかんコードのモンスターをずかんに加えます
+// 同じコードを何度も入れて増やせないように、一度使ったコードは受け取りません
+// （まったく同じモンスターは同じコードになるので、2匹目は受け取れません）
+func receiveMonster(input string) (CaughtMonster, error) {
+	m, err := fromTradeCode(input)
+	if err != nil {
+		return CaughtMonster{}, err
+	}
+	// 小文字やハイフンのちがいで別のコードにならないよう、作り直したコードで覚える
+	code, err := tradeCode(m)
+	if err != nil {
+		return CaughtMonster{}, err
+	}
+	if slices.Contains(tradedCodes, code) {
+		return CaughtMonster{}, errAlreadyTraded
+	}
+	dex = append(dex, m)
+	tradedCodes = append(tradedCodes, code)
+	return m, nil
+}
+
+// promptExport は dex の i 番目のモンスターのこうかんコードをコピーできるように表示します
+// OK を押すとモンスターをおくったことにしてずかんから外し、キャンセルならそのまま残します
+func promptExport(i int) {
+	m := dex[i]
+	code, err := tradeCode(m)
+	if err != nil {
+		showTradeMessage(err.Error())
+		return
+	}
+	answer := js.Global().Call("prompt", fmt.Sprintf("%s の こうかんコード（コピーして OK を おすと おくります）", m.Name), code)
+	if answer.IsNull() {
+		return
+	}
+	if _, err := sendMonster(i); err != nil {
+		showTradeMessage(err.Error())
+		return
+	}
+	dexPage = min(dexPage, dexPages()-1)
+	saveGame()
+	showTradeMessage(fmt.Sprintf("%s を おくった！", m.Name))
+}
+
+// promptImport はこうかんコードを入力してもらい、ずかんに登録します
+func promptImport() {
+	input := js.Global().Call("prompt", "こうかんコードを いれてね")
+	if input.IsNull() || strings.TrimSpace(input.String()) == "" {
+		return
+	}
+	m, err := receiveMonster(input.String())
+	switch {
+	case errors.Is(err, trade.ErrVersion):
+		showTradeMessage("あたらしい バージョンの コードです")
+	case errors.Is(err, errAlreadyTraded):
+		showTradeMessage(err.Error())
+	case err != nil:
+		showTradeMessage("コードが ただしく ありません")
+	default:
+		saveGame()
+		showTradeMessage(fmt.Sprintf("%s が とどいた！", m.Name))
+	}
+}
+
+func showTradeMessage(message string) {
+	tradeMessage = message
+	tradeMessageTimer = tradeMessageFor
+}
+
+// dexRowAt は (x, y) にあるずかんの行のモンスターの dex での位置を返します（なければ -1）
+// まとめの画面では「さいきん つかまえた モンスター」の行、一覧では表示中のページの行を見ます
+func dexRowAt(x, y float64) int {
+	top, rows, first, right := recentTop, recentRows, 0, 280.0
+	if collectionListing {
+		top, rows, first, right = dexListTop, dexListRows, dexPage*dexListRows, 380
+	}
+	if x < 20 || x > right || y < top-11 {
+		return -1
+	}
+	row := int((y - (top - 11)) / dexRowHeight)
+	if row >= rows || first+row >= len(dex) {
+		return -1
+	}
+	return len(dex) - 1 - (first + row)
+}
+
+// isImportButton は (x, y) が「コードで うけとる」ボタンの上かを返します
+func isImportButton(x, y float64) bool {
+	return x >= 290 && x <= 390 && y >= 334 && y <= 352
+}
+
+// drawImportButton は「コードで うけとる」ボタンを描画します
+func drawImportButton() {
+	drawButton(290, 334, 100, 18, "コードで うけとる")
+}
+
+// ─────────────────────────────
+// フィールド
+
+const (
+	tileSize        = 16.0 // フィールドの1マスのピクセル数
+	tileTexels      = 4    // 1マスに並べる背景ピクセルの数（縦横）
+	walkFrames      = 8    // 1マス歩くのにかかるフレーム数
+	encounterChance = 0.12 // 草むらに入ったときに出会う確率
+)
+
+//go:embed maps
+var mapFiles embed.FS
+
+// biomeLabels は背景タイプの名前です
+var biomeLabels = []string{"そうげん", "もり", "やま", "うみべ", "どうくつ", "かざん", "せつげん", "さばく"}
+
+// groundTop は背景タイプごとに、バトル背景で地面が始まる行です（フィールドの地面の模様に使う）
+var groundTop = []int{50, 40, 60, 70, 0, 60, 40, 40}
+
+// biomeTextures はバイオームごとに、バトル背景の生成関数で作った地面の色番号です
+var biomeTextures [encounter.Biomes][][]int
+
+// directionKeys は歩く向きのキーです（やじるしキーと WASD）
+var directionKeys = []struct {
+	key string
+	dir overworld.Point
+}{
+	{"ArrowUp", overworld.Point{X: 0, Y: -1}},
+	{"ArrowDown", overworld.Point{X: 0, Y: 1}},
+	{"ArrowLeft", overworld.Point{X: -1, Y: 0}},
+	{"ArrowRight", overworld.Point{X: 1, Y: 0}},
+	{"w", overworld.Point{X: 0, Y: -1}},
+	{"s", overworld.Point{X: 0, Y: 1}},
+	{"a", overworld.Point{X: -1, Y: 0}},
+	{"d", overworld.Point{X: 1, Y: 0}},
+}
+
+// Walker はフィールドを歩くプレイヤーです
+type Walker struct {
+	tile   overworld.Point // 今いるタイル（歩いている途中なら向かっているタイル）
+	from   overworld.Point // 歩き始めたタイル
+	facing overworld.Point // 向き
+	step   int             // 歩いている途中のフレーム数（0 なら止まっている）
+}
+
+// position はプレイヤーの中心のピクセル座標を返します
+func (w *Walker) position() (float64, float64) {
+	t := float64(w.step) / walkFrames
+	x := float64(w.from.X) + float64(w.tile.X-w.from.X)*t
+	y := float64(w.from.Y) + float64(w.tile.Y-w.from.Y)*t
+	return (x + 0.5) * tileSize, (y + 0.5) * tileSize
+}
+
+// walk は dir の向きを向き、歩けるならそのタイルへ歩き始めます
+func (w *Walker) walk(dir overworld.Point) {
+	w.facing = dir
+	next := overworld.Point{X: w.tile.X + dir.X, Y: w.tile.Y + dir.Y}
+	if !world.Walkable(next.X, next.Y) {
+		return
+	}
+	w.from, w.tile = w.tile, next
+	w.step = 1
+}
+
+// loadWorld は ?map=名前 なら maps/ の名前.txt か名前.json を読み込み、
+// なければ ?seed= のシード（省略するとランダム）からマップを生成します
+func loadWorld(query url.Values) *overworld.Map {
+	if name := query.Get("map"); name != "" {
+		m, err := loadMapFile(name)
+		if err == nil {
+			return m
+		}
+		fmt.Println("マップを読み込めませんでした:", err)
+	}
+	seed := rand.Int63()
+	if s, err := strconv.ParseInt(query.Get("seed"), 10, 64); err == nil {
+		seed = s
+	}
+	return overworld.Generate(seed, overworld.DefaultGenerateConfig())
+}
+
+// loadMapFile は埋め込んだ maps/ からマップを読み込みます
+func loadMapFile(name string) (*overworld.Map, error) {
+	if b, err := mapFiles.ReadFile("maps/" + name + ".txt"); err == nil {
+		return overworld.ParseText(string(b))
+	}
+	if b, err := mapFiles.ReadFile("maps/" + name + ".json"); err == nil {
+		return overworld.ParseJSON(b)
+	}
+	return nil, fmt.Errorf("maps/%s.txt も maps/%s.json もありません", name, name)
+}
+
+// listenKeys は押されているキーを heldKeys に記録します
+func listenKeys() {
+	document := js.Global().Get("document")
+	document.Call("addEventListener", "keydown", js.FuncOf(func(this js.Value, args []js.Value) any {
+		key := args[0].Get("key").String()
+		if scene != nil && scene.State() == gamestate.Overworld && strings.HasPrefix(key, "Arrow") {
+			args[0].Call("preventDefault") // ページがスクロールしないように
+		}
+		heldKeys[key] = true
+		sound.Resume()
+		return nil
+	}))
+	document.Call("addEventListener", "keyup", js.FuncOf(func(this js.Value, args []js.Value) any {
+		delete(heldKeys, args[0].Get("key").String())
+		return nil
+	}))
+	// ほかのウィンドウに切り替えると keyup が来ないので、押しっぱなしにならないようにする
+	js.Global().Call("addEventListener", "blur", js.FuncOf(func(this js.Value, args []js.Value) any {
+		clear(heldKeys)
+		return nil
+	}))
+}
+
+// buildBiomeTextures はバトル背景の生成関数でバイオームごとの地面の模様を作ります
+func buildBiomeTextures() {
+	savedPixels, savedGrass := backgroundPixels, grassPixels
+	for biome := range biomeTextures {
+		generateBackground(biome)
+		biomeTextures[biome] = backgroundPixels[groundTop[biome]:]
+	}
+	backgroundPixels, grassPixels = savedPixels, savedGrass
+}
+
+// updateOverworld はキー入力でプレイヤーを歩かせ、草むらに入ったら野生のモンスターに出会います
+func updateOverworld() {
+	if player.step > 0 {
+		player.step++
+		if player.step < walkFrames {
+			return
+		}
+		player.step = 0
+		player.from = player.tile
+		tile := world.At(player.tile.X, player.tile.Y)
+		if tile.Kind == overworld.TallGrass && rand.Float64() < encounterChance {
+			startEncounter(tile.Biome)
+			return
+		}
+	}
+	for _, k := range directionKeys {
+		if heldKeys[k.key] {
+			player.walk(k.dir)
+			return
+		}
+	}
+}
+
+// drawOverworld はカメラに映るタイルとプレイヤーを描画します
+func drawOverworld() {
+	if biomeTextures[0] == nil {
+		buildBiomeTextures()
+	}
+	p.Background(0, 0, 0)
+	p.NoStroke()
+
+	px, py := player.position()
+	viewW, viewH := float64(p.Width()), float64(p.Height())
+	camX, camY := overworld.Camera(px, py, viewW, viewH, float64(world.Width)*tileSize, float64(world.Height)*tileSize)
+	left, top := int(math.Floor(camX/tileSize)), int(math.Floor(camY/tileSize))
+	for ty := top; ty <= top+int(viewH/tileSize)+1; ty++ {
+		for tx := left; tx <= left+int(viewW/tileSize)+1; tx++ {
+			if world.In(tx, ty) {
+				drawTile(world.At(tx, ty), tx, ty, float64(tx)*tileSize-camX, float64(ty)*tileSize-camY)
+			}
+		}
+	}
+	drawWalker(px-camX, py-camY)
+
+	// 今いるバイオームと操作の説明
+	tile := world.At(player.tile.X, player.tile.Y)
+	p.Fill(0, 0, 0, 150)
+	p.Rect(10, 10, 110, 20)
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(11)
+	p.Text(biomeLabels[tile.Biome], 18, 24)
+	p.Fill(0, 0, 0, 150)
+	p.Rect(10, viewH-28, 220, 18)
+	p.Fill(255, 255, 255, 255)
+	p.TextSize(10)
+	p.Text("やじるしキー / WASD で あるく", 18, viewH-15)
+	drawCollectionButton()
+}
+
+// drawTile は (sx, sy) にフィールドの1マスを描画します
+func drawTile(tile overworld.Tile, tx, ty int, sx, sy float64) {
+	// 地面はバトル背景の地面の模様を並べる
+	texture := biomeTextures[tile.Biome]
+	ps := tileSize / tileTexels
+	for i := 0; i < tileTexels; i++ {
+		for j := 0; j < tileTexels; j++ {
+			row := texture[(ty*tileTexels+i)%len(texture)]
+			fillBackground(row[(tx*tileTexels+j)%len(row)])
+			p.Rect(sx+float64(j)*ps, sy+float64(i)*ps, ps, ps)
+		}
+	}
+
+	switch tile.Kind {
+	case overworld.TallGrass:
+		// 草むら（バイオームに合った色の葉を3本ずつ）
+		grassColors := [][3]uint8{{60, 140, 50}, {30, 90, 30}, {110, 130, 90}, {90, 170, 90}, {90, 110, 100}, {120, 70, 40}, {180, 210, 230}, {170, 160, 70}}
+		c := grassColors[tile.Biome]
+		p.Fill(c[0], c[1], c[2], 255)
+		sway := math.Sin(float64(frameCount)*0.1+float64(tx+ty)) * 1
+		for _, blade := range [][2]float64{{2, 6}, {7, 2}, {11, 7}} {
+			p.Rect(sx+blade[0]+sway, sy+blade[1], 2, 8)
+			p.Rect(sx+blade[0]+2+sway, sy+blade[1]+3, 2, 5)
+		}
+	case overworld.Block:
+		drawBlock(tile.Biome, sx, sy)
+	}
+}
+
+// drawBlock は通れないマスをバイオームに合わせて描画します
+func drawBlock(biome encounter.Biome, sx, sy float64) {
+	switch biome {
+	case encounter.Grassland, encounter.Forest:
+		// 木
+		p.Fill(100, 70, 40, 255)
+		p.Rect(sx+6, sy+10, 4, 6)
+		if biome == encounter.Forest {
+			p.Fill(30, 80, 40, 255)
+		} else {
+			p.Fill(50, 140, 60, 255)
+		}
+		p.Ellipse(sx+8, sy+7, 14, 12)
+	case encounter.Beach:
+		// 海
+		p.Fill(50, 150, 220, 255)
+		p.Rect(sx, sy, tileSize, tileSize)
+		p.Fill(100, 200, 255, 255)
+		wave := math.Sin(float64(frameCount)*0.05+sx*0.1) * 2
+		p.Rect(sx+3+wave, sy+5, 6, 2)
+		p.Rect(sx+8-wave, sy+11, 5, 2)
+	case encounter.Volcano:
+		// 溶岩
+		p.Fill(60, 40, 30, 255)
+		p.Rect(sx, sy, tileSize, tileSize)
+		if frameCount%30 < 15 {
+			p.Fill(255, 100, 0, 255)
+		} else {
+			p.Fill(255, 160, 0, 255)
+		}
+		p.Rect(sx+3, sy+3, 10, 10)
+	case encounter.Snowfield:
+		// 氷のかたまり
+		p.Fill(200, 220, 255, 255)
+		p.Rect(sx+2, sy+3, 12, 12)
+		p.Fill(240, 250, 255, 255)
+		p.Rect(sx+4, sy+5, 4, 3)
+	case encounter.Desert:
+		// サボテン
+		p.Fill(50, 150, 50, 255)
+		p.Rect(sx+6, sy+2, 4, 14)
+		p.Rect(sx+2, sy+6, 4, 3)
+		p.Rect(sx+10, sy+4, 4, 3)
+	default:
+		// 岩（山・洞窟）
+		p.Fill(80, 80, 95, 255)
+		p.Rect(sx+1, sy+4, 14, 12)
+		p.Fill(120, 120, 140, 255)
+		p.Rect(sx+3, sy+2, 10, 10)
+	}
+}
+
+// drawWalker はプレイヤーを (x, y) を中心に描画します
+func drawWalker(x, y float64) {
+	// 1=帽子, 2=顔, 3=目, 4=服, 5=足
+	walkerPixels := [][]int{
+		{0, 0, 1, 1, 1, 1, 0, 0},
+		{0, 1, 1, 1, 1, 1, 1, 0},
+		{0, 2, 2, 2, 2, 2, 2, 0},
+		{0, 2, 3, 2, 2, 3, 2, 0},
+		{0, 0, 2, 2, 2, 2, 0, 0},
+		{0, 4, 4, 4, 4, 4, 4, 0},
+		{0, 4, 4, 4, 4, 4, 4, 0},
+		{0, 5, 5, 0, 0, 5, 5, 0},
+	}
+	ps := tileSize / 8
+	stepping := player.step > 0 && player.step < walkFrames/2
+	for row, pixels := range walkerPixels {
+		for col, pixel := range pixels {
+			// 向きに合わせて目を動かす（うしろ向きなら見えない）
+			if row == 3 && player.facing.Y < 0 {
+				pixel = 1
+			} else if row == 3 && player.facing.X != 0 {
+				pixel = 2
+				if (player.facing.X > 0 && col == 5) || (player.facing.X < 0 && col == 2) {
+					pixel = 3
+				}
+			}
+			// 歩いている途中は片足を上げる
+			if row == 7 && stepping && col >= 5 {
+				continue
+			}
+			switch pixel {
+			case 0:
+				continue
+			case 1:
+				p.Fill(220, 40, 40, 255)
+			case 2:
+				p.Fill(255, 210, 170, 255)
+			case 3:
+				p.Fill(0, 0, 0, 255)
+			case 4:
+				p.Fill(50, 90, 200, 255)
+			case 5:
+				p.Fill(40, 40, 60, 255)
+			}
+			p.Rect(x-tileSize/2+float64(col)*ps, y-tileSize/2+float64(row)*ps-2, ps, ps)
+		}
+	}
+}
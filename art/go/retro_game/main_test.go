@@ -0,0 +1,122 @@
+// retro_game は js/wasm でしか組み立てられないので、テストは node で動かします。
+//
+//	GOOS=js GOARCH=wasm go test -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" ./retro_game/
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tradedSpecies は、こうかんコードが出回っている種族の並びです。
+// コードには species.json の並び順の番号が入るので、ここにある種族の順番は変えられません。
+var tradedSpecies = []string{"hitokage", "zenigame", "fushigidane", "pikachu", "mewtwo"}
+
+func TestSpeciesAppendOnly(t *testing.T) {
+	if len(speciesList) > 256 {
+		t.Errorf("種族が %d あります。こうかんコードに入るのは 256 までです", len(speciesList))
+	}
+	if len(speciesList) < len(tradedSpecies) {
+		t.Fatalf("species.json の種族が %d しかありません（消さないでください）", len(speciesList))
+	}
+	for i, id := range tradedSpecies {
+		if speciesList[i].ID != id {
+			t.Errorf("species.json の %d 番目が %q です。%q のままにして、新しい種族は最後に足してください", i, speciesList[i].ID, id)
+		}
+		if got, ok := speciesIndex(id); !ok || got != i {
+			t.Errorf("speciesIndex(%q) = %d, %v, want %d", id, got, ok, i)
+		}
+	}
+}
+
+// resetTrade はずかんと使ったコードを空にします。
+func resetTrade(t *testing.T) {
+	dex, tradedCodes = nil, nil
+	t.Cleanup(func() { dex, tradedCodes = nil, nil })
+}
+
+func TestReceiveOnce(t *testing.T) {
+	resetTrade(t)
+	pikachu := CaughtMonster{Species: "pikachu", Name: "ピカチュウ", Level: 12, Size: sizeNames[2], SizeValue: 0.5, Rarity: 5, Shiny: true, Accessory: accessoryNames[0]}
+	code, err := tradeCode(pikachu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := receiveMonster(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != pikachu || len(dex) != 1 {
+		t.Fatalf("receiveMonster = %+v（ずかん %d 匹）, want %+v", m, len(dex), pikachu)
+	}
+
+	// 同じコードは、書き方を変えても2回目からは受け取れない
+	for _, again := range []string{code, strings.ToLower(code), strings.ReplaceAll(code, "-", "")} {
+		if _, err := receiveMonster(again); !errors.Is(err, errAlreadyTraded) {
+			t.Errorf("receiveMonster(%q) = %v, want errAlreadyTraded", again, err)
+		}
+	}
+	if len(dex) != 1 {
+		t.Errorf("ずかんが %d 匹になりました, want 1", len(dex))
+	}
+
+	// 別のモンスターのコードは受け取れる
+	other := pikachu
+	other.Level = 13
+	otherCode, _ := tradeCode(other)
+	if _, err := receiveMonster(otherCode); err != nil || len(dex) != 2 {
+		t.Errorf("別のコード: %v（ずかん %d 匹）", err, len(dex))
+	}
+
+	// 正しくないコードは覚えない
+	if _, err := receiveMonster("XXXXX-XXXXX"); err == nil {
+		t.Error("正しくないコードを受け取りました")
+	}
+	if len(tradedCodes) != 2 {
+		t.Errorf("使ったコードが %d あります, want 2", len(tradedCodes))
+	}
+}
+
+func TestSendRemoves(t *testing.T) {
+	resetTrade(t)
+	dex = []CaughtMonster{
+		{Species: "hitokage", Name: "ヒトカゲ", Level: 5, Size: sizeNames[1], SizeValue: 0.8, Rarity: 1, Accessory: accessoryNames[0]},
+		{Species: "mewtwo", Name: "ミュウツー", Level: 50, Size: sizeNames[4], SizeValue: 1.5, Rarity: 5, Shiny: true, Accessory: accessoryNames[1]},
+	}
+	mewtwo := dex[1]
+
+	code, err := sendMonster(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dex) != 1 || dex[0].Species != "hitokage" {
+		t.Errorf("おくったあとのずかん = %+v, want ヒトカゲだけ", dex)
+	}
+
+	// おくったコードを自分で受け取り直して増やすことはできない
+	if _, err := receiveMonster(code); !errors.Is(err, errAlreadyTraded) {
+		t.Errorf("おくったコードを受け取れました: %v", err)
+	}
+
+	// 相手のずかんでは1回だけ受け取れる
+	dex, tradedCodes = nil, nil
+	m, err := receiveMonster(code)
+	if err != nil || m != mewtwo {
+		t.Errorf("相手が受け取ったモンスター = %+v, %v, want %+v", m, err, mewtwo)
+	}
+	if _, err := receiveMonster(code); !errors.Is(err, errAlreadyTraded) {
+		t.Errorf("相手が2回受け取れました: %v", err)
+	}
+}
+
+func TestSaveKeepsTradedCodes(t *testing.T) {
+	data, err := decodeSave([]byte(`{"version": 2, "caught": [], "inventory": [1, 0, 0, 0], "tradedCodes": ["0ABCD-EFGH0"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.TradedCodes) != 1 || data.TradedCodes[0] != "0ABCD-EFGH0" {
+		t.Errorf("TradedCodes = %v", data.TradedCodes)
+	}
+}
@@ -0,0 +1,56 @@
+// chipwav は、internal/chiptune のトラッカー形式の曲を WAV ファイルに書き出します。
+// ブラウザで鳴らすのと同じ PCM なので、音を作るときの確認や聴き比べに使います。
+//
+//	go run ./tools/chipwav -o out retro_game/sounds.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ryomak/sketch/art/internal/chiptune"
+)
+
+func main() {
+	out := flag.String("o", ".", "WAV を書き出すディレクトリ")
+	rate := flag.Int("rate", 44100, "サンプリング周波数")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: chipwav [-o dir] [-rate 44100] sounds.txt")
+		os.Exit(2)
+	}
+
+	b, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	songs, err := chiptune.Parse(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for _, song := range songs {
+		path := filepath.Join(*out, song.Name+".wav")
+		if err := writeSong(path, song, *rate); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(path)
+	}
+}
+
+func writeSong(path string, song *chiptune.Song, rate int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := chiptune.WriteWAV(f, song.Render(rate), rate); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
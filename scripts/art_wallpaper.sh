@@ -0,0 +1,61 @@
+#!/bin/bash
+
+# Export a high-resolution PNG wallpaper of the cityscape art.
+# Requires the local server (yarn dev) running at localhost:4321.
+if [ $# -lt 3 ]; then
+  echo "Usage: $0 <name> <width> <height> [seed] [tile(0|1)] [hour]"
+  echo "  e.g. $0 20250209 3840 2160 42 1 21"
+  exit 1
+fi
+name="$1"
+width="$2"
+height="$3"
+seed="${4:-1}"
+tile="${5:-0}"
+hour="${6:-21}"
+
+language="go"
+gallery_dir="public/art/${language}/${name}"
+file_name="${language}_${name}"
+output="${gallery_dir}/wallpaper_${width}x${height}_${seed}.png"
+mkdir -p "$gallery_dir"
+
+query="seed=${seed}&width=${width}&height=${height}&tile=${tile}&hour=${hour}"
+
+# Render the artwork in a headless browser and save the canvas pixels as is
+# (an element screenshot would be scaled down by the page layout, so use toDataURL)
+node -e '
+  const puppeteer = require("puppeteer");
+  const fs = require("fs");
+  const sleep = (ms) => new Promise((res) => setTimeout(res, ms));
+
+  (async () => {
+    const browser = await puppeteer.launch({});
+    const page = await browser.newPage();
+    // Keep devicePixelRatio at 1 so the canvas has exactly the requested size
+    await page.setViewport({ width: 1280, height: 800, deviceScaleFactor: 1 });
+
+    await page.goto("http://localhost:4321/art/detail/'${file_name}'/?'${query}'");
+
+    // Wait for the canvas to be rendered
+    await sleep(3000);
+
+    const dataURL = await page.evaluate(() => {
+      const canvas = document.querySelector("#canvas-detail canvas");
+      return canvas ? canvas.toDataURL("image/png") : null;
+    });
+    if (!dataURL) {
+      console.error("Canvas element not found.");
+      process.exit(1);
+    }
+    fs.writeFileSync("'${output}'", Buffer.from(dataURL.split(",")[1], "base64"));
+
+    await browser.close();
+  })();
+'
+
+if [ -f "${output}" ]; then
+  echo "Wallpaper has been successfully generated: ${output}"
+else
+  echo "Failed to generate wallpaper."
+fi